@@ -28,6 +28,45 @@ type Config struct {
 	RedisURL            string
 	RedisTimeout        int
 	LogLevel            string
+
+	RecommendationsLimit          int
+	RecommendationsMaxPageSize    int
+	RecommendationsCandidateLimit int
+	RecommendationsSnapshotTTL    int
+	DeclineTTLDays                int
+
+	CFBlendWeight        float64
+	CFNeighbors          int
+	CFRebuildIntervalMin int
+
+	RankingStrategy         string
+	RankingBlendScoreWeight float64
+	RankingDistanceDecayKm  float64
+	DiversityLambda         float64
+
+	ExposureDailyCap  int
+	PendingRequestCap int
+
+	ActivityHalfLifeDays float64
+
+	LocationSwitchIntervalMin int
+	SavedSearchIntervalMin    int
+
+	SearchLanguage string
+
+	DigestIntervalMin int
+	DigestSize        int
+	DigestActiveDays  int
+	DigestEmail       bool
+
+	NearbyAlertMinScore   float64
+	NearbyAlertDailyLimit int
+
+	QuestionnaireBlendWeight float64
+
+	CommunityBoost float64
+
+	MessageEditWindowMin int
 }
 
 var AppConfig *Config
@@ -52,6 +91,45 @@ func LoadConfig() {
 		RedisURL:            getEnv("REDIS_URL", "localhost:6379"),
 		RedisTimeout:        getEnvAsInt("REDIS_TIMEOUT", 5),
 		LogLevel:            getEnv("LOG_LEVEL", "debug"),
+
+		RecommendationsLimit:          getEnvAsInt("RECOMMENDATIONS_LIMIT", 10),
+		RecommendationsMaxPageSize:    getEnvAsInt("RECOMMENDATIONS_MAX_PAGE_SIZE", 50),
+		RecommendationsCandidateLimit: getEnvAsInt("RECOMMENDATIONS_CANDIDATE_LIMIT", 500),
+		RecommendationsSnapshotTTL:    getEnvAsInt("RECOMMENDATIONS_SNAPSHOT_TTL", 30),
+		DeclineTTLDays:                getEnvAsInt("DECLINE_TTL_DAYS", 0),
+
+		CFBlendWeight:        getEnvAsFloat("CF_BLEND_WEIGHT", 0.2),
+		CFNeighbors:          getEnvAsInt("CF_NEIGHBORS", 50),
+		CFRebuildIntervalMin: getEnvAsInt("CF_REBUILD_INTERVAL", 60),
+
+		RankingStrategy:         strings.ToLower(getEnv("RANKING_STRATEGY", "distance")),
+		RankingBlendScoreWeight: getEnvAsFloat("RANKING_BLEND_SCORE_WEIGHT", 0.7),
+		RankingDistanceDecayKm:  getEnvAsFloat("RANKING_DISTANCE_DECAY_KM", 10),
+		DiversityLambda:         getEnvAsFloat("DIVERSITY_LAMBDA", 0.7),
+
+		ExposureDailyCap:  getEnvAsInt("EXPOSURE_DAILY_CAP", 200),
+		PendingRequestCap: getEnvAsInt("PENDING_REQUEST_CAP", 20),
+
+		ActivityHalfLifeDays: getEnvAsFloat("ACTIVITY_HALF_LIFE_DAYS", 0),
+
+		LocationSwitchIntervalMin: getEnvAsInt("LOCATION_SWITCH_INTERVAL", 5),
+		SavedSearchIntervalMin:    getEnvAsInt("SAVED_SEARCH_INTERVAL", 15),
+
+		SearchLanguage: strings.ToLower(getEnv("SEARCH_LANGUAGE", "english")),
+
+		DigestIntervalMin: getEnvAsInt("DIGEST_INTERVAL", 60),
+		DigestSize:        getEnvAsInt("DIGEST_SIZE", 5),
+		DigestActiveDays:  getEnvAsInt("DIGEST_ACTIVE_DAYS", 14),
+		DigestEmail:       strings.ToLower(getEnv("DIGEST_EMAIL", "false")) == "true",
+
+		NearbyAlertMinScore:   getEnvAsFloat("NEARBY_ALERT_MIN_SCORE", 0.06),
+		NearbyAlertDailyLimit: getEnvAsInt("NEARBY_ALERT_DAILY_LIMIT", 3),
+
+		QuestionnaireBlendWeight: getEnvAsFloat("QUESTIONNAIRE_BLEND_WEIGHT", 0),
+
+		CommunityBoost: getEnvAsFloat("COMMUNITY_BOOST", 0.05),
+
+		MessageEditWindowMin: getEnvAsInt("MESSAGE_EDIT_WINDOW", 60),
 	}
 
 	AppConfig.IsDev = AppConfig.Environment == "development"
@@ -98,6 +176,65 @@ func (c *Config) Validate() error {
 	if c.SMTPPort <= 0 {
 		return errors.New("SMTP_PORT must be greater than zero")
 	}
+	if c.RecommendationsLimit <= 0 {
+		return errors.New("RECOMMENDATIONS_LIMIT must be greater than zero")
+	}
+	if c.RecommendationsMaxPageSize < c.RecommendationsLimit {
+		return errors.New("RECOMMENDATIONS_MAX_PAGE_SIZE must not be less than RECOMMENDATIONS_LIMIT")
+	}
+	if c.RecommendationsCandidateLimit <= 0 {
+		return errors.New("RECOMMENDATIONS_CANDIDATE_LIMIT must be greater than zero")
+	}
+	if c.CFBlendWeight < 0 || c.CFBlendWeight > 1 {
+		return errors.New("CF_BLEND_WEIGHT must be between 0 and 1")
+	}
+	switch c.RankingStrategy {
+	case "distance", "score", "blend":
+	default:
+		return errors.New("RANKING_STRATEGY must be distance, score or blend")
+	}
+	if c.RankingBlendScoreWeight < 0 || c.RankingBlendScoreWeight > 1 {
+		return errors.New("RANKING_BLEND_SCORE_WEIGHT must be between 0 and 1")
+	}
+	if c.RankingDistanceDecayKm <= 0 {
+		return errors.New("RANKING_DISTANCE_DECAY_KM must be greater than zero")
+	}
+	if c.SearchLanguage == "" {
+		return errors.New("SEARCH_LANGUAGE cannot be empty")
+	}
+	if c.DiversityLambda < 0 || c.DiversityLambda > 1 {
+		return errors.New("DIVERSITY_LAMBDA must be between 0 and 1")
+	}
+	if c.ExposureDailyCap < 0 {
+		return errors.New("EXPOSURE_DAILY_CAP must not be negative")
+	}
+	if c.PendingRequestCap < 0 {
+		return errors.New("PENDING_REQUEST_CAP must not be negative")
+	}
+	if c.ActivityHalfLifeDays < 0 {
+		return errors.New("ACTIVITY_HALF_LIFE_DAYS must not be negative")
+	}
+	if c.DigestSize <= 0 {
+		return errors.New("DIGEST_SIZE must be greater than zero")
+	}
+	if c.DigestActiveDays <= 0 {
+		return errors.New("DIGEST_ACTIVE_DAYS must be greater than zero")
+	}
+	if c.NearbyAlertMinScore < 0 || c.NearbyAlertMinScore > 1 {
+		return errors.New("NEARBY_ALERT_MIN_SCORE must be between 0 and 1")
+	}
+	if c.NearbyAlertDailyLimit < 0 {
+		return errors.New("NEARBY_ALERT_DAILY_LIMIT must not be negative")
+	}
+	if c.QuestionnaireBlendWeight < 0 || c.QuestionnaireBlendWeight > 1 {
+		return errors.New("QUESTIONNAIRE_BLEND_WEIGHT must be between 0 and 1")
+	}
+	if c.CommunityBoost < 0 || c.CommunityBoost > 1 {
+		return errors.New("COMMUNITY_BOOST must be between 0 and 1")
+	}
+	if c.MessageEditWindowMin < 0 {
+		return errors.New("MESSAGE_EDIT_WINDOW must not be negative")
+	}
 	if c.LogLevel == "" {
 		return errors.New("LOG_LEVEL cannot be empty")
 	}
@@ -127,3 +264,19 @@ func getEnvAsInt(name string, defaultVal int) int {
 
 	return val
 }
+
+// getEnvAsFloat returns the value of an environment variable as float64 or a default value.
+func getEnvAsFloat(name string, defaultVal float64) float64 {
+	valStr := os.Getenv(name)
+	if valStr == "" {
+		return defaultVal
+	}
+
+	val, err := strconv.ParseFloat(valStr, 64)
+	if err != nil {
+		logrus.Warnf("Failed to convert %s to float: %v. Using default value.", name, err)
+		return defaultVal
+	}
+
+	return val
+}
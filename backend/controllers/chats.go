@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"log"
+	"m/backend/config"
 	"m/backend/services"
 	"m/backend/sockets"
 	"net/http"
@@ -21,24 +22,34 @@ import (
 )
 
 var chatsDB *gorm.DB
+var chatsService *services.ChatService
 
 // chats.go - Handles HTTP endpoints for chat and messaging functionality.
 // Provides chat list, message history, sending messages, and chat creation.
 // Integrates with presence service for real-time online status and with WebSocket for message delivery.
+// Group chat management (participants, roles, read positions) is in group_chats.go,
+// message editing and deletion in message_edits.go.
 
 // InitChatsController initializes the chats controller with database connection
 // and presence service for real-time user status updates.
 // Should be called once at startup.
 func InitChatsController(db *gorm.DB, ps *services.PresenceService) {
 	chatsDB = db
+	chatsService = services.NewChatService(db)
+	services.SetMessageEditWindow(time.Duration(config.AppConfig.MessageEditWindowMin) * time.Minute)
 	presenceService = ps
 	logrus.Info("Chats controller initialized")
 }
 
 // ChatSummary represents a condensed view of a chat for the chat list.
 // Includes basic user info, last message, unread count, and online status.
+// Group chats have a name, an avatar and a member count instead of the other user.
 type ChatSummary struct {
 	ChatID      uint      `json:"chatId"`
+	IsGroup     bool      `json:"isGroup"`
+	Name        string    `json:"name,omitempty"`
+	AvatarURL   string    `json:"avatarUrl,omitempty"`
+	MemberCount int       `json:"memberCount"`
 	OtherUserID uuid.UUID `json:"otherUserId"`
 	OtherUser   *struct {
 		ID        uuid.UUID `json:"id"`
@@ -55,22 +66,31 @@ type ChatSummary struct {
 
 // for display in chat lists and message history.
 type MessageSummary struct {
-	ID        uint      `json:"id"`
-	SenderID  uuid.UUID `json:"senderId"`
-	Content   string    `json:"content"`
-	Timestamp time.Time `json:"timestamp"`
-	Read      bool      `json:"read"`
+	ID        uint       `json:"id"`
+	SenderID  uuid.UUID  `json:"senderId"`
+	Content   string     `json:"content"`
+	Timestamp time.Time  `json:"timestamp"`
+	Read      bool       `json:"read"`
+	EditedAt  *time.Time `json:"editedAt,omitempty"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
 }
 
 // ChatMessageResponse represents a message in the chat with additional
-// sender information for display purposes.
+// sender information for display purposes. Deleted messages have empty content.
 type ChatMessageResponse struct {
-	ID         uint      `json:"id"`
-	Content    string    `json:"content"`
-	Timestamp  time.Time `json:"timestamp"`
-	Read       bool      `json:"read"`
-	SenderID   uuid.UUID `json:"sender_id"`
-	SenderName string    `json:"sender_name"`
+	ID         uint       `json:"id"`
+	Content    string     `json:"content"`
+	Timestamp  time.Time  `json:"timestamp"`
+	Read       bool       `json:"read"`
+	SenderID   uuid.UUID  `json:"sender_id"`
+	SenderName string     `json:"sender_name"`
+	EditedAt   *time.Time `json:"edited_at,omitempty"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+}
+
+// hiddenMessages selects the IDs of the messages the user deleted for themselves.
+func hiddenMessages(userID uuid.UUID) *gorm.DB {
+	return chatsDB.Model(&models.HiddenMessage{}).Select("message_id").Where("user_id = ?", userID)
 }
 
 // GetChats handles the HTTP request to retrieve the current user's chat list.
@@ -93,7 +113,8 @@ func GetChats(w http.ResponseWriter, r *http.Request) {
 
 	var chats []models.Chat
 	if err := chatsDB.
-		Where("user1_id = ? OR user2_id = ?", currentUserID, currentUserID).
+		Preload("Participants").
+		Where("id IN (?)", chatsDB.Model(&models.ChatParticipant{}).Select("chat_id").Where("user_id = ?", currentUserID)).
 		Find(&chats).Error; err != nil {
 		http.Error(w, "Error fetching chats", http.StatusInternalServerError)
 		return
@@ -102,16 +123,24 @@ func GetChats(w http.ResponseWriter, r *http.Request) {
 	summaries := make([]ChatSummary, 0, len(chats))
 	for _, chat := range chats {
 		var otherUserID uuid.UUID
-		if chat.User1ID == currentUserID {
-			otherUserID = chat.User2ID
-		} else {
-			otherUserID = chat.User1ID
+		isTyping := false
+		for _, p := range chat.Participants {
+			if p.UserID == currentUserID {
+				continue
+			}
+			if otherUserID == uuid.Nil {
+				otherUserID = p.UserID
+			}
+			if !isTyping && sockets.IsUserTypingInChat(chat.ID, p.UserID.String()) {
+				isTyping = true
+			}
 		}
 
 		var lastMsg models.Message
 		res := chatsDB.
 			Model(&models.Message{}).
 			Where("chat_id = ?", chat.ID).
+			Where("id NOT IN (?)", hiddenMessages(currentUserID)).
 			Order("timestamp desc").
 			Limit(1).
 			First(&lastMsg)
@@ -127,18 +156,32 @@ func GetChats(w http.ResponseWriter, r *http.Request) {
 				Content:   lastMsg.Content,
 				Timestamp: lastMsg.Timestamp,
 				Read:      lastMsg.Read,
+				EditedAt:  lastMsg.EditedAt,
+				DeletedAt: lastMsg.DeletedAt,
 			}
 		}
 
-		var unreadCount int64
-		if err := chatsDB.
-			Model(&models.Message{}).
-			Where("chat_id = ? AND sender_id <> ? AND read = ?", chat.ID, currentUserID, false).
-			Count(&unreadCount).Error; err != nil {
+		unreadCount, err := chatsService.UnreadCount(chat.ID, currentUserID)
+		if err != nil {
 			http.Error(w, "Error counting unread messages", http.StatusInternalServerError)
 			return
 		}
 
+		if chat.IsGroup {
+			summaries = append(summaries, ChatSummary{
+				ChatID:        chat.ID,
+				IsGroup:       true,
+				Name:          chat.Name,
+				AvatarURL:     chat.AvatarURL,
+				MemberCount:   len(chat.Participants),
+				LastMessage:   lastSummary,
+				UnreadCount:   int(unreadCount),
+				IsTyping:      isTyping,
+				ChatCreatedAt: chat.CreatedAt,
+			})
+			continue
+		}
+
 		var otherProfile models.Profile
 		if err := chatsDB.
 			Select("first_name", "last_name", "photo_url").
@@ -155,11 +198,12 @@ func GetChats(w http.ResponseWriter, r *http.Request) {
 
 		summary := ChatSummary{
 			ChatID:          chat.ID,
+			MemberCount:     len(chat.Participants),
 			OtherUserID:     otherUserID,
 			LastMessage:     lastSummary,
 			UnreadCount:     int(unreadCount),
 			OtherUserOnline: otherOnline,
-			IsTyping:        sockets.IsUserTypingInChat(chat.ID, otherUserID.String()),
+			IsTyping:        isTyping,
 			ChatCreatedAt:   chat.CreatedAt,
 			OtherUser: &struct {
 				ID        uuid.UUID `json:"id"`
@@ -226,18 +270,10 @@ func GetChatHistory(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		var chat models.Chat
-		err = chatsDB.
-			Where("(user1_id = ? AND user2_id = ?) OR (user1_id = ? AND user2_id = ?)",
-				currentUserID, otherUserID, otherUserID, currentUserID).
-			First(&chat).Error
-
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			chat = models.Chat{User1ID: currentUserID, User2ID: otherUserID}
-			if err := chatsDB.Create(&chat).Error; err != nil {
-				http.Error(w, "Error creating chat", http.StatusInternalServerError)
-				return
-			}
+		chat, err := chatsService.CreateChat(currentUserID, otherUserID)
+		if err != nil {
+			http.Error(w, "Error creating chat", http.StatusInternalServerError)
+			return
 		}
 
 		resp := struct {
@@ -262,7 +298,7 @@ func GetChatHistory(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Chat not found", http.StatusNotFound)
 		return
 	}
-	if chat.User1ID != currentUserID && chat.User2ID != currentUserID {
+	if _, err := chatsService.Participant(chat.ID, currentUserID); err != nil {
 		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
@@ -271,6 +307,7 @@ func GetChatHistory(w http.ResponseWriter, r *http.Request) {
 	if err := chatsDB.
 		Model(&models.Message{}).
 		Where("chat_id = ?", chat.ID).
+		Where("id NOT IN (?)", hiddenMessages(currentUserID)).
 		Count(&totalCount).Error; err != nil {
 		http.Error(w, "Error counting messages", http.StatusInternalServerError)
 		return
@@ -293,6 +330,7 @@ func GetChatHistory(w http.ResponseWriter, r *http.Request) {
 	if err := chatsDB.
 		Preload("Sender.Profile").
 		Where("chat_id = ?", chat.ID).
+		Where("id NOT IN (?)", hiddenMessages(currentUserID)).
 		Order("timestamp desc").
 		Offset(offset).
 		Limit(limit).
@@ -302,10 +340,9 @@ func GetChatHistory(w http.ResponseWriter, r *http.Request) {
 	}
 
 	go func() {
-		_ = chatsDB.
-			Model(&models.Message{}).
-			Where("chat_id = ? AND sender_id <> ? AND read = ?", chat.ID, currentUserID, false).
-			Update("read", true).Error
+		if _, _, err := chatsService.MarkRead(chat.ID, currentUserID, 0); err != nil {
+			logrus.Errorf("GetChatHistory: marking chat %d read for %s failed: %v", chat.ID, currentUserID, err)
+		}
 	}()
 
 	for i := 0; i < len(messages)/2; i++ {
@@ -326,6 +363,8 @@ func GetChatHistory(w http.ResponseWriter, r *http.Request) {
 			Read:       m.Read,
 			SenderID:   m.SenderID,
 			SenderName: fullName,
+			EditedAt:   m.EditedAt,
+			DeletedAt:  m.DeletedAt,
 		}
 	}
 
@@ -370,7 +409,7 @@ func PostMessage(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Chat not found", http.StatusNotFound)
 		return
 	}
-	if chat.User1ID != currentUserID && chat.User2ID != currentUserID {
+	if _, err := chatsService.Participant(chat.ID, currentUserID); err != nil {
 		logrus.Warnf("PostMessage: user %s is not a participant of chat %d", currentUserID, chatID)
 		http.Error(w, "You are not a participant of this chat", http.StatusForbidden)
 		return
@@ -439,17 +478,10 @@ func CreateOrGetChat(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid other_user_id", http.StatusBadRequest)
 		return
 	}
-	var chat models.Chat
-	err = chatsDB.
-		Where("(user1_id = ? AND user2_id = ?) OR (user1_id = ? AND user2_id = ?)",
-			userID, otherID, otherID, userID).
-		First(&chat).Error
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		chat = models.Chat{User1ID: userID, User2ID: otherID}
-		if err := chatsDB.Create(&chat).Error; err != nil {
-			http.Error(w, "Cannot create chat", http.StatusInternalServerError)
-			return
-		}
+	chat, err := chatsService.CreateChat(userID, otherID)
+	if err != nil {
+		http.Error(w, "Cannot create chat", http.StatusInternalServerError)
+		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]uint{"chatId": chat.ID})
@@ -0,0 +1,386 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"m/backend/services"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// communities.go - Interest communities: creation around a Bio tag, discovery,
+// membership with owner/moderator roles, the community board and per-city stats.
+
+var communityService *services.CommunityService
+
+// InitCommunitiesController initializes the community service for this controller.
+// Should be called once at startup.
+func InitCommunitiesController(db *gorm.DB) {
+	communityService = services.NewCommunityService(db)
+	logrus.Info("Communities controller initialized")
+}
+
+// writeCommunityError maps community service errors to HTTP responses.
+func writeCommunityError(w http.ResponseWriter, op string, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Community not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidCommunity):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrCommunityForbidden), errors.Is(err, services.ErrNotMember):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		logrus.Errorf("%s failed: %v", op, err)
+		http.Error(w, "Error processing community", http.StatusInternalServerError)
+	}
+}
+
+// communityRequest returns the current user and the {id} community ID of the request,
+// writing an error response when either is missing or malformed.
+func communityRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, uint, bool) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return uuid.Nil, 0, false
+	}
+	currentUserID, _ := uuid.Parse(userIDStr)
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid community ID", http.StatusBadRequest)
+		return uuid.Nil, 0, false
+	}
+	return currentUserID, uint(id), true
+}
+
+// GetCommunities handles GET /communities endpoint.
+// Query: tag, city, limit. Returns matching communities, largest first.
+func GetCommunities(w http.ResponseWriter, r *http.Request) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	currentUserID, _ := uuid.Parse(userIDStr)
+
+	q := r.URL.Query()
+	search := services.CommunitySearch{Tag: q.Get("tag"), City: q.Get("city")}
+	search.Limit, _ = strconv.Atoi(q.Get("limit"))
+	list, err := communityService.Search(currentUserID, search)
+	if err != nil {
+		writeCommunityError(w, "GetCommunities", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// GetSuggestedCommunities handles GET /communities/suggested endpoint.
+// Returns communities around the tags of the user's bio they have not joined,
+// the ones in their city first. Query: limit (default 20).
+func GetSuggestedCommunities(w http.ResponseWriter, r *http.Request) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	currentUserID, _ := uuid.Parse(userIDStr)
+
+	limit := 20
+	if l, _ := strconv.Atoi(r.URL.Query().Get("limit")); l > 0 {
+		limit = l
+	}
+	list, err := communityService.Suggested(currentUserID, limit)
+	if err != nil {
+		writeCommunityError(w, "GetSuggestedCommunities", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// GetMyCommunities handles GET /me/communities endpoint.
+func GetMyCommunities(w http.ResponseWriter, r *http.Request) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	currentUserID, _ := uuid.Parse(userIDStr)
+
+	list, err := communityService.Mine(currentUserID)
+	if err != nil {
+		writeCommunityError(w, "GetMyCommunities", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// CreateCommunity handles POST /communities endpoint.
+// Body: {"tag": "jazz", "name": "Jazz lovers", "description": "...", "city": "Helsinki"}.
+// The tag must appear in the creator's bio; the creator becomes the owner.
+func CreateCommunity(w http.ResponseWriter, r *http.Request) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	currentUserID, _ := uuid.Parse(userIDStr)
+
+	var input services.CommunityInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	community, err := communityService.Create(currentUserID, input)
+	if err != nil {
+		writeCommunityError(w, "CreateCommunity", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(community)
+}
+
+// GetCommunity handles GET /communities/{id} endpoint.
+func GetCommunity(w http.ResponseWriter, r *http.Request) {
+	currentUserID, id, ok := communityRequest(w, r)
+	if !ok {
+		return
+	}
+	community, err := communityService.Get(id, currentUserID)
+	if err != nil {
+		writeCommunityError(w, "GetCommunity", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(community)
+}
+
+// UpdateCommunity handles PUT /communities/{id} endpoint (owner only).
+// Body: name, description and city, all optional. The tag cannot be changed.
+func UpdateCommunity(w http.ResponseWriter, r *http.Request) {
+	currentUserID, id, ok := communityRequest(w, r)
+	if !ok {
+		return
+	}
+	var input services.CommunityInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	community, err := communityService.Update(id, currentUserID, input)
+	if err != nil {
+		writeCommunityError(w, "UpdateCommunity", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(community)
+}
+
+// DeleteCommunity handles DELETE /communities/{id} endpoint (owner only).
+func DeleteCommunity(w http.ResponseWriter, r *http.Request) {
+	currentUserID, id, ok := communityRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := communityService.Delete(id, currentUserID); err != nil {
+		writeCommunityError(w, "DeleteCommunity", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// JoinCommunity handles POST /communities/{id}/membership endpoint.
+func JoinCommunity(w http.ResponseWriter, r *http.Request) {
+	currentUserID, id, ok := communityRequest(w, r)
+	if !ok {
+		return
+	}
+	membership, err := communityService.Join(id, currentUserID)
+	if err != nil {
+		writeCommunityError(w, "JoinCommunity", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(membership)
+}
+
+// LeaveCommunity handles DELETE /communities/{id}/membership endpoint.
+// The owner cannot leave, they delete the community instead.
+func LeaveCommunity(w http.ResponseWriter, r *http.Request) {
+	currentUserID, id, ok := communityRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := communityService.Leave(id, currentUserID); err != nil {
+		writeCommunityError(w, "LeaveCommunity", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetCommunityMembers handles GET /communities/{id}/members endpoint.
+// Lists the owner, moderators and members. Only visible to members.
+func GetCommunityMembers(w http.ResponseWriter, r *http.Request) {
+	currentUserID, id, ok := communityRequest(w, r)
+	if !ok {
+		return
+	}
+	list, err := communityService.Members(id, currentUserID)
+	if err != nil {
+		writeCommunityError(w, "GetCommunityMembers", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// UpdateCommunityMember handles PUT /communities/{id}/members/{userId} endpoint (owner only).
+// Body: {"role": "moderator"} or {"role": "member"}.
+func UpdateCommunityMember(w http.ResponseWriter, r *http.Request) {
+	currentUserID, id, ok := communityRequest(w, r)
+	if !ok {
+		return
+	}
+	memberID, err := uuid.Parse(mux.Vars(r)["userId"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	var input struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := communityService.SetRole(id, currentUserID, memberID, input.Role); err != nil {
+		writeCommunityError(w, "UpdateCommunityMember", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveCommunityMember handles DELETE /communities/{id}/members/{userId} endpoint.
+// The owner removes anyone else, moderators only plain members.
+func RemoveCommunityMember(w http.ResponseWriter, r *http.Request) {
+	currentUserID, id, ok := communityRequest(w, r)
+	if !ok {
+		return
+	}
+	memberID, err := uuid.Parse(mux.Vars(r)["userId"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	if err := communityService.RemoveMember(id, currentUserID, memberID); err != nil {
+		writeCommunityError(w, "RemoveCommunityMember", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetCommunityPosts handles GET /communities/{id}/posts endpoint.
+// Query: page (default 1), limit (default 20, max 100). Newest first, members only.
+func GetCommunityPosts(w http.ResponseWriter, r *http.Request) {
+	currentUserID, id, ok := communityRequest(w, r)
+	if !ok {
+		return
+	}
+	page, limit := 1, 20
+	if p, _ := strconv.Atoi(r.URL.Query().Get("page")); p > 0 {
+		page = p
+	}
+	if l, _ := strconv.Atoi(r.URL.Query().Get("limit")); l > 0 && l <= 100 {
+		limit = l
+	}
+	posts, total, err := communityService.Posts(id, currentUserID, page, limit)
+	if err != nil {
+		writeCommunityError(w, "GetCommunityPosts", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"posts":      posts,
+		"totalCount": total,
+		"page":       page,
+		"limit":      limit,
+	})
+}
+
+// CreateCommunityPost handles POST /communities/{id}/posts endpoint.
+// Body: {"content": "..."}. The post is pushed to the members as a community_post event.
+func CreateCommunityPost(w http.ResponseWriter, r *http.Request) {
+	currentUserID, id, ok := communityRequest(w, r)
+	if !ok {
+		return
+	}
+	var input struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	post, err := communityService.Post(id, currentUserID, input.Content)
+	if err != nil {
+		writeCommunityError(w, "CreateCommunityPost", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(post)
+}
+
+// DeleteCommunityPost handles DELETE /communities/{id}/posts/{postId} endpoint.
+// Authors delete their own posts, the owner and moderators any post.
+func DeleteCommunityPost(w http.ResponseWriter, r *http.Request) {
+	currentUserID, id, ok := communityRequest(w, r)
+	if !ok {
+		return
+	}
+	postID, err := strconv.ParseUint(mux.Vars(r)["postId"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+		return
+	}
+	if err := communityService.DeletePost(id, uint(postID), currentUserID); err != nil {
+		writeCommunityError(w, "DeleteCommunityPost", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetCityCommunityStats handles GET /cities/communities endpoint.
+// Query: community (ID, optional), city (optional), days (joins window, default 30).
+// Returns per city of the members' profiles: communities, members and recent joins.
+func GetCityCommunityStats(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var communityID uint64
+	if s := q.Get("community"); s != "" {
+		var err error
+		if communityID, err = strconv.ParseUint(s, 10, 64); err != nil {
+			http.Error(w, "Invalid community ID", http.StatusBadRequest)
+			return
+		}
+	}
+	days := 30
+	if d, err := strconv.Atoi(q.Get("days")); err == nil && d > 0 && d <= 365 {
+		days = d
+	}
+	stats, err := communityService.CityStats(uint(communityID), q.Get("city"), time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		writeCommunityError(w, "GetCityCommunityStats", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
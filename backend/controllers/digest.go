@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"m/backend/config"
+	"m/backend/services"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var digestService *services.DigestService
+
+// InitDigestController initializes the digest controller.
+// Must be called after InitRecommendationControllerService.
+// Digests are built by the background job started in main; this controller only reads them.
+func InitDigestController(db *gorm.DB) {
+	digestService = services.NewDigestService(db, recommendationService, nil,
+		config.AppConfig.DigestSize, config.AppConfig.DigestActiveDays)
+	logrus.Info("Digest controller initialized")
+}
+
+// GetDigest handles GET /me/digest endpoint.
+// Returns the current user's latest daily digest: {servedAt, items: [{id, firstName, photoUrl, city, distance, score}]}.
+// servedAt is null and items empty if no digest was sent yet.
+func GetDigest(w http.ResponseWriter, r *http.Request) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	currentUserID, _ := uuid.Parse(userIDStr)
+
+	items, servedAt, err := digestService.LatestDigest(currentUserID)
+	if err != nil {
+		logrus.Errorf("GetDigest failed: %v", err)
+		http.Error(w, "Error fetching digest", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		ServedAt *time.Time            `json:"servedAt"`
+		Items    []services.DigestItem `json:"items"`
+	}{servedAt, items})
+}
@@ -0,0 +1,351 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"m/backend/services"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// events.go - Events and meetups: creation, geo discovery, RSVPs with waitlist,
+// the event group chat and recommendations of people going to the same events.
+
+var eventService *services.EventService
+
+// InitEventsController initializes the event service for this controller.
+// Should be called once at startup.
+func InitEventsController(db *gorm.DB) {
+	eventService = services.NewEventService(db)
+	logrus.Info("Events controller initialized")
+}
+
+// writeEventError maps event service errors to HTTP responses.
+func writeEventError(w http.ResponseWriter, op string, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Event not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidEvent):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrEventForbidden), errors.Is(err, services.ErrNotAttending):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, services.ErrEventClosed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		logrus.Errorf("%s failed: %v", op, err)
+		http.Error(w, "Error processing event", http.StatusInternalServerError)
+	}
+}
+
+// eventRequest returns the current user and the {id} event ID of the request,
+// writing an error response when either is missing or malformed.
+func eventRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, uint, bool) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return uuid.Nil, 0, false
+	}
+	currentUserID, _ := uuid.Parse(userIDStr)
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid event ID", http.StatusBadRequest)
+		return uuid.Nil, 0, false
+	}
+	return currentUserID, uint(id), true
+}
+
+// GetEvents handles GET /events endpoint.
+// Query: lat, lon (default: profile location), radius in km (default: maxRadius or 25),
+// tag, from and to (RFC 3339), limit. Returns upcoming events nearby, soonest first.
+func GetEvents(w http.ResponseWriter, r *http.Request) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	currentUserID, _ := uuid.Parse(userIDStr)
+
+	q := r.URL.Query()
+	var search services.EventSearch
+	if q.Get("lat") != "" || q.Get("lon") != "" {
+		lat, errLat := strconv.ParseFloat(q.Get("lat"), 64)
+		lon, errLon := strconv.ParseFloat(q.Get("lon"), 64)
+		if errLat != nil || errLon != nil {
+			http.Error(w, "lat and lon must both be numbers", http.StatusBadRequest)
+			return
+		}
+		search.Latitude, search.Longitude = &lat, &lon
+	}
+	if v := q.Get("radius"); v != "" {
+		radius, err := strconv.ParseFloat(v, 64)
+		if err != nil || radius <= 0 {
+			http.Error(w, "radius must be a positive number", http.StatusBadRequest)
+			return
+		}
+		search.RadiusKm = radius
+	}
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{{"from", &search.From}, {"to", &search.To}} {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, p.name+" must be an RFC 3339 time", http.StatusBadRequest)
+				return
+			}
+			*p.dst = &t
+		}
+	}
+	search.Tag = q.Get("tag")
+	if v := q.Get("limit"); v != "" {
+		search.Limit, _ = strconv.Atoi(v)
+	}
+
+	list, err := eventService.Nearby(currentUserID, search)
+	if err != nil {
+		writeEventError(w, "GetEvents", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// GetMyEvents handles GET /me/events endpoint.
+// Returns the upcoming events the user created or RSVPed to.
+func GetMyEvents(w http.ResponseWriter, r *http.Request) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	currentUserID, _ := uuid.Parse(userIDStr)
+
+	list, err := eventService.Mine(currentUserID)
+	if err != nil {
+		writeEventError(w, "GetMyEvents", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// CreateEvent handles POST /events endpoint.
+// Body: {"title": "Board games", "description": "...", "city": "Helsinki", "address": "...",
+// "latitude": 60.17, "longitude": 24.94, "startsAt": "2026-11-01T18:00:00Z",
+// "endsAt": "2026-11-01T21:00:00Z", "capacity": 8, "tags": ["boardgames", "coffee"]}.
+func CreateEvent(w http.ResponseWriter, r *http.Request) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	currentUserID, _ := uuid.Parse(userIDStr)
+
+	var input services.EventInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	event, err := eventService.Create(currentUserID, input)
+	if err != nil {
+		writeEventError(w, "CreateEvent", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(event)
+}
+
+// GetEvent handles GET /events/{id} endpoint.
+func GetEvent(w http.ResponseWriter, r *http.Request) {
+	currentUserID, id, ok := eventRequest(w, r)
+	if !ok {
+		return
+	}
+	event, err := eventService.Get(id, currentUserID)
+	if err != nil {
+		writeEventError(w, "GetEvent", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(event)
+}
+
+// UpdateEvent handles PUT /events/{id} endpoint (creator only).
+// Body: any fields of CreateEvent; omitted fields are left unchanged.
+func UpdateEvent(w http.ResponseWriter, r *http.Request) {
+	currentUserID, id, ok := eventRequest(w, r)
+	if !ok {
+		return
+	}
+	var input services.EventInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	event, err := eventService.Update(id, currentUserID, input)
+	if err != nil {
+		writeEventError(w, "UpdateEvent", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(event)
+}
+
+// CancelEvent handles DELETE /events/{id} endpoint (creator only).
+// The event is kept as canceled and everyone who RSVPed is notified.
+func CancelEvent(w http.ResponseWriter, r *http.Request) {
+	currentUserID, id, ok := eventRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := eventService.Cancel(id, currentUserID); err != nil {
+		writeEventError(w, "CancelEvent", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RSVPEvent handles POST /events/{id}/rsvp endpoint.
+// Returns the RSVP with status "going", or "waitlisted" when the event is full.
+func RSVPEvent(w http.ResponseWriter, r *http.Request) {
+	currentUserID, id, ok := eventRequest(w, r)
+	if !ok {
+		return
+	}
+	rsvp, err := eventService.RSVP(id, currentUserID)
+	if err != nil {
+		writeEventError(w, "RSVPEvent", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rsvp)
+}
+
+// LeaveEvent handles DELETE /events/{id}/rsvp endpoint.
+// The freed spot goes to the first person on the waitlist.
+func LeaveEvent(w http.ResponseWriter, r *http.Request) {
+	currentUserID, id, ok := eventRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := eventService.Leave(id, currentUserID); err != nil {
+		writeEventError(w, "LeaveEvent", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetEventAttendees handles GET /events/{id}/attendees endpoint.
+// Lists people going, then the waitlist. Only visible to users who RSVPed.
+func GetEventAttendees(w http.ResponseWriter, r *http.Request) {
+	currentUserID, id, ok := eventRequest(w, r)
+	if !ok {
+		return
+	}
+	list, err := eventService.Attendees(id, currentUserID)
+	if err != nil {
+		writeEventError(w, "GetEventAttendees", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// GetEventMessages handles GET /events/{id}/messages endpoint.
+// Query: page, limit (default 1, 20). Returns the event chat for people going.
+func GetEventMessages(w http.ResponseWriter, r *http.Request) {
+	currentUserID, id, ok := eventRequest(w, r)
+	if !ok {
+		return
+	}
+	page, limit := 1, 20
+	if p, _ := strconv.Atoi(r.URL.Query().Get("page")); p > 0 {
+		page = p
+	}
+	if l, _ := strconv.Atoi(r.URL.Query().Get("limit")); l > 0 && l <= 100 {
+		limit = l
+	}
+	messages, total, err := eventService.Messages(id, currentUserID, page, limit)
+	if err != nil {
+		writeEventError(w, "GetEventMessages", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"messages":   messages,
+		"totalCount": total,
+		"page":       page,
+		"limit":      limit,
+	})
+}
+
+// PostEventMessage handles POST /events/{id}/messages endpoint.
+// Body: {"content": "..."}. The message is pushed to everyone going as an event_message event.
+func PostEventMessage(w http.ResponseWriter, r *http.Request) {
+	currentUserID, id, ok := eventRequest(w, r)
+	if !ok {
+		return
+	}
+	var input struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	msg, err := eventService.PostMessage(id, currentUserID, input.Content)
+	if err != nil {
+		writeEventError(w, "PostEventMessage", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(msg)
+}
+
+// GetEventRecommendations handles GET /events/{id}/recommendations and
+// GET /recommendations/events endpoints.
+// Returns people going to the same upcoming events (this one, or all of the user's)
+// who share interests. Query: mode (affinity|desire), limit (default 20).
+func GetEventRecommendations(w http.ResponseWriter, r *http.Request) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	currentUserID, _ := uuid.Parse(userIDStr)
+	var eventID uint
+	if v, found := mux.Vars(r)["id"]; found {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid event ID", http.StatusBadRequest)
+			return
+		}
+		if _, err := eventService.Get(uint(id), currentUserID); err != nil {
+			writeEventError(w, "GetEventRecommendations", err)
+			return
+		}
+		eventID = uint(id)
+	}
+	limit := 20
+	if l, _ := strconv.Atoi(r.URL.Query().Get("limit")); l > 0 {
+		limit = l
+	}
+
+	list, err := recommendationService.GetEventRecommendations(currentUserID, eventID, r.URL.Query().Get("mode"), limit)
+	if err != nil {
+		writeEventError(w, "GetEventRecommendations", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"m/backend/models"
+	"m/backend/services"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// experiments.go - Admin endpoints for A/B experiments on recommendation scoring.
+// Variants override field weights and sort order; GetRecommendations assigns a
+// variant per user and records impressions used by the metrics endpoint.
+
+var experimentService *services.ExperimentService
+
+// InitExperimentsController initializes the experiment service for this controller.
+// Should be called once at startup.
+func InitExperimentsController(db *gorm.DB) {
+	experimentService = services.NewExperimentService(db)
+	logrus.Info("Experiments controller initialized")
+}
+
+type experimentVariantInput struct {
+	Name       string                 `json:"name"`
+	Allocation int                    `json:"allocation"`
+	Config     services.VariantConfig `json:"config"`
+}
+
+type experimentInput struct {
+	Name     string                   `json:"name"`
+	Active   bool                     `json:"active"`
+	Variants []experimentVariantInput `json:"variants"`
+}
+
+// CreateExperiment handles POST /admin/experiments endpoint.
+// Body: {"name": "...", "active": true, "variants": [{"name": "control", "allocation": 1, "config": {}},
+// {"name": "music-heavy", "allocation": 1, "config": {"fieldWeights": {"Music": 0.05}, "sortOrder": "score"}}]}.
+func CreateExperiment(w http.ResponseWriter, r *http.Request) {
+	var input experimentInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	exp := models.Experiment{Name: input.Name, Active: input.Active}
+	for _, v := range input.Variants {
+		cfg, _ := json.Marshal(v.Config)
+		exp.Variants = append(exp.Variants, models.ExperimentVariant{
+			Name:       v.Name,
+			Allocation: v.Allocation,
+			Config:     string(cfg),
+		})
+	}
+
+	if err := experimentService.CreateExperiment(&exp, recommendationService.FieldNames()); err != nil {
+		if errors.Is(err, services.ErrInvalidExperiment) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logrus.Errorf("CreateExperiment failed: %v", err)
+		http.Error(w, "Error creating experiment", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(exp)
+}
+
+// GetExperiments handles GET /admin/experiments endpoint.
+// Returns all experiments with their variants.
+func GetExperiments(w http.ResponseWriter, r *http.Request) {
+	list, err := experimentService.ListExperiments()
+	if err != nil {
+		logrus.Errorf("GetExperiments failed: %v", err)
+		http.Error(w, "Error fetching experiments", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// UpdateExperiment handles PUT /admin/experiments/{id} endpoint.
+// Body: {"active": true|false}. Starts or stops the experiment.
+func UpdateExperiment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid experiment ID", http.StatusBadRequest)
+		return
+	}
+	var input struct {
+		Active *bool `json:"active"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.Active == nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	exp, err := experimentService.SetActive(uint(id), *input.Active)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Experiment not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logrus.Errorf("UpdateExperiment failed: %v", err)
+		http.Error(w, "Error updating experiment", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(exp)
+}
+
+// GetExperimentMetrics handles GET /admin/experiments/{id}/metrics endpoint.
+// Returns per-variant impressions, connection requests and acceptance rates.
+func GetExperimentMetrics(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid experiment ID", http.StatusBadRequest)
+		return
+	}
+	metrics, err := experimentService.Metrics(uint(id))
+	if err != nil {
+		logrus.Errorf("GetExperimentMetrics failed: %v", err)
+		http.Error(w, "Error fetching experiment metrics", http.StatusInternalServerError)
+		return
+	}
+	if metrics == nil {
+		metrics = []services.VariantMetrics{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(metrics)
+}
@@ -6,7 +6,7 @@ import (
 	"fmt"
 	"m/backend/config"
 	"m/backend/models"
-	"math/rand"
+	"m/backend/services"
 	"net/http"
 	"strconv"
 	"time"
@@ -28,8 +28,14 @@ func InitFixturesController(db *gorm.DB) {
 func ResetFixtures(w http.ResponseWriter, r *http.Request) {
 	modelsToDrop := []interface{}{
 		&models.User{}, &models.Profile{}, &models.Bio{}, &models.Preference{},
-		&models.Recommendation{}, &models.Connection{}, &models.Chat{},
-		&models.Message{}, &models.FakeUser{},
+		&models.Recommendation{}, &models.Connection{}, &models.Chat{}, &models.ChatParticipant{},
+		&models.Message{}, &models.MessageEdit{}, &models.HiddenMessage{}, &models.FakeUser{}, &models.UserSimilarity{},
+		&models.Experiment{}, &models.ExperimentVariant{}, &models.RecommendationImpression{},
+		&models.SavedLocation{}, &models.SavedSearch{}, &models.SavedSearchMatch{},
+		&models.Notification{}, &models.NearbyAlert{}, &models.DigestRun{},
+		&models.Question{}, &models.QuestionChoice{}, &models.QuestionAnswer{},
+		&models.Event{}, &models.EventAttendee{}, &models.EventMessage{},
+		&models.Community{}, &models.CommunityMember{}, &models.CommunityPost{},
 	}
 	if err := fixturesDB.Migrator().DropTable(modelsToDrop...); err != nil {
 		logrus.Errorf("ResetFixtures: error dropping tables: %v", err)
@@ -42,6 +48,9 @@ func ResetFixtures(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("Database migration error: %v", err), http.StatusInternalServerError)
 		return
 	}
+	if _, err := models.SetupFullTextSearch(fixturesDB, config.AppConfig.SearchLanguage); err != nil {
+		logrus.Errorf("ResetFixtures: full-text search setup error: %v", err)
+	}
 	logrus.Info("ResetFixtures: database migration completed successfully")
 	adminUUID, _ := uuid.Parse(config.AdminID)
 	var existing models.User
@@ -68,6 +77,7 @@ func ResetFixtures(w http.ResponseWriter, r *http.Request) {
 }
 
 // GenerateFixtures generates a number of fake users and fills the database with test data.
+// Pass ?seed=N to generate the same users again (e.g. to match an offline evaluation run).
 func GenerateFixtures(w http.ResponseWriter, r *http.Request) {
 	numUsers := 100
 	if param := r.URL.Query().Get("num"); param != "" {
@@ -77,7 +87,15 @@ func GenerateFixtures(w http.ResponseWriter, r *http.Request) {
 			logrus.Warnf("GenerateFixtures: invalid value for num parameter (%s), using %d", param, numUsers)
 		}
 	}
-	rand.Seed(time.Now().UnixNano())
+	seed := time.Now().UnixNano()
+	if param := r.URL.Query().Get("seed"); param != "" {
+		if n, err := strconv.ParseInt(param, 10, 64); err == nil {
+			seed = n
+		} else {
+			logrus.Warnf("GenerateFixtures: invalid value for seed parameter (%s), using %d", param, seed)
+		}
+	}
+	gen := services.NewFixtureGenerator(seed)
 	for i := 1; i <= numUsers; i++ {
 		email := fmt.Sprintf("user%d@example.com", i)
 		password := "password123"
@@ -87,28 +105,29 @@ func GenerateFixtures(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
-		latitude, longitude, city := randomLocationWithCity()
+		fp := gen.Profile()
 		profile := models.Profile{
 			UserID:    user.ID,
-			FirstName: randomFirstName(),
-			LastName:  randomLastName(),
+			FirstName: fp.FirstName,
+			LastName:  fp.LastName,
 			About:     "Test user for demonstration purposes.",
 			PhotoURL:  "/static/images/default.png",
 			Online:    false,
-			Latitude:  latitude,
-			Longitude: longitude,
-			City:      city,
+			Latitude:  fp.Latitude,
+			Longitude: fp.Longitude,
+			City:      fp.City,
 		}
 		if err := fixturesDB.Save(&profile).Error; err != nil {
 			logrus.Warnf("GenerateFixtures: error saving profile %s: %v", email, err)
 		}
 
 		bioUpdates := map[string]interface{}{
-			"interests": randomInterests(),
-			"hobbies":   randomHobbies(),
-			"music":     randomMusic(),
-			"food":      randomFood(),
-			"travel":    randomTravel(),
+			"interests":   fp.Interests,
+			"hobbies":     fp.Hobbies,
+			"music":       fp.Music,
+			"food":        fp.Food,
+			"travel":      fp.Travel,
+			"looking_for": fp.LookingFor,
 		}
 		if err := fixturesDB.Model(&models.Bio{}).
 			Where("user_id = ?", user.ID).
@@ -129,55 +148,9 @@ func GenerateFixtures(w http.ResponseWriter, r *http.Request) {
 
 		logrus.Debugf("GenerateFixtures: user %s created", email)
 	}
-	logrus.Infof("GenerateFixtures: %d fake users created", numUsers)
+	logrus.Infof("GenerateFixtures: %d fake users created (seed %d)", numUsers, seed)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
 		"message": fmt.Sprintf("%d fake users generated", numUsers),
 	})
 }
-
-func randomFirstName() string {
-	arr := []string{"Anna", "Jan", "Maria", "Alex", "Olga", "Danny", "Eelena", "Sergio", "Natalie", "Michael"}
-	return arr[rand.Intn(len(arr))]
-}
-func randomLastName() string {
-	arr := []string{"Agricola", "Petrov", "Sibelius", "Kuznets", "Saminen", "Gogol", "Novi", "Feducci", "Savolainen", "Gagarin"}
-	return arr[rand.Intn(len(arr))]
-}
-func randomInterests() string {
-	arr := []string{"movies", "sports", "music", "technology", "art", "travel", "literature", "photography"}
-	return arr[rand.Intn(len(arr))]
-}
-func randomHobbies() string {
-	arr := []string{"reading", "running", "drawing", "games", "cooking", "gardening", "swimming", "travel"}
-	return arr[rand.Intn(len(arr))]
-}
-func randomMusic() string {
-	arr := []string{"rock", "jazz", "classical", "pop", "hip-hop", "electronic", "blues"}
-	return arr[rand.Intn(len(arr))]
-}
-func randomFood() string {
-	arr := []string{"italian", "asian", "russian", "french", "mexican", "japanese"}
-	return arr[rand.Intn(len(arr))]
-}
-func randomTravel() string {
-	arr := []string{"beach vacation", "mountains", "cultural tours", "expeditions", "city trip"}
-	return arr[rand.Intn(len(arr))]
-}
-func randomLatitude() float64 {
-	return 41 + rand.Float64()*(82-41)
-}
-func randomLongitude() float64 {
-	return 19 + rand.Float64()*(169-19)
-}
-func randomLocationWithCity() (float64, float64, string) {
-	if rand.Float64() < 0.8 {
-		c := finnishCities[rand.Intn(len(finnishCities))]
-		jitterLat := c.Latitude + (rand.Float64()-0.5)*0.02
-		jitterLon := c.Longitude + (rand.Float64()-0.5)*0.02
-		return jitterLat, jitterLon, c.Name
-	}
-	lat := randomLatitude()
-	lon := randomLongitude()
-	return lat, lon, "Unknown"
-}
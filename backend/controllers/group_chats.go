@@ -0,0 +1,317 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"m/backend/config"
+	"m/backend/services"
+	"m/backend/sockets"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// group_chats.go - Group chats: creation from accepted connections, name and avatar,
+// participants with owner/admin roles, and per-member read positions. Changes are
+// pushed to the participants over WebSocket (see sockets.BroadcastChatEvent).
+
+// writeChatError maps chat service errors to HTTP responses.
+func writeChatError(w http.ResponseWriter, op string, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Chat not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidChat), errors.Is(err, services.ErrNotConnected):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrChatForbidden), errors.Is(err, services.ErrNotParticipant):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		logrus.Errorf("%s failed: %v", op, err)
+		http.Error(w, "Error processing chat", http.StatusInternalServerError)
+	}
+}
+
+// chatRequest returns the current user and the {chatId} of the request,
+// writing an error response when either is missing or malformed.
+func chatRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, uint, bool) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return uuid.Nil, 0, false
+	}
+	currentUserID, _ := uuid.Parse(userIDStr)
+	chatID, err := strconv.ParseUint(mux.Vars(r)["chatId"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid chat_id", http.StatusBadRequest)
+		return uuid.Nil, 0, false
+	}
+	return currentUserID, uint(chatID), true
+}
+
+// CreateGroupChat handles POST /chats/groups endpoint.
+// Body: {"name": "Hiking crew", "memberIds": ["<uuid>", ...]}. Members must be accepted
+// connections of the creator, who becomes the owner. Members get a chat_added event.
+func CreateGroupChat(w http.ResponseWriter, r *http.Request) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	currentUserID, _ := uuid.Parse(userIDStr)
+
+	var input struct {
+		Name      string      `json:"name"`
+		MemberIDs []uuid.UUID `json:"memberIds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	chat, err := chatsService.CreateGroup(currentUserID, input.Name, input.MemberIDs)
+	if err != nil {
+		writeChatError(w, "CreateGroupChat", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(chat)
+}
+
+// UpdateGroupChat handles PUT /chats/{chatId} endpoint (owner and admins).
+// Body: {"name": "...", "avatarUrl": "..."}; both optional.
+func UpdateGroupChat(w http.ResponseWriter, r *http.Request) {
+	currentUserID, chatID, ok := chatRequest(w, r)
+	if !ok {
+		return
+	}
+	var input struct {
+		Name      *string `json:"name"`
+		AvatarURL *string `json:"avatarUrl"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	chat, err := chatsService.UpdateGroup(chatID, currentUserID, input.Name, input.AvatarURL)
+	if err != nil {
+		writeChatError(w, "UpdateGroupChat", err)
+		return
+	}
+	go sockets.BroadcastChatEvent(chat.ID, map[string]interface{}{
+		"type":       "chat_updated",
+		"name":       chat.Name,
+		"avatar_url": chat.AvatarURL,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(chat)
+}
+
+// UploadChatAvatar handles POST /chats/{chatId}/avatar endpoint (owner and admins).
+// Multipart form with an "avatar" JPEG or PNG file (max 5MB), stored like profile photos.
+func UploadChatAvatar(w http.ResponseWriter, r *http.Request) {
+	currentUserID, chatID, ok := chatRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := r.ParseMultipartForm(5 << 20); err != nil {
+		http.Error(w, "Could not parse multipart form", http.StatusBadRequest)
+		return
+	}
+	file, fileHeader, err := r.FormFile("avatar")
+	if err != nil {
+		http.Error(w, "Error retrieving the file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	// Check the rights before writing anything to disk
+	if err := chatsService.CheckManager(chatID, currentUserID); err != nil {
+		writeChatError(w, "UploadChatAvatar", err)
+		return
+	}
+
+	head := make([]byte, 512)
+	if _, err := file.Read(head); err != nil {
+		http.Error(w, "Error reading file", http.StatusBadRequest)
+		return
+	}
+	if fileType := http.DetectContentType(head); fileType != "image/jpeg" && fileType != "image/png" {
+		http.Error(w, "Only JPEG and PNG images are allowed", http.StatusBadRequest)
+		return
+	}
+	file.Seek(0, 0)
+
+	uploadDir := config.AppConfig.MediaUploadDir
+	if err := os.MkdirAll(uploadDir, os.ModePerm); err != nil {
+		logrus.Errorf("UploadChatAvatar: error creating upload directory: %v", err)
+		http.Error(w, "Error creating upload directory", http.StatusInternalServerError)
+		return
+	}
+	newFileName := "chat_" + strconv.FormatUint(uint64(chatID), 10) + "_" +
+		strconv.FormatInt(time.Now().Unix(), 10) + filepath.Ext(fileHeader.Filename)
+	dst, err := os.Create(filepath.Join(uploadDir, newFileName))
+	if err != nil {
+		logrus.Errorf("UploadChatAvatar: error creating file: %v", err)
+		http.Error(w, "Error saving file", http.StatusInternalServerError)
+		return
+	}
+	defer dst.Close()
+	if _, err := io.Copy(dst, file); err != nil {
+		logrus.Errorf("UploadChatAvatar: error saving file: %v", err)
+		http.Error(w, "Error saving file", http.StatusInternalServerError)
+		return
+	}
+
+	avatarURL := "/static/images/" + newFileName
+	chat, err := chatsService.UpdateGroup(chatID, currentUserID, nil, &avatarURL)
+	if err != nil {
+		writeChatError(w, "UploadChatAvatar", err)
+		return
+	}
+	go sockets.BroadcastChatEvent(chat.ID, map[string]interface{}{
+		"type":       "chat_updated",
+		"name":       chat.Name,
+		"avatar_url": chat.AvatarURL,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(chat)
+}
+
+// GetChatParticipants handles GET /chats/{chatId}/participants endpoint.
+// Lists the participants with their roles and read positions. Participants only.
+func GetChatParticipants(w http.ResponseWriter, r *http.Request) {
+	currentUserID, chatID, ok := chatRequest(w, r)
+	if !ok {
+		return
+	}
+	list, err := chatsService.Participants(chatID, currentUserID)
+	if err != nil {
+		writeChatError(w, "GetChatParticipants", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// AddChatParticipants handles POST /chats/{chatId}/participants endpoint (owner and admins).
+// Body: {"userIds": ["<uuid>", ...]}, accepted connections of the current user.
+// Returns the users added.
+func AddChatParticipants(w http.ResponseWriter, r *http.Request) {
+	currentUserID, chatID, ok := chatRequest(w, r)
+	if !ok {
+		return
+	}
+	var input struct {
+		UserIDs []uuid.UUID `json:"userIds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	added, err := chatsService.AddParticipants(chatID, currentUserID, input.UserIDs)
+	if err != nil {
+		writeChatError(w, "AddChatParticipants", err)
+		return
+	}
+	if len(added) > 0 {
+		go sockets.BroadcastChatEvent(chatID, map[string]interface{}{
+			"type":     "participants_added",
+			"user_ids": added,
+			"by":       currentUserID.String(),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"added": added})
+}
+
+// UpdateChatParticipant handles PUT /chats/{chatId}/participants/{userId} endpoint (owner only).
+// Body: {"role": "admin"} or {"role": "member"}.
+func UpdateChatParticipant(w http.ResponseWriter, r *http.Request) {
+	currentUserID, chatID, ok := chatRequest(w, r)
+	if !ok {
+		return
+	}
+	userID, err := uuid.Parse(mux.Vars(r)["userId"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	var input struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := chatsService.SetParticipantRole(chatID, currentUserID, userID, input.Role); err != nil {
+		writeChatError(w, "UpdateChatParticipant", err)
+		return
+	}
+	go sockets.BroadcastChatEvent(chatID, map[string]interface{}{
+		"type":    "participant_role",
+		"user_id": userID.String(),
+		"role":    input.Role,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveChatParticipant handles DELETE /chats/{chatId}/participants/{userId} endpoint.
+// Participants leave with their own ID; the owner removes anyone, admins only members.
+func RemoveChatParticipant(w http.ResponseWriter, r *http.Request) {
+	currentUserID, chatID, ok := chatRequest(w, r)
+	if !ok {
+		return
+	}
+	userID, err := uuid.Parse(mux.Vars(r)["userId"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	if err := chatsService.RemoveParticipant(chatID, currentUserID, userID); err != nil {
+		writeChatError(w, "RemoveChatParticipant", err)
+		return
+	}
+	// Open windows of the removed user stop receiving the chat at once
+	sockets.UnsubscribeUser(chatID, userID)
+	go sockets.BroadcastChatEvent(chatID, map[string]interface{}{
+		"type":    "participant_removed",
+		"user_id": userID.String(),
+		"by":      currentUserID.String(),
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MarkChatRead handles POST /chats/{chatId}/read endpoint.
+// Body: {"messageId": 42} (optional, defaults to the newest message). Moves the current
+// user's read position forward; returns {"lastReadMessageId": 42}.
+func MarkChatRead(w http.ResponseWriter, r *http.Request) {
+	currentUserID, chatID, ok := chatRequest(w, r)
+	if !ok {
+		return
+	}
+	var input struct {
+		MessageID uint `json:"messageId"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	lastRead, readIDs, err := chatsService.MarkRead(chatID, currentUserID, input.MessageID)
+	if err != nil {
+		writeChatError(w, "MarkChatRead", err)
+		return
+	}
+	go sockets.BroadcastReadState(chatID, currentUserID, lastRead, readIDs)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]uint{"lastReadMessageId": lastRead})
+}
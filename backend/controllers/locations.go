@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"m/backend/services"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// locations.go - Handles HTTP endpoints for saved locations (home, work, trips).
+// The active location is used as the profile location for recommendations;
+// trips are switched automatically by the location job.
+
+var locationService *services.LocationService
+
+// InitLocationsController initializes the saved locations controller.
+// Should be called once at startup.
+func InitLocationsController(db *gorm.DB) {
+	locationService = services.NewLocationService(db)
+	logrus.Info("Locations controller initialized")
+}
+
+// currentUserAndPathID extracts the current user and the numeric {id} path parameter.
+// what names the resource in the error message.
+func currentUserAndPathID(w http.ResponseWriter, r *http.Request, what string) (uuid.UUID, uint, bool) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return uuid.Nil, 0, false
+	}
+	currentUserID, _ := uuid.Parse(userIDStr)
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid "+what+" ID", http.StatusBadRequest)
+		return uuid.Nil, 0, false
+	}
+	return currentUserID, uint(id), true
+}
+
+// writeLocationError maps location service errors to HTTP statuses.
+func writeLocationError(w http.ResponseWriter, op string, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Location not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidLocation):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrTooManyLocations), errors.Is(err, services.ErrLocationNotInRange):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		logrus.Errorf("%s failed: %v", op, err)
+		http.Error(w, "Error saving location", http.StatusInternalServerError)
+	}
+}
+
+// GetLocations handles GET /me/locations endpoint.
+// Returns the current user's saved locations; exactly one of them may be active.
+func GetLocations(w http.ResponseWriter, r *http.Request) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	currentUserID, _ := uuid.Parse(userIDStr)
+
+	list, err := locationService.List(currentUserID)
+	if err != nil {
+		logrus.Errorf("GetLocations failed: %v", err)
+		http.Error(w, "Error fetching locations", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// CreateLocation handles POST /me/locations endpoint.
+// Body: {"name", "city", "latitude", "longitude", "startsAt"?, "endsAt"?}; dates make it a trip.
+func CreateLocation(w http.ResponseWriter, r *http.Request) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	currentUserID, _ := uuid.Parse(userIDStr)
+
+	var input services.LocationInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	loc, err := locationService.Create(currentUserID, input)
+	if err != nil {
+		writeLocationError(w, "CreateLocation", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(loc)
+}
+
+// UpdateLocation handles PUT /me/locations/{id} endpoint.
+// Replaces name, coordinates and trip dates of a saved location.
+func UpdateLocation(w http.ResponseWriter, r *http.Request) {
+	currentUserID, id, ok := currentUserAndPathID(w, r, "location")
+	if !ok {
+		return
+	}
+	var input services.LocationInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	loc, err := locationService.Update(currentUserID, id, input)
+	if err != nil {
+		writeLocationError(w, "UpdateLocation", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(loc)
+}
+
+// DeleteLocation handles DELETE /me/locations/{id} endpoint.
+// Returns 204 No Content on success.
+func DeleteLocation(w http.ResponseWriter, r *http.Request) {
+	currentUserID, id, ok := currentUserAndPathID(w, r, "location")
+	if !ok {
+		return
+	}
+	if err := locationService.Delete(currentUserID, id); err != nil {
+		writeLocationError(w, "DeleteLocation", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ActivateLocation handles POST /me/locations/{id}/activate endpoint.
+// Makes the location the one used for recommendations (trips only while in progress).
+func ActivateLocation(w http.ResponseWriter, r *http.Request) {
+	currentUserID, id, ok := currentUserAndPathID(w, r, "location")
+	if !ok {
+		return
+	}
+	loc, err := locationService.Activate(currentUserID, id)
+	if err != nil {
+		writeLocationError(w, "ActivateLocation", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(loc)
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"m/backend/services"
+	"m/backend/sockets"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// message_edits.go - Editing and deleting chat messages. Changes are pushed to the
+// open chat windows as message_edited and message_deleted WebSocket events.
+
+// messageRequest returns the current user, the {chatId} and the {id} message ID of the
+// request, writing an error response when any is missing or malformed.
+func messageRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, uint, uint, bool) {
+	currentUserID, chatID, ok := chatRequest(w, r)
+	if !ok {
+		return uuid.Nil, 0, 0, false
+	}
+	messageID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return uuid.Nil, 0, 0, false
+	}
+	return currentUserID, chatID, uint(messageID), true
+}
+
+// writeMessageError maps message edit errors to HTTP responses.
+func writeMessageError(w http.ResponseWriter, op string, err error) {
+	if errors.Is(err, services.ErrMessageLocked) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	writeChatError(w, op, err)
+}
+
+// EditMessage handles PATCH /chats/{chatId}/messages/{id} endpoint.
+// Body: {"content": "..."}. Only the sender, within MESSAGE_EDIT_WINDOW of sending;
+// the previous text is kept in the edit history.
+func EditMessage(w http.ResponseWriter, r *http.Request) {
+	currentUserID, chatID, messageID, ok := messageRequest(w, r)
+	if !ok {
+		return
+	}
+	var input struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	msg, err := chatsService.EditMessage(chatID, messageID, currentUserID, input.Content)
+	if err != nil {
+		writeMessageError(w, "EditMessage", err)
+		return
+	}
+	go sockets.BroadcastMessageEdited(*msg)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(msg)
+}
+
+// DeleteMessage handles DELETE /chats/{chatId}/messages/{id} endpoint.
+// Query: for=me (default) hides the message for the current user only; for=everyone
+// deletes it for all participants (sender only, within MESSAGE_EDIT_WINDOW).
+func DeleteMessage(w http.ResponseWriter, r *http.Request) {
+	currentUserID, chatID, messageID, ok := messageRequest(w, r)
+	if !ok {
+		return
+	}
+	scope := r.URL.Query().Get("for")
+	if scope != "" && scope != "me" && scope != "everyone" {
+		http.Error(w, "for must be me or everyone", http.StatusBadRequest)
+		return
+	}
+	forEveryone := scope == "everyone"
+	msg, err := chatsService.DeleteMessage(chatID, messageID, currentUserID, forEveryone)
+	if err != nil {
+		writeMessageError(w, "DeleteMessage", err)
+		return
+	}
+	go sockets.BroadcastMessageDeleted(*msg, currentUserID, forEveryone)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetMessageHistory handles GET /chats/{chatId}/messages/{id}/history endpoint.
+// Returns the previous versions of an edited message, oldest first.
+func GetMessageHistory(w http.ResponseWriter, r *http.Request) {
+	currentUserID, chatID, messageID, ok := messageRequest(w, r)
+	if !ok {
+		return
+	}
+	edits, err := chatsService.MessageEdits(chatID, messageID, currentUserID)
+	if err != nil {
+		writeMessageError(w, "GetMessageHistory", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(edits)
+}
@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"m/backend/services"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var notificationService *services.NotificationService
+
+// InitNotificationsController initializes the notifications controller.
+func InitNotificationsController(db *gorm.DB) {
+	notificationService = services.NewNotificationService(db)
+	logrus.Info("Notifications controller initialized")
+}
+
+// GetNotifications handles GET /notifications endpoint.
+// Returns events stored while the user was offline (same JSON as the WebSocket events)
+// and marks them delivered.
+func GetNotifications(w http.ResponseWriter, r *http.Request) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	currentUserID, _ := uuid.Parse(userIDStr)
+
+	events, err := notificationService.TakePending(currentUserID)
+	if err != nil {
+		logrus.Errorf("GetNotifications failed: %v", err)
+		http.Error(w, "Error fetching notifications", http.StatusInternalServerError)
+		return
+	}
+	if events == nil {
+		events = []json.RawMessage{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
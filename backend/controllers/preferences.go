@@ -2,9 +2,11 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"m/backend/models"
+	"m/backend/services"
 
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
@@ -14,7 +16,7 @@ import (
 var preferencesDB *gorm.DB
 
 // preferences.go - Handles HTTP endpoints for user search and recommendation preferences.
-// Provides endpoints to get and update user preferences (e.g., max search radius).
+// Provides endpoints to get and update user preferences (e.g., max search radius, field weights).
 // Automatically creates default preferences if not found.
 
 // InitPreferencesController initializes the preferences controller with a database connection.
@@ -64,8 +66,31 @@ func GetPreferences(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(pref)
 }
 
+// maxDeclineTTLDays limits declineTtlDays to ten years.
+const maxDeclineTTLDays = 3650
+
+// applyDeclineTTL sets the per-user decline expiry when it is present in the request.
+// A negative value resets it to the global DECLINE_TTL_DAYS, 0 disables expiry.
+func applyDeclineTTL(pref *models.Preference, days *int) {
+	if days == nil {
+		return
+	}
+	if *days < 0 {
+		pref.DeclineTTLDays = nil
+		return
+	}
+	v := *days
+	pref.DeclineTTLDays = &v
+}
+
 // UpdatePreferences handles PUT /me/preferences endpoint.
-// Updates the current user's preferences (e.g., max search radius).
+// Updates the fields present in the request: maxRadius, declineTtlDays, rankingStrategy and
+// fieldWeights ({"Interests": 3, "Music": 0.5, ...}, 0-5, replaces all weights; missing fields
+// count as 1). The legacy priorityInterests..priorityTravel flags map to a weight of 2.
+// digestOptOut / digestEmailOptOut turn off the daily digest or only its email.
+// dealbreakers ({"Food": ["meat"], ...}) replaces all dealbreakers; mutualMatching also
+// requires candidates' radius and dealbreakers to accept the user.
+// An empty rankingStrategy resets it to the server default.
 // If preferences do not exist, creates them. Handles DB errors and returns updated preferences as JSON.
 func UpdatePreferences(w http.ResponseWriter, r *http.Request) {
 	userIDStr, ok := r.Context().Value("userID").(string)
@@ -80,39 +105,139 @@ func UpdatePreferences(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		MaxRadius float64 `json:"maxRadius"`
+		MaxRadius         *float64            `json:"maxRadius"`
+		DeclineTTLDays    *int                `json:"declineTtlDays"`
+		RankingStrategy   *string             `json:"rankingStrategy"`
+		FieldWeights      map[string]float64  `json:"fieldWeights"`
+		PriorityInterests *bool               `json:"priorityInterests"`
+		PriorityHobbies   *bool               `json:"priorityHobbies"`
+		PriorityMusic     *bool               `json:"priorityMusic"`
+		PriorityFood      *bool               `json:"priorityFood"`
+		PriorityTravel    *bool               `json:"priorityTravel"`
+		DigestOptOut      *bool               `json:"digestOptOut"`
+		DigestEmailOptOut *bool               `json:"digestEmailOptOut"`
+		Dealbreakers      map[string][]string `json:"dealbreakers"`
+		MutualMatching    *bool               `json:"mutualMatching"`
+		MinAge            *int                `json:"minAge"`
+		MaxAge            *int                `json:"maxAge"`
+		Genders           []string            `json:"genders"`
+		Languages         []string            `json:"languages"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if req.MaxRadius != nil && *req.MaxRadius < 0 {
+		http.Error(w, "maxRadius must not be negative", http.StatusBadRequest)
+		return
+	}
+	if req.DeclineTTLDays != nil && *req.DeclineTTLDays > maxDeclineTTLDays {
+		http.Error(w, "declineTtlDays is too large", http.StatusBadRequest)
+		return
+	}
+	if req.RankingStrategy != nil && *req.RankingStrategy != "" && !services.IsRankingStrategy(*req.RankingStrategy) {
+		http.Error(w, "rankingStrategy must be distance, score or blend", http.StatusBadRequest)
+		return
+	}
+	var weights models.FieldWeights
+	if req.FieldWeights != nil {
+		weights, err = recommendationService.NormalizeFieldWeights(req.FieldWeights)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	var dealbreakers models.Dealbreakers
+	if req.Dealbreakers != nil {
+		dealbreakers, err = services.NormalizeDealbreakers(req.Dealbreakers)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	var genders, languages models.StringList
+	if req.Genders != nil {
+		if genders, err = services.NormalizeGenders(req.Genders); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Languages != nil {
+		if languages, err = services.NormalizeLanguages(req.Languages); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
 
 	var pref models.Preference
 	err = preferencesDB.
 		Where("user_id = ?", uid).
 		First(&pref).Error
-
-	if err == gorm.ErrRecordNotFound {
-		pref = models.Preference{
-			UserID:    uid,
-			MaxRadius: req.MaxRadius,
-		}
-		if err := preferencesDB.Create(&pref).Error; err != nil {
-			logrus.Errorf("UpdatePreferences: error creating preferences: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
+	created := errors.Is(err, gorm.ErrRecordNotFound)
+	if created {
+		pref = models.Preference{UserID: uid}
 	} else if err != nil {
 		logrus.Errorf("UpdatePreferences: error fetching preferences: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
+	}
+
+	if req.MaxRadius != nil {
+		pref.MaxRadius = *req.MaxRadius
+	}
+	applyDeclineTTL(&pref, req.DeclineTTLDays)
+	if req.RankingStrategy != nil {
+		pref.RankingStrategy = *req.RankingStrategy
+	}
+	if req.FieldWeights != nil {
+		pref.FieldWeights = weights
+	}
+	if req.DigestOptOut != nil {
+		pref.DigestOptOut = *req.DigestOptOut
+	}
+	if req.DigestEmailOptOut != nil {
+		pref.DigestEmailOptOut = *req.DigestEmailOptOut
+	}
+	if req.Dealbreakers != nil {
+		pref.Dealbreakers = dealbreakers
+	}
+	if req.MutualMatching != nil {
+		pref.MutualMatching = *req.MutualMatching
+	}
+	if req.MinAge != nil {
+		pref.MinAge = *req.MinAge
+	}
+	if req.MaxAge != nil {
+		pref.MaxAge = *req.MaxAge
+	}
+	// Checked on the merged range so one side can be changed at a time
+	if err := services.ValidateAgeRange(pref.MinAge, pref.MaxAge); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Genders != nil {
+		pref.Genders = genders
+	}
+	if req.Languages != nil {
+		pref.Languages = languages
+	}
+	pref.FieldWeights = services.ApplyPriorityFlags(pref.FieldWeights, map[string]*bool{
+		"Interests": req.PriorityInterests,
+		"Hobbies":   req.PriorityHobbies,
+		"Music":     req.PriorityMusic,
+		"Food":      req.PriorityFood,
+		"Travel":    req.PriorityTravel,
+	})
+
+	if created {
+		err = preferencesDB.Create(&pref).Error
 	} else {
-		pref.MaxRadius = req.MaxRadius
-		if err := preferencesDB.Save(&pref).Error; err != nil {
-			logrus.Errorf("UpdatePreferences: error saving preferences: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
+		err = preferencesDB.Save(&pref).Error
+	}
+	if err != nil {
+		logrus.Errorf("UpdatePreferences: error saving preferences: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -37,10 +37,10 @@ func (pc *PresenceController) GetMultipleOnlineStatus(w http.ResponseWriter, r *
 		return
 	}
 	ids := strings.Split(qs, ",")
-	result := make(map[string]bool, len(ids))
-	for _, id := range ids {
-		online, _ := pc.svc.IsOnline(id)
-		result[id] = online
+	result, err := pc.svc.OnlineMany(ids)
+	if err != nil {
+		http.Error(w, "error checking presence", http.StatusInternalServerError)
+		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(result)
@@ -13,6 +13,7 @@ import (
 
 	"m/backend/config"
 	"m/backend/models"
+	"m/backend/services"
 	"m/backend/utils"
 
 	"github.com/google/uuid"
@@ -52,6 +53,12 @@ func UpdateCurrentUserProfile(w http.ResponseWriter, r *http.Request) {
 		City      string  `json:"city"`
 		Latitude  float64 `json:"latitude"`
 		Longitude float64 `json:"longitude"`
+		// Demographics are optional; omitted fields are left unchanged
+		Birthdate *string  `json:"birthdate"`
+		HideAge   *bool    `json:"hideAge"`
+		Gender    *string  `json:"gender"`
+		Languages []string `json:"languages"`
+		Pronouns  *string  `json:"pronouns"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
@@ -76,6 +83,11 @@ func UpdateCurrentUserProfile(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "City cannot be empty", http.StatusBadRequest)
 		return
 	}
+	demographics, err := parseDemographics(reqBody.Birthdate, reqBody.Gender, reqBody.Languages, reqBody.Pronouns)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Try to find existing profile for user
 	var profile models.Profile
@@ -92,6 +104,7 @@ func UpdateCurrentUserProfile(w http.ResponseWriter, r *http.Request) {
 				Latitude:  reqBody.Latitude,
 				Longitude: reqBody.Longitude,
 			}
+			demographics.apply(&profile, reqBody.HideAge)
 			if err := profileDB.Create(&profile).Error; err != nil {
 				logrus.Errorf("UpdateCurrentUserProfile: error creating profile for user %s: %v", currentUserID, err)
 				http.Error(w, "Error creating profile", http.StatusInternalServerError)
@@ -113,6 +126,7 @@ func UpdateCurrentUserProfile(w http.ResponseWriter, r *http.Request) {
 			profile.Latitude = reqBody.Latitude
 			profile.Longitude = reqBody.Longitude
 		}
+		demographics.apply(&profile, reqBody.HideAge)
 
 		if err := profileDB.Save(&profile).Error; err != nil {
 			logrus.Errorf("UpdateCurrentUserProfile: error updating profile for user %s: %v", currentUserID, err)
@@ -136,10 +150,75 @@ func UpdateCurrentUserProfile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	logrus.Infof("Profile for user %s updated successfully", currentUserID)
+	// Tell people nearby about a newly completed profile
+	services.ProfileChanged(currentUserID)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(profile)
 }
 
+// profileDemographics holds the validated demographic fields of a profile update.
+// Fields that were not in the request are left unset.
+type profileDemographics struct {
+	birthdate    *time.Time
+	hasBirthdate bool
+	gender       *string
+	languages    models.StringList
+	hasLanguages bool
+	pronouns     *string
+}
+
+// parseDemographics validates the optional demographic fields of a profile update.
+func parseDemographics(birthdate, gender *string, languages []string, pronouns *string) (profileDemographics, error) {
+	var d profileDemographics
+	var err error
+	if birthdate != nil {
+		if d.birthdate, err = services.ParseBirthdate(*birthdate, time.Now()); err != nil {
+			return d, err
+		}
+		d.hasBirthdate = true
+	}
+	if gender != nil {
+		g, err := services.NormalizeGender(*gender)
+		if err != nil {
+			return d, err
+		}
+		d.gender = &g
+	}
+	if languages != nil {
+		if d.languages, err = services.NormalizeLanguages(languages); err != nil {
+			return d, err
+		}
+		d.hasLanguages = true
+	}
+	if pronouns != nil {
+		p, err := services.ValidatePronouns(*pronouns)
+		if err != nil {
+			return d, err
+		}
+		d.pronouns = &p
+	}
+	return d, nil
+}
+
+// apply copies the fields present in the request to the profile.
+func (d profileDemographics) apply(profile *models.Profile, hideAge *bool) {
+	if d.hasBirthdate {
+		profile.Birthdate = d.birthdate
+	}
+	if hideAge != nil {
+		profile.HideAge = *hideAge
+	}
+	if d.gender != nil {
+		profile.Gender = *d.gender
+	}
+	if d.hasLanguages {
+		profile.Languages = d.languages
+	}
+	if d.pronouns != nil {
+		profile.Pronouns = *d.pronouns
+	}
+}
+
 func UpdateCurrentUserLocation(w http.ResponseWriter, r *http.Request) {
 	userIDStr, ok := r.Context().Value("userID").(string)
 	if !ok {
@@ -201,6 +280,8 @@ func UpdateCurrentUserLocation(w http.ResponseWriter, r *http.Request) {
 		}
 		logrus.Infof("earth_loc updated for user %s", currentUserID)
 	}
+	// Tell people whose radius the user moved into
+	services.ProfileChanged(currentUserID)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(profile)
@@ -230,11 +311,11 @@ func UpdateCurrentUserBio(w http.ResponseWriter, r *http.Request) {
 		Food              string `json:"food"`
 		Travel            string `json:"travel"`
 		LookingFor        string `json:"lookingFor"`
-		PriorityInterests bool   `json:"priorityInterests"`
-		PriorityHobbies   bool   `json:"priorityHobbies"`
-		PriorityMusic     bool   `json:"priorityMusic"`
-		PriorityFood      bool   `json:"priorityFood"`
-		PriorityTravel    bool   `json:"priorityTravel"`
+		PriorityInterests *bool  `json:"priorityInterests"`
+		PriorityHobbies   *bool  `json:"priorityHobbies"`
+		PriorityMusic     *bool  `json:"priorityMusic"`
+		PriorityFood      *bool  `json:"priorityFood"`
+		PriorityTravel    *bool  `json:"priorityTravel"`
 	}
 
 	logrus.Infof("UpdateCurrentUserBio: reqBody: %+v", reqBody)
@@ -267,34 +348,36 @@ func UpdateCurrentUserBio(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Legacy priority flags are stored as field weights (see PUT /me/preferences)
+	priorityFlags := map[string]*bool{
+		"Interests": reqBody.PriorityInterests,
+		"Hobbies":   reqBody.PriorityHobbies,
+		"Music":     reqBody.PriorityMusic,
+		"Food":      reqBody.PriorityFood,
+		"Travel":    reqBody.PriorityTravel,
+	}
+
 	// Find or create user preferences
 	var pref models.Preference
 	if err := profileDB.
 		Where("user_id = ?", currentUserID).
 		First(&pref).Error; err != nil {
 		pref = models.Preference{
-			UserID:            currentUserID,
-			PriorityInterests: reqBody.PriorityInterests,
-			PriorityHobbies:   reqBody.PriorityHobbies,
-			PriorityMusic:     reqBody.PriorityMusic,
-			PriorityFood:      reqBody.PriorityFood,
-			PriorityTravel:    reqBody.PriorityTravel,
+			UserID:       currentUserID,
+			FieldWeights: services.ApplyPriorityFlags(nil, priorityFlags),
 		}
 		if err := profileDB.Create(&pref).Error; err != nil {
 			logrus.Errorf("UpdateCurrentUserBio: error creating preferences for user %s: %v", currentUserID, err)
 		}
 	} else {
-		pref.PriorityInterests = reqBody.PriorityInterests
-		pref.PriorityHobbies = reqBody.PriorityHobbies
-		pref.PriorityMusic = reqBody.PriorityMusic
-		pref.PriorityFood = reqBody.PriorityFood
-		pref.PriorityTravel = reqBody.PriorityTravel
+		pref.FieldWeights = services.ApplyPriorityFlags(pref.FieldWeights, priorityFlags)
 		if err := profileDB.Save(&pref).Error; err != nil {
 			logrus.Errorf("UpdateCurrentUserBio: error updating preferences for user %s: %v", currentUserID, err)
 		}
 	}
 
 	logrus.Infof("Bio for user %s updated successfully", currentUserID)
+	services.ProfileChanged(currentUserID)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"bio":         bio,
@@ -446,3 +529,33 @@ func DeleteUserPhoto(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(profile)
 }
+
+// GetProfileCompleteness handles GET /me/profile/completeness endpoint.
+// Returns the completeness percentage and the missing fields; profiles missing
+// required fields get cold-start recommendations.
+func GetProfileCompleteness(w http.ResponseWriter, r *http.Request) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	currentUserID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		http.Error(w, "Invalid userID", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	if err := profileDB.Preload("Profile").Preload("Bio").First(&user, "id = ?", currentUserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		logrus.Errorf("GetProfileCompleteness: error loading user %s: %v", currentUserID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(services.GetProfileStatus(user))
+}
@@ -0,0 +1,249 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"m/backend/models"
+	"m/backend/services"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// questionnaire.go - Compatibility questionnaire. Admins manage the questions, users
+// answer them, and the answers of two users give their match percentage.
+
+var questionnaireService *services.QuestionnaireService
+
+// InitQuestionnaireController initializes the questionnaire service for this controller.
+// Should be called once at startup.
+func InitQuestionnaireController(db *gorm.DB) {
+	questionnaireService = services.NewQuestionnaireService(db)
+	logrus.Info("Questionnaire controller initialized")
+}
+
+// CreateQuestion handles POST /admin/questions endpoint.
+// Body: {"text": "Do you want children?", "choices": ["Yes", "No", "Not sure"]}.
+func CreateQuestion(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Text    string   `json:"text"`
+		Choices []string `json:"choices"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	q := models.Question{Text: input.Text, Active: true}
+	for _, c := range input.Choices {
+		q.Choices = append(q.Choices, models.QuestionChoice{Text: c})
+	}
+	if err := questionnaireService.CreateQuestion(&q); err != nil {
+		if errors.Is(err, services.ErrInvalidQuestion) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logrus.Errorf("CreateQuestion failed: %v", err)
+		http.Error(w, "Error creating question", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(q)
+}
+
+// GetAdminQuestions handles GET /admin/questions endpoint.
+// Returns all questions, including inactive ones.
+func GetAdminQuestions(w http.ResponseWriter, r *http.Request) {
+	list, err := questionnaireService.ListQuestions(true)
+	if err != nil {
+		logrus.Errorf("GetAdminQuestions failed: %v", err)
+		http.Error(w, "Error fetching questions", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// UpdateQuestion handles PUT /admin/questions/{id} endpoint.
+// Body: {"text": "...", "active": false}; both optional. Choices cannot be changed.
+func UpdateQuestion(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid question ID", http.StatusBadRequest)
+		return
+	}
+	var input struct {
+		Text   *string `json:"text"`
+		Active *bool   `json:"active"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	q, err := questionnaireService.UpdateQuestion(uint(id), input.Text, input.Active)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Question not found", http.StatusNotFound)
+		return
+	} else if errors.Is(err, services.ErrInvalidQuestion) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		logrus.Errorf("UpdateQuestion failed: %v", err)
+		http.Error(w, "Error updating question", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(q)
+}
+
+// GetQuestions handles GET /questions endpoint.
+// Returns the active questions with the current user's answers (null when unanswered).
+func GetQuestions(w http.ResponseWriter, r *http.Request) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	userID, _ := uuid.Parse(userIDStr)
+	list, err := questionnaireService.QuestionsFor(userID)
+	if err != nil {
+		logrus.Errorf("GetQuestions failed for %s: %v", userID, err)
+		http.Error(w, "Error fetching questions", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// AnswerQuestion handles PUT /questions/{id}/answer endpoint.
+// Body: {"choiceId": 3, "acceptable": [3, 4], "importance": "very"}.
+// An empty acceptable list accepts any answer; importance defaults to "somewhat".
+func AnswerQuestion(w http.ResponseWriter, r *http.Request) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	userID, _ := uuid.Parse(userIDStr)
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid question ID", http.StatusBadRequest)
+		return
+	}
+	var input struct {
+		ChoiceID   uint   `json:"choiceId"`
+		Acceptable []uint `json:"acceptable"`
+		Importance string `json:"importance"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	answer, err := questionnaireService.Answer(userID, uint(id), input.ChoiceID, input.Acceptable, input.Importance)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Question not found", http.StatusNotFound)
+		return
+	} else if errors.Is(err, services.ErrInvalidAnswer) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		logrus.Errorf("AnswerQuestion failed for %s: %v", userID, err)
+		http.Error(w, "Error saving answer", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(answer)
+}
+
+// DeleteAnswer handles DELETE /questions/{id}/answer endpoint.
+func DeleteAnswer(w http.ResponseWriter, r *http.Request) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	userID, _ := uuid.Parse(userIDStr)
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid question ID", http.StatusBadRequest)
+		return
+	}
+	err = questionnaireService.DeleteAnswer(userID, uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Answer not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logrus.Errorf("DeleteAnswer failed for %s: %v", userID, err)
+		http.Error(w, "Error deleting answer", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetCompatibility handles GET /users/{id}/compatibility endpoint.
+// Returns {"matchPercent": 87, "score": 0.87, "commonQuestions": 12}; matchPercent is
+// null when the users have too few answers in common. Requires access check.
+func GetCompatibility(w http.ResponseWriter, r *http.Request) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	currentUserID, _ := uuid.Parse(userIDStr)
+	otherID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid requested user ID", http.StatusBadRequest)
+		return
+	}
+	allowed, err := userHasAccess(currentUserID, otherID)
+	if err != nil {
+		logrus.Errorf("GetCompatibility: error checking access: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !allowed {
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+
+	c, err := questionnaireService.Compatibility(currentUserID, otherID)
+	if err != nil {
+		logrus.Errorf("GetCompatibility failed for %s/%s: %v", currentUserID, otherID, err)
+		http.Error(w, "Error computing compatibility", http.StatusInternalServerError)
+		return
+	}
+	response := map[string]interface{}{"matchPercent": nil}
+	if c != nil {
+		response["matchPercent"] = c.Percent
+		response["score"] = c.Score
+		response["commonQuestions"] = c.CommonQuestions
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// matchPercent returns the questionnaire match percentage of two users for profile
+// responses, nil when unknown. Errors are logged, not returned: the profile is still served.
+func matchPercent(userID, otherID uuid.UUID) interface{} {
+	if userID == otherID {
+		return nil
+	}
+	c, err := questionnaireService.Compatibility(userID, otherID)
+	if err != nil {
+		logrus.Errorf("matchPercent failed for %s/%s: %v", userID, otherID, err)
+		return nil
+	}
+	if c == nil {
+		return nil
+	}
+	return c.Percent
+}
@@ -2,14 +2,19 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"m/backend/config"
 	"m/backend/models"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 	"unicode"
 
 	"m/backend/services"
+	"m/backend/sockets"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -27,23 +32,156 @@ func containsUUID(arr []uuid.UUID, x uuid.UUID) bool {
 }
 
 type RecommendationOutput struct {
-	ID       uuid.UUID `json:"id"`
-	Distance float64   `json:"distance"`
-	Score    float64   `json:"score"`
-	//Online   bool      `json:"online"`
+	ID          uuid.UUID                           `json:"id"`
+	Distance    float64                             `json:"distance"`
+	Score       float64                             `json:"score"`
+	Online      bool                                `json:"online"`
+	Explanation *services.RecommendationExplanation `json:"explanation,omitempty"`
+}
+
+// RecommendationPageOutput is the paged response of GET /recommendations.
+// Pass NextCursor back as ?cursor= to load the next page of the same ranking.
+type RecommendationPageOutput struct {
+	SnapshotID string                 `json:"snapshotId"`
+	Items      []RecommendationOutput `json:"items"`
+	NextCursor string                 `json:"nextCursor,omitempty"`
+	Total      int                    `json:"total"`
+	// IncompleteProfile is set on the first page of cold-start results.
+	IncompleteProfile *services.ErrIncompleteProfile `json:"incompleteProfile,omitempty"`
+}
+
+// toRecommendationOutputs converts service results to API output, optionally with explanations.
+func toRecommendationOutputs(recs []services.RecommendationWithDistance, explain bool) []RecommendationOutput {
+	out := make([]RecommendationOutput, len(recs))
+	for i, rec := range recs {
+		out[i] = RecommendationOutput{
+			ID:       rec.UserID,
+			Distance: rec.Distance,
+			Score:    rec.Score,
+			Online:   rec.Online,
+		}
+		if explain {
+			out[i].Explanation = rec.Explanation
+		}
+	}
+	return out
+}
+
+// writeRecommendationPage encodes a page of recommendations as JSON.
+func writeRecommendationPage(w http.ResponseWriter, page *services.RecommendationPage, explain bool) {
+	writeRecommendationPageWith(w, page, explain, nil)
+}
+
+// writeRecommendationPageWith encodes a page of recommendations, marking cold-start
+// results with the missing profile fields.
+func writeRecommendationPageWith(w http.ResponseWriter, page *services.RecommendationPage, explain bool,
+	incomplete *services.ErrIncompleteProfile) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RecommendationPageOutput{
+		SnapshotID:        page.SnapshotID,
+		Items:             toRecommendationOutputs(page.Items, explain),
+		NextCursor:        page.NextCursor,
+		Total:             page.Total,
+		IncompleteProfile: incomplete,
+	})
+}
+
+// writeIncompleteProfile responds 422 with the fields missing for full recommendations.
+func writeIncompleteProfile(w http.ResponseWriter, incomplete *services.ErrIncompleteProfile) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":         incomplete.Error(),
+		"missingFields": incomplete.MissingFields,
+		"completeness":  incomplete.Completeness,
+	})
+}
+
+// parsePageSize reads ?pageSize=, defaulting to RECOMMENDATIONS_LIMIT and
+// clamping to RECOMMENDATIONS_MAX_PAGE_SIZE.
+func parsePageSize(q string) (int, error) {
+	if q == "" {
+		return config.AppConfig.RecommendationsLimit, nil
+	}
+	n, err := strconv.Atoi(q)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid pageSize %q", q)
+	}
+	if n > config.AppConfig.RecommendationsMaxPageSize {
+		n = config.AppConfig.RecommendationsMaxPageSize
+	}
+	return n, nil
 }
 
 var recommendationService *services.RecommendationService
 var presenceService *services.PresenceService
+var collaborativeService *services.CollaborativeService
+var recommendationInitOnce sync.Once
 
-// InitRecommendationControllerService initializes the recommendation and presence services for this controller.
-// Should be called once at startup.
+// InitRecommendationControllerService initializes the recommendation and presence services for this controller
+// and applies the ranking settings of the config to the services package.
+// Must run before any background job ranks; later calls do nothing, so the settings are
+// never rewritten while jobs read them.
 func InitRecommendationControllerService(db *gorm.DB, ps *services.PresenceService) {
+	recommendationInitOnce.Do(func() { initRecommendationService(db, ps) })
+}
+
+// RecommendationService returns the configured recommendation service, for background
+// jobs that rank like the API does. Nil before InitRecommendationControllerService.
+func RecommendationService() *services.RecommendationService {
+	return recommendationService
+}
+
+func initRecommendationService(db *gorm.DB, ps *services.PresenceService) {
 	recommendationService = services.NewRecommendationService(db, nil)
-	presenceService = ps // ✅ added
+	recommendationService.CandidateLimit = config.AppConfig.RecommendationsCandidateLimit
+	services.SetSnapshotTTL(time.Duration(config.AppConfig.RecommendationsSnapshotTTL) * time.Minute)
+	services.SetDeclineTTLDays(config.AppConfig.DeclineTTLDays)
+	services.SetCollaborativeBlendWeight(config.AppConfig.CFBlendWeight)
+	recommendationService.SortOrder = config.AppConfig.RankingStrategy
+	services.SetRankingBlend(config.AppConfig.RankingBlendScoreWeight, config.AppConfig.RankingDistanceDecayKm)
+	services.SetDiversityLambda(config.AppConfig.DiversityLambda)
+	services.SetExposureCaps(config.AppConfig.ExposureDailyCap, config.AppConfig.PendingRequestCap)
+	services.SetActivityHalfLife(config.AppConfig.ActivityHalfLifeDays)
+	services.SetCompatibilityBlendWeight(config.AppConfig.QuestionnaireBlendWeight)
+	services.SetCommunityBoost(config.AppConfig.CommunityBoost)
+	recommendationService.Presence = ps
+	collaborativeService = services.NewCollaborativeService(db, config.AppConfig.CFNeighbors)
+	presenceService = ps
 	logrus.Info("Recommendations controller initialized")
 }
 
+// serviceForUser returns the recommendation service to rank with for the user:
+// the shared one, or a copy with the user's experiment variant applied.
+// Sets X-Experiment-Variant so clients and logs can attribute results.
+func serviceForUser(w http.ResponseWriter, userID uuid.UUID) (*services.RecommendationService, *services.Assignment) {
+	if experimentService == nil {
+		return recommendationService, nil
+	}
+	a, err := experimentService.Assign(userID)
+	if err != nil {
+		logrus.Errorf("GetRecommendations: experiment assignment failed for %s: %v", userID, err)
+		return recommendationService, nil
+	}
+	if a == nil {
+		return recommendationService, nil
+	}
+	w.Header().Set("X-Experiment-Variant", a.Experiment+"/"+a.Variant)
+	return recommendationService.WithVariant(a.Config), a
+}
+
+// recordImpressions stores served recommendations in the background.
+func recordImpressions(userID uuid.UUID, a *services.Assignment, recs []services.RecommendationWithDistance, offset int) {
+	if experimentService == nil || len(recs) == 0 {
+		return
+	}
+	go func() {
+		if err := experimentService.RecordImpressions(userID, a, recs, offset); err != nil {
+			logrus.Errorf("GetRecommendations: recording impressions for %s failed: %v", userID, err)
+		}
+	}()
+}
+
 // parseMode parses the recommendation mode from query string.
 // Returns "affinity" (default) or "desire". Returns error for invalid values.
 func parseMode(q string) (string, error) {
@@ -61,7 +199,19 @@ func parseMode(q string) (string, error) {
 // Returns a list of recommended users for the current user, with optional distance and score.
 // Supports two modes: profile-based (uses saved user preferences) and custom-filtered (uses query params).
 // Filters out users with pending/declined connections. Adds online status via presenceService.
-// Handles errors and incomplete profiles gracefully (returns empty array for known validation errors).
+// With explain=true each item includes the score explanation (matched fields, weights, distance band).
+// With paged=true the full ranking is stored as a snapshot and the first page is returned
+// with a cursor; cursor=... returns following pages of the same snapshot (410 once it expires).
+// Snapshots are kept in process memory: cursors need the same instance and die on restart.
+// If an experiment is active, ranking uses the user's variant; served items are recorded as impressions.
+// ranking=distance|score|blend overrides the user's saved ranking strategy for this request.
+// diversity=0..1 overrides the MMR lambda of the diversity re-ranking (1 = off).
+// onlineOnly=true returns only users who are online; recentlyActive=true|false turns the
+// boost of recently active users on or off for this request.
+// mutual=true|false overrides the user's mutual matching preference (see dealbreakers.go).
+// With an incomplete profile (useProfile) cold-start recommendations are returned instead,
+// marked by the X-Recommendations-Mode: cold-start, X-Profile-Completeness and
+// X-Missing-Fields headers.
 func GetRecommendations(w http.ResponseWriter, r *http.Request) {
 
 	userIDStr, ok := r.Context().Value("userID").(string)
@@ -79,7 +229,97 @@ func GetRecommendations(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ranking := r.URL.Query().Get("ranking")
+	if ranking != "" && !services.IsRankingStrategy(ranking) {
+		http.Error(w, fmt.Sprintf("invalid ranking %q", ranking), http.StatusBadRequest)
+		return
+	}
+
+	var diversity *float64
+	if d := r.URL.Query().Get("diversity"); d != "" {
+		lambda, err := strconv.ParseFloat(d, 64)
+		if err != nil || lambda < 0 || lambda > 1 {
+			http.Error(w, fmt.Sprintf("invalid diversity %q (expected 0..1)", d), http.StatusBadRequest)
+			return
+		}
+		diversity = &lambda
+	}
+
+	onlineOnly := false
+	if v := r.URL.Query().Get("onlineOnly"); v != "" {
+		if onlineOnly, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, fmt.Sprintf("invalid onlineOnly %q", v), http.StatusBadRequest)
+			return
+		}
+	}
+	var recentlyActive *bool
+	if v := r.URL.Query().Get("recentlyActive"); v != "" {
+		on, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid recentlyActive %q", v), http.StatusBadRequest)
+			return
+		}
+		recentlyActive = &on
+	}
+	var mutual *bool
+	if v := r.URL.Query().Get("mutual"); v != "" {
+		on, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid mutual %q", v), http.StatusBadRequest)
+			return
+		}
+		mutual = &on
+	}
+
 	withDist := r.URL.Query().Get("withDistance") == "true"
+	explain := r.URL.Query().Get("explain") == "true"
+
+	cursor := r.URL.Query().Get("cursor")
+	paged := cursor != "" || r.URL.Query().Get("paged") == "true"
+	pageSize, err := parsePageSize(r.URL.Query().Get("pageSize"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	svc, assignment := serviceForUser(w, currentUserID)
+	svc = svc.WithRanking(ranking)
+	if diversity != nil {
+		svc = svc.WithDiversity(*diversity)
+	}
+	if onlineOnly {
+		svc = svc.WithOnlineOnly()
+	}
+	if recentlyActive != nil {
+		svc = svc.WithActivityBoost(*recentlyActive)
+	}
+	if mutual != nil {
+		svc = svc.WithMutual(*mutual)
+	}
+
+	if cursor != "" {
+		page, err := svc.GetPageByCursor(currentUserID, cursor, pageSize)
+		switch {
+		case errors.Is(err, services.ErrInvalidCursor):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, services.ErrSnapshotNotFound):
+			http.Error(w, err.Error(), http.StatusGone)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		recordImpressions(currentUserID, assignment, page.Items, page.Offset)
+		writeRecommendationPage(w, page, explain)
+		return
+	}
+
+	// Paged requests rank every candidate; the page is cut from the stored snapshot
+	limit := 0
+	if !paged {
+		limit = 50
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 
@@ -88,32 +328,56 @@ func GetRecommendations(w http.ResponseWriter, r *http.Request) {
 	fmt.Println("Mode:", mode)
 	fmt.Println("UseProfile:", useProfile)
 
-	var pendingIDs, declinedIDs []uuid.UUID
+	var pendingIDs []uuid.UUID
 
 	recommendationService.DB.
 		Model(&models.Connection{}).
 		Where("connection_id = ? AND status = ?", currentUserID, "pending").
 		Pluck("user_id", &pendingIDs)
 
-	recommendationService.DB.
-		Model(&models.Recommendation{}).
-		Where("user_id = ? AND status = ?", currentUserID, "declined").
-		Pluck("rec_user_id", &declinedIDs)
+	declinedIDs, err := recommendationService.DeclinedUserIDs(currentUserID)
+	if err != nil {
+		logrus.Errorf("GetRecommendations: error fetching declined users for %s: %v", currentUserID, err)
+		http.Error(w, "Error fetching recommendations", http.StatusInternalServerError)
+		return
+	}
 
 	var (
 		idsWithDist []services.RecommendationWithDistance
 		ids         []uuid.UUID
+		incomplete  *services.ErrIncompleteProfile
 	)
 
 	if useProfile {
 		fmt.Println("Using saved profile filters")
-		idsWithDist, err = recommendationService.GetRecommendationsWithDistance(currentUserID, mode)
+		idsWithDist, err = svc.GetRankedRecommendations(currentUserID, mode, limit)
+		if errors.As(err, &incomplete) {
+			idsWithDist, err = svc.GetColdStartRecommendations(currentUserID, mode, limit)
+			w.Header().Set("X-Recommendations-Mode", services.ColdStartMode)
+			w.Header().Set("X-Profile-Completeness", strconv.Itoa(incomplete.Completeness))
+			w.Header().Set("X-Missing-Fields", strings.Join(incomplete.MissingFields, ","))
+		}
 
 	} else {
 
 		lat, _ := strconv.ParseFloat(r.URL.Query().Get("cityLat"), 64)
 		lon, _ := strconv.ParseFloat(r.URL.Query().Get("cityLon"), 64)
 
+		// locationId searches around one of the user's saved locations instead of cityLat/cityLon
+		if param := r.URL.Query().Get("locationId"); param != "" {
+			locID, err := strconv.ParseUint(param, 10, 64)
+			if err != nil {
+				http.Error(w, "Invalid locationId", http.StatusBadRequest)
+				return
+			}
+			loc, err := locationService.Get(currentUserID, uint(locID))
+			if err != nil {
+				http.Error(w, "Location not found", http.StatusNotFound)
+				return
+			}
+			lat, lon = loc.Latitude, loc.Longitude
+		}
+
 		interests := strings.FieldsFunc(r.URL.Query().Get("interests"), func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
 		priorityInterests, _ := strconv.ParseBool(r.URL.Query().Get("priorityInterests"))
 
@@ -139,16 +403,24 @@ func GetRecommendations(w http.ResponseWriter, r *http.Request) {
 		fmt.Println("Travel:", travel, "Priority:", priorityTravel)
 		fmt.Println("LookingFor:", lookingFor)
 
-		idsWithDist, err = recommendationService.GetRecommendationsWithFiltersWithDistance(
-			currentUserID, mode,
-			lat, lon,
-			interests, priorityInterests,
-			hobbies, priorityHobbies,
-			music, priorityMusic,
-			food, priorityFood,
-			travel, priorityTravel,
-			lookingFor,
-		)
+		if !paged {
+			limit = 10
+		}
+		idsWithDist, err = svc.GetRecommendationsWithFilters(currentUserID, mode, services.RecommendationFilters{
+			Latitude:          lat,
+			Longitude:         lon,
+			Interests:         interests,
+			PriorityInterests: priorityInterests,
+			Hobbies:           hobbies,
+			PriorityHobbies:   priorityHobbies,
+			Music:             music,
+			PriorityMusic:     priorityMusic,
+			Food:              food,
+			PriorityFood:      priorityFood,
+			Travel:            travel,
+			PriorityTravel:    priorityTravel,
+			LookingFor:        lookingFor,
+		}, limit)
 
 	}
 
@@ -158,14 +430,8 @@ func GetRecommendations(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err != nil {
-
-		msg := err.Error()
-		if strings.Contains(msg, "please fill in your profile and biography to get recommendations") ||
-			strings.Contains(msg, "please provide your first and last name") ||
-			strings.Contains(msg, "please complete your biography") {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("[]"))
+		if errors.As(err, &incomplete) {
+			writeIncompleteProfile(w, incomplete)
 			return
 		}
 
@@ -186,26 +452,95 @@ func GetRecommendations(w http.ResponseWriter, r *http.Request) {
 	}
 	idsWithDist = filtered
 
-	if withDist {
-		out := make([]RecommendationOutput, len(idsWithDist))
-		for i, rec := range idsWithDist {
-			//online, _ := presenceService.IsOnline(rec.UserID.String()) // ✅ added
-			out[i] = RecommendationOutput{
-				ID:       rec.UserID,
-				Distance: rec.Distance,
-				Score:    rec.Score,
-				//Online:   online,
-			}
-		}
-		json.NewEncoder(w).Encode(out)
+	if paged {
+		snap := recommendationService.SaveSnapshot(currentUserID, mode, idsWithDist)
+		page := services.PageFromSnapshot(snap, 0, pageSize)
+		recordImpressions(currentUserID, assignment, page.Items, 0)
+		writeRecommendationPageWith(w, page, explain, incomplete)
+		return
+	}
+
+	recordImpressions(currentUserID, assignment, idsWithDist, 0)
+
+	if withDist || explain {
+		json.NewEncoder(w).Encode(toRecommendationOutputs(idsWithDist, explain))
 	} else {
 		json.NewEncoder(w).Encode(ids)
 	}
 }
 
+// ExplainRecommendation handles GET /recommendations/{id}/explain endpoint.
+// Returns the score breakdown (matched fields, shared tokens, weights, distance band, mode)
+// for a single candidate. Uses the same access rules as the user endpoints and
+// responds with 404 if the candidate is not visible to the current user.
+func ExplainRecommendation(w http.ResponseWriter, r *http.Request) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	currentUserID, _ := uuid.Parse(userIDStr)
+
+	vars := mux.Vars(r)
+	recID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid recommendation ID", http.StatusBadRequest)
+		return
+	}
+
+	mode, err := parseMode(r.URL.Query().Get("mode"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	allowed, err := userHasAccess(currentUserID, recID)
+	if err != nil || !allowed {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	rec, err := recommendationService.ExplainRecommendation(currentUserID, recID, mode)
+	if err != nil {
+		var incomplete *services.ErrIncompleteProfile
+		if errors.As(err, &incomplete) {
+			writeIncompleteProfile(w, incomplete)
+			return
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		logrus.WithFields(logrus.Fields{"userID": currentUserID, "recID": recID}).
+			Errorf("ExplainRecommendation failed: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RecommendationOutput{
+		ID:          rec.UserID,
+		Distance:    rec.Distance,
+		Score:       rec.Score,
+		Explanation: rec.Explanation,
+	})
+}
+
+// RebuildSimilarity handles POST /admin/rebuild-similarity endpoint.
+// Recomputes collaborative filtering similarities immediately instead of waiting for the job.
+func RebuildSimilarity(w http.ResponseWriter, r *http.Request) {
+	if err := collaborativeService.Rebuild(); err != nil {
+		logrus.Errorf("RebuildSimilarity failed: %v", err)
+		http.Error(w, "Error rebuilding similarities", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Similarities rebuilt"})
+}
+
 // DeclineRecommendation handles POST /recommendations/{id}/decline endpoint.
 // Marks a recommendation as declined for the current user.
-// Returns 204 No Content on success, or error status on failure.
+// Returns 204 No Content on success, 409 if the users are already matched.
 func DeclineRecommendation(w http.ResponseWriter, r *http.Request) {
 	userIDStr, ok := r.Context().Value("userID").(string)
 	if !ok {
@@ -222,6 +557,10 @@ func DeclineRecommendation(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := recommendationService.DeclineRecommendation(currentUserID, recID); err != nil {
+		if errors.Is(err, services.ErrAlreadyMatched) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		logrus.WithFields(logrus.Fields{"userID": currentUserID, "recID": recID}).
 			Errorf("DeclineRecommendation failed: %v", err)
 		http.Error(w, "Error declining recommendation", http.StatusInternalServerError)
@@ -230,3 +569,123 @@ func DeclineRecommendation(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// UndoDeclineRecommendation handles DELETE /recommendations/{id}/decline endpoint.
+// Removes the decline so the user can be recommended again.
+// Returns 204 No Content on success, 404 if the user was not declined.
+func UndoDeclineRecommendation(w http.ResponseWriter, r *http.Request) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	currentUserID, _ := uuid.Parse(userIDStr)
+
+	vars := mux.Vars(r)
+	recID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid recommendation ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := recommendationService.UndoDecline(currentUserID, recID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Declined recommendation not found", http.StatusNotFound)
+			return
+		}
+		logrus.WithFields(logrus.Fields{"userID": currentUserID, "recID": recID}).
+			Errorf("UndoDeclineRecommendation failed: %v", err)
+		http.Error(w, "Error undoing decline", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetDeclinedRecommendations handles GET /recommendations/declined endpoint.
+// Returns the users the current user has declined whose decline has not expired,
+// with the decline time and expiry (if any), most recent first.
+func GetDeclinedRecommendations(w http.ResponseWriter, r *http.Request) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	currentUserID, _ := uuid.Parse(userIDStr)
+
+	declined, err := recommendationService.GetDeclined(currentUserID)
+	if err != nil {
+		logrus.WithField("userID", currentUserID).Errorf("GetDeclinedRecommendations failed: %v", err)
+		http.Error(w, "Error fetching declined recommendations", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(declined)
+}
+
+// LikeRecommendation handles POST /recommendations/{id}/like endpoint.
+// Records a like; if the other user already liked the current user, the pair is matched:
+// an accepted connection and a chat are created and both users receive a "match" event.
+// Responds with {"matched": bool, "chatId": id}.
+func LikeRecommendation(w http.ResponseWriter, r *http.Request) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	currentUserID, _ := uuid.Parse(userIDStr)
+
+	vars := mux.Vars(r)
+	recID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid recommendation ID", http.StatusBadRequest)
+		return
+	}
+
+	result, err := recommendationService.LikeRecommendation(currentUserID, recID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrLikeSelf):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "User not found", http.StatusNotFound)
+		default:
+			logrus.WithFields(logrus.Fields{"userID": currentUserID, "recID": recID}).
+				Errorf("LikeRecommendation failed: %v", err)
+			http.Error(w, "Error liking recommendation", http.StatusInternalServerError)
+		}
+		return
+	}
+	if result.Matched && result.ChatID != 0 {
+		go sockets.BroadcastMatch(currentUserID, recID, result.ChatID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetReceivedLikes handles GET /likes/received endpoint.
+// Returns users who liked the current user and are still waiting for an answer.
+// Kept as a separate endpoint so it can be gated behind a premium entitlement later.
+func GetReceivedLikes(w http.ResponseWriter, r *http.Request) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	currentUserID, _ := uuid.Parse(userIDStr)
+
+	likes, err := recommendationService.GetReceivedLikes(currentUserID)
+	if err != nil {
+		logrus.WithField("userID", currentUserID).Errorf("GetReceivedLikes failed: %v", err)
+		http.Error(w, "Error fetching likes", http.StatusInternalServerError)
+		return
+	}
+	if likes == nil {
+		likes = []services.ReceivedLike{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(likes)
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"m/backend/services"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// saved_searches.go - Handles HTTP endpoints for saved custom searches.
+// Searches with alerts enabled are re-run by the saved search job, which sends a
+// saved_search_match event when new users qualify.
+
+var savedSearchService *services.SavedSearchService
+
+// InitSavedSearchesController initializes the saved searches controller.
+// Must be called after InitRecommendationControllerService.
+func InitSavedSearchesController(db *gorm.DB) {
+	savedSearchService = services.NewSavedSearchService(db, recommendationService)
+	logrus.Info("Saved searches controller initialized")
+}
+
+// writeSavedSearchError maps saved search service errors to HTTP statuses.
+func writeSavedSearchError(w http.ResponseWriter, op string, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Saved search not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidSavedSearch):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		logrus.Errorf("%s failed: %v", op, err)
+		http.Error(w, "Error processing saved search", http.StatusInternalServerError)
+	}
+}
+
+// GetSavedSearches handles GET /me/searches endpoint.
+// Returns the current user's saved searches with their filters.
+func GetSavedSearches(w http.ResponseWriter, r *http.Request) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	currentUserID, _ := uuid.Parse(userIDStr)
+
+	list, err := savedSearchService.List(currentUserID)
+	if err != nil {
+		writeSavedSearchError(w, "GetSavedSearches", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// CreateSavedSearch handles POST /me/searches endpoint.
+// Body: {"name", "mode", "alerts", "filters": {latitude, longitude, interests, priorityInterests, ..., lookingFor}}.
+func CreateSavedSearch(w http.ResponseWriter, r *http.Request) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	currentUserID, _ := uuid.Parse(userIDStr)
+
+	var input services.SavedSearchInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	search, err := savedSearchService.Create(currentUserID, input)
+	if err != nil {
+		writeSavedSearchError(w, "CreateSavedSearch", err)
+		return
+	}
+
+	// Record the baseline right away so the next job run only reports new users
+	if search.Alerts {
+		go func() {
+			if _, err := savedSearchService.RunSearch(search); err != nil {
+				logrus.Errorf("CreateSavedSearch: baseline run of search %d failed: %v", search.ID, err)
+			}
+		}()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(search)
+}
+
+// DeleteSavedSearch handles DELETE /me/searches/{id} endpoint.
+// Returns 204 No Content on success.
+func DeleteSavedSearch(w http.ResponseWriter, r *http.Request) {
+	currentUserID, id, ok := currentUserAndPathID(w, r, "saved search")
+	if !ok {
+		return
+	}
+	if err := savedSearchService.Delete(currentUserID, id); err != nil {
+		writeSavedSearchError(w, "DeleteSavedSearch", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetSavedSearchResults handles GET /me/searches/{id}/results endpoint.
+// Runs the saved search now and returns the recommendations with distance and score.
+func GetSavedSearchResults(w http.ResponseWriter, r *http.Request) {
+	currentUserID, id, ok := currentUserAndPathID(w, r, "saved search")
+	if !ok {
+		return
+	}
+	search, err := savedSearchService.Get(currentUserID, id)
+	if err != nil {
+		writeSavedSearchError(w, "GetSavedSearchResults", err)
+		return
+	}
+	recs, err := savedSearchService.Execute(search)
+	if err != nil {
+		writeSavedSearchError(w, "GetSavedSearchResults", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(toRecommendationOutputs(recs, false))
+}
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"m/backend/services"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var searchService *services.SearchService
+
+// InitSearchController initializes the user search controller.
+func InitSearchController(db *gorm.DB) {
+	searchService = services.NewSearchService(db)
+	logrus.Info("Search controller initialized")
+}
+
+// SearchUsers handles GET /search/users endpoint.
+// Query: q (required, websearch syntax: "quoted phrases", OR, -excluded), page (1-based), pageSize.
+// Returns {items: [{id, firstName, lastName, photoUrl, city, distance, rank, snippets}], page, pageSize, total}.
+// Only users visible to the current user are searched (see services.SearchService).
+func SearchUsers(w http.ResponseWriter, r *http.Request) {
+	userIDStr, ok := r.Context().Value("userID").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	currentUserID, _ := uuid.Parse(userIDStr)
+
+	query := r.URL.Query()
+	page := 1
+	if p := query.Get("page"); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 1 {
+			http.Error(w, "invalid page", http.StatusBadRequest)
+			return
+		}
+		page = n
+	}
+	pageSize, err := parsePageSize(query.Get("pageSize"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := searchService.SearchUsers(currentUserID, query.Get("q"), page, pageSize)
+	if errors.Is(err, services.ErrInvalidQuery) {
+		http.Error(w, "q is required (max 200 characters)", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logrus.Errorf("SearchUsers failed: %v", err)
+		http.Error(w, "Error searching users", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -26,6 +26,13 @@ func InitUserController(database *gorm.DB) {
 // Access is granted if:
 // - The user requests their own data
 // - There is an accepted or pending connection
+// - The requested user liked the current user
+// - The requested user was found by one of the current user's saved searches
+// - The requested user was served in a recommendation snapshot
+// - The requested user can be found by the current user's search
+// - The requested user was sent to the current user in a recent daily digest
+// - The requested user was announced to the current user in a new_match_nearby event
+// - Both users are going to the same upcoming event
 // - The requested user is in recommendations for the current user
 func userHasAccess(currentUserID, requestedUserID uuid.UUID) (bool, error) {
 	logrus.Infof("userHasAccess: checking access from %s to %s", currentUserID, requestedUserID)
@@ -71,6 +78,90 @@ func userHasAccess(currentUserID, requestedUserID uuid.UUID) (bool, error) {
 		logrus.Debugf("userHasAccess: no pending connection between %s and %s", currentUserID, requestedUserID)
 	}
 
+	// Users who liked the current user are visible so likes can be answered
+	var likes int64
+	if err := db.Model(&models.Recommendation{}).
+		Where("user_id = ? AND rec_user_id = ? AND status IN ?", requestedUserID, currentUserID, []string{"liked", "matched"}).
+		Count(&likes).Error; err != nil {
+		logrus.Errorf("userHasAccess: DB error while checking likes: %v", err)
+		return false, err
+	}
+	if likes > 0 {
+		logrus.Infof("userHasAccess: access granted — user %s liked %s", requestedUserID, currentUserID)
+		return true, nil
+	}
+
+	// Users served on any page of a live recommendation snapshot are visible too
+	if services.InSnapshot(currentUserID, requestedUserID) {
+		logrus.Infof("userHasAccess: access granted — user %s is in a recommendation snapshot of %s", requestedUserID, currentUserID)
+		return true, nil
+	}
+
+	// Users the current user can find with search (results link to them)
+	if searchService != nil {
+		visible, err := searchService.Visible(currentUserID, requestedUserID)
+		if err != nil {
+			logrus.Errorf("userHasAccess: DB error while checking search visibility: %v", err)
+			return false, err
+		}
+		if visible {
+			logrus.Infof("userHasAccess: access granted — user %s can be found by %s in search", requestedUserID, currentUserID)
+			return true, nil
+		}
+	}
+
+	// Users found by one of the current user's saved searches (alerts link to them)
+	if savedSearchService != nil {
+		found, err := savedSearchService.FoundBySavedSearch(currentUserID, requestedUserID)
+		if err != nil {
+			logrus.Errorf("userHasAccess: DB error while checking saved searches: %v", err)
+			return false, err
+		}
+		if found {
+			logrus.Infof("userHasAccess: access granted — user %s was found by a saved search of %s", requestedUserID, currentUserID)
+			return true, nil
+		}
+	}
+
+	// Users sent in a recent daily digest (the digest links to them)
+	if digestService != nil {
+		served, err := digestService.ServedInDigest(currentUserID, requestedUserID)
+		if err != nil {
+			logrus.Errorf("userHasAccess: DB error while checking digests: %v", err)
+			return false, err
+		}
+		if served {
+			logrus.Infof("userHasAccess: access granted — user %s was in a digest of %s", requestedUserID, currentUserID)
+			return true, nil
+		}
+	}
+
+	// Users announced in a new_match_nearby event (the event links to them)
+	var alerts int64
+	if err := db.Model(&models.NearbyAlert{}).
+		Where("user_id = ? AND matched_user_id = ?", currentUserID, requestedUserID).
+		Count(&alerts).Error; err != nil {
+		logrus.Errorf("userHasAccess: DB error while checking nearby alerts: %v", err)
+		return false, err
+	}
+	if alerts > 0 {
+		logrus.Infof("userHasAccess: access granted — user %s was announced nearby to %s", requestedUserID, currentUserID)
+		return true, nil
+	}
+
+	// People going to the same upcoming event (event recommendations link to them)
+	if eventService != nil {
+		shared, err := eventService.SharesEvent(currentUserID, requestedUserID)
+		if err != nil {
+			logrus.Errorf("userHasAccess: DB error while checking events: %v", err)
+			return false, err
+		}
+		if shared {
+			logrus.Infof("userHasAccess: access granted — %s and %s are going to the same event", requestedUserID, currentUserID)
+			return true, nil
+		}
+	}
+
 	// As a fallback, check if the requested user is in recommendations for the current user
 	// This allows users to see public info of those who are recommended to them
 	logrus.Debugf("userHasAccess: checking if %s is in recommendations for %s", requestedUserID, currentUserID)
@@ -219,10 +310,20 @@ func GetUserProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Respond with 'about' field only (privacy)
+	// Respond with 'about', demographics and questionnaire match only (privacy).
+	// The birthdate is only shown as an age, and not at all when the user hides it.
 	logrus.Infof("Profile of user %s retrieved by user %s", requestedUserID, currentUserID)
+	var age *int
+	if !profile.HideAge {
+		age = profile.Age()
+	}
 	response := map[string]interface{}{
-		"about": profile.About,
+		"about":        profile.About,
+		"age":          age,
+		"gender":       profile.Gender,
+		"pronouns":     profile.Pronouns,
+		"languages":    profile.Languages,
+		"matchPercent": matchPercent(currentUserID, requestedUserID),
 	}
 	json.NewEncoder(w).Encode(response)
 }
@@ -354,9 +455,20 @@ func GetCurrentUserProfile(w http.ResponseWriter, r *http.Request) {
 		"latitude":  profile.Latitude,
 		"longitude": profile.Longitude,
 		"city":      profile.City,
+		"birthdate": nil,
+		"age":       profile.Age(),
+		"hideAge":   profile.HideAge,
+		"gender":    profile.Gender,
+		"languages": profile.Languages,
+		"pronouns":  profile.Pronouns,
+	}
+	// The owner sees their own birthdate
+	if profile.Birthdate != nil {
+		response["birthdate"] = profile.Birthdate.Format(services.BirthdateLayout)
 	}
 
-	logrus.Infof("📤 Sending profile response: %+v", response)
+	// Not the whole response: the birthdate must stay out of the logs
+	logrus.Infof("📤 Sending profile response for user %s", userID)
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
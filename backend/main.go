@@ -66,6 +66,13 @@ func main() {
 		log.Fatalf("Database connection error: %v", err)
 	}
 
+	// Full-text search columns for GET /search/users
+	searchLanguage, err := models.SetupFullTextSearch(db, config.AppConfig.SearchLanguage)
+	if err != nil {
+		log.Errorf("Full-text search setup error: %v", err)
+	}
+	services.SetSearchLanguage(searchLanguage)
+
 	// Initialize Redis client for presence and caching
 	rdb := redis.NewClient(&redis.Options{
 		Addr:     config.AppConfig.RedisURL,
@@ -73,12 +80,49 @@ func main() {
 		DB:       0,
 	})
 	presenceService := services.NewPresenceService(rdb)
+	// Persist last activity for presence-aware ranking
+	presenceService.DB = db
 
 	// Pass DB and presence to controllers and sockets
 	sockets.SetDB(db)
 	sockets.SetChatsDB(db)
 	controllers.InitChatsController(db, presenceService)
+	// Ranking settings are applied here, before any background job starts ranking
 	controllers.InitRecommendationControllerService(db, presenceService)
+	recs := controllers.RecommendationService()
+
+	// Rebuild collaborative filtering similarities in the background
+	collaborativeService := services.NewCollaborativeService(db, config.AppConfig.CFNeighbors)
+	go collaborativeService.Run(time.Duration(config.AppConfig.CFRebuildIntervalMin) * time.Minute)
+
+	// Switch active locations when saved trips start and end
+	locationService := services.NewLocationService(db)
+	go locationService.Run(time.Duration(config.AppConfig.LocationSwitchIntervalMin) * time.Minute)
+
+	// Deliver background events over WebSocket (stored for offline users)
+	services.SetNotifier(sockets.SendToUser)
+
+	// Re-run saved searches and alert users about new matches
+	savedSearchService := services.NewSavedSearchService(db, recs)
+	go savedSearchService.Run(time.Duration(config.AppConfig.SavedSearchIntervalMin) * time.Minute)
+
+	// Send each active user a daily digest of top matches (email too if enabled)
+	var mailer *services.Mailer
+	if config.AppConfig.DigestEmail {
+		mailer = services.NewMailer(config.AppConfig.SMTPServer, config.AppConfig.SMTPPort,
+			config.AppConfig.SMTPUser, config.AppConfig.SMTPPassword)
+	}
+	digestService := services.NewDigestService(db, recs, mailer,
+		config.AppConfig.DigestSize, config.AppConfig.DigestActiveDays)
+	go digestService.Run(time.Duration(config.AppConfig.DigestIntervalMin) * time.Minute)
+
+	// Tell users when someone new matching them appears within their radius
+	nearbyMatcher := services.NewNearbyMatcher(db, recs,
+		config.AppConfig.NearbyAlertMinScore, config.AppConfig.NearbyAlertDailyLimit)
+	if config.AppConfig.NearbyAlertDailyLimit > 0 {
+		services.SetNearbyMatcher(nearbyMatcher)
+	}
+	go nearbyMatcher.Run()
 
 	// Set up HTTP router and CORS middleware
 	router := mux.NewRouter()
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// migrateChatParticipants adds the participants of direct chats created before chats
+// had a participants table. Their read position is the newest message of the other user
+// already marked read. Chats that have participants are left alone, so it runs once per chat.
+func migrateChatParticipants(db *gorm.DB) error {
+	res := db.Exec(`
+		INSERT INTO chat_participants (chat_id, user_id, role, last_read_message_id, joined_at)
+		SELECT c.id, u.user_id, 'member',
+		       COALESCE((SELECT MAX(m.id) FROM messages m
+		                 WHERE m.chat_id = c.id AND m.sender_id <> u.user_id AND m.read), 0),
+		       c.created_at
+		FROM chats c
+		CROSS JOIN LATERAL (VALUES (c.user1_id), (c.user2_id)) AS u(user_id)
+		WHERE NOT c.is_group
+		  AND c.user1_id IS NOT NULL AND c.user2_id IS NOT NULL
+		  AND NOT EXISTS (SELECT 1 FROM chat_participants p WHERE p.chat_id = c.id)
+		ON CONFLICT DO NOTHING
+	`)
+	if res.Error != nil {
+		return fmt.Errorf("migrateChatParticipants: %w", res.Error)
+	}
+	if res.RowsAffected > 0 {
+		logrus.Infof("migrateChatParticipants: %d participants added to direct chats", res.RowsAffected)
+	}
+	return nil
+}
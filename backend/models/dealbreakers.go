@@ -0,0 +1,40 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Dealbreakers maps Bio field names to lowercase tokens that rule a person out
+// ({"Food": ["meat"], "LookingFor": ["casual"]}). Stored as a JSON object like FieldWeights.
+type Dealbreakers map[string][]string
+
+// Value implements driver.Valuer.
+func (d Dealbreakers) Value() (driver.Value, error) {
+	if len(d) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner.
+func (d *Dealbreakers) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*d = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("Dealbreakers: unsupported type %T", value)
+	}
+	return json.Unmarshal(data, d)
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// StringList is a list of lowercase codes (genders, languages) stored as a JSON array.
+type StringList []string
+
+// Contains reports whether the list has s.
+func (l StringList) Contains(s string) bool {
+	for _, x := range l {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Value implements driver.Valuer.
+func (l StringList) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner.
+func (l *StringList) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("StringList: unsupported type %T", value)
+	}
+	return json.Unmarshal(data, l)
+}
+
+// AgeAt returns the age in full years of someone born on birthdate.
+func AgeAt(birthdate, now time.Time) int {
+	age := now.Year() - birthdate.Year()
+	if now.Month() < birthdate.Month() || (now.Month() == birthdate.Month() && now.Day() < birthdate.Day()) {
+		age--
+	}
+	return age
+}
+
+// Age returns the profile's age today, nil without a birthdate.
+func (p Profile) Age() *int {
+	if p.Birthdate == nil {
+		return nil
+	}
+	age := AgeAt(*p.Birthdate, time.Now())
+	return &age
+}
+
+// MarshalJSON adds the age to the profile. The birthdate itself is never serialized.
+func (p Profile) MarshalJSON() ([]byte, error) {
+	type profile Profile
+	return json.Marshal(struct {
+		profile
+		Age *int `json:"age"`
+	}{profile(p), p.Age()})
+}
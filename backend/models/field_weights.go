@@ -0,0 +1,83 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// FieldWeights maps Bio field names to scoring multipliers. It is stored as a JSON
+// object, so adding a Bio field does not need a new column.
+type FieldWeights map[string]float64
+
+// Weight returns the multiplier for a field, 1 if it is not set.
+func (fw FieldWeights) Weight(field string) float64 {
+	if w, ok := fw[field]; ok {
+		return w
+	}
+	return 1
+}
+
+// Value implements driver.Valuer.
+func (fw FieldWeights) Value() (driver.Value, error) {
+	if len(fw) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(fw)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner.
+func (fw *FieldWeights) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*fw = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("FieldWeights: unsupported type %T", value)
+	}
+	return json.Unmarshal(data, fw)
+}
+
+// legacyPriorityColumns are the boolean priority columns replaced by FieldWeights.
+// A set flag doubled the field weight, so it becomes a weight of 2.
+var legacyPriorityColumns = map[string]string{
+	"priority_interests": "Interests",
+	"priority_hobbies":   "Hobbies",
+	"priority_music":     "Music",
+	"priority_food":      "Food",
+	"priority_travel":    "Travel",
+}
+
+// migratePriorityFlags moves the old priority_* booleans into field_weights and drops them.
+func migratePriorityFlags(db *gorm.DB) error {
+	for column, field := range legacyPriorityColumns {
+		if !db.Migrator().HasColumn(&Preference{}, column) {
+			continue
+		}
+		err := db.Exec(fmt.Sprintf(`
+			UPDATE preferences
+			SET field_weights = COALESCE(field_weights, '{}'::jsonb) || jsonb_build_object(?::text, 2)
+			WHERE %s AND (field_weights IS NULL OR field_weights -> ?::text IS NULL)
+		`, column), field, field).Error
+		if err != nil {
+			return fmt.Errorf("migratePriorityFlags: %s: %w", column, err)
+		}
+		if err := db.Migrator().DropColumn(&Preference{}, column); err != nil {
+			return fmt.Errorf("migratePriorityFlags: dropping %s: %w", column, err)
+		}
+		logrus.Infof("migratePriorityFlags: %s moved to field_weights", column)
+	}
+	return nil
+}
@@ -39,6 +39,16 @@ type Profile struct {
 	City      string    `gorm:"size:100" json:"city"`
 	Latitude  float64   `json:"latitude"`
 	Longitude float64   `json:"longitude"`
+	// LastActiveAt is the last WebSocket activity (see services.PresenceService).
+	LastActiveAt *time.Time `gorm:"index" json:"lastActiveAt"`
+	// Demographics are optional. Birthdate is only ever exposed as an age (see Age), and
+	// HideAge keeps even that from other users; it still applies to their age filters.
+	// Gender is one of services.Genders, Languages are lowercase ISO 639 codes.
+	Birthdate *time.Time `gorm:"type:date" json:"-"`
+	HideAge   bool       `gorm:"default:false" json:"hideAge"`
+	Gender    string     `gorm:"size:30" json:"gender"`
+	Languages StringList `gorm:"type:jsonb" json:"languages"`
+	Pronouns  string     `gorm:"size:30" json:"pronouns"`
 	// EarthLoc is a generated column (PostgreSQL cube type) for fast geo-distance queries.
 	// It is automatically computed from latitude/longitude using ll_to_earth().
 	EarthLoc []byte `gorm:"type:cube;->" json:"-"`
@@ -56,25 +66,52 @@ type Bio struct {
 	LookingFor string    `gorm:"type:text" json:"lookingFor"`
 }
 
-// Preference stores user search settings and field priorities for recommendations.
+// Preference stores user search settings and per-field scoring weights for recommendations.
 type Preference struct {
-	ID                uint      `gorm:"primaryKey" json:"id"`
-	UserID            uuid.UUID `gorm:"type:uuid;not null;index" json:"userId"`
-	MaxRadius         float64   `gorm:"default:0" json:"maxRadius"`
-	PriorityInterests bool      `gorm:"default:false" json:"priorityInterests"`
-	PriorityHobbies   bool      `gorm:"default:false" json:"priorityHobbies"`
-	PriorityMusic     bool      `gorm:"default:false" json:"priorityMusic"`
-	PriorityFood      bool      `gorm:"default:false" json:"priorityFood"`
-	PriorityTravel    bool      `gorm:"default:false" json:"priorityTravel"`
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index" json:"userId"`
+	MaxRadius float64   `gorm:"default:0;index" json:"maxRadius"`
+	// FieldWeights multiplies the scoring weight of Bio fields by name ("Interests": 2,
+	// "Music": 0.5, ...). Missing fields count as 1, 0 ignores the field.
+	FieldWeights FieldWeights `gorm:"type:jsonb" json:"fieldWeights"`
+	// DeclineTTLDays overrides the global decline expiry: nil uses DECLINE_TTL_DAYS,
+	// 0 keeps declined users hidden forever, N lets them resurface after N days.
+	DeclineTTLDays *int `json:"declineTtlDays"`
+	// RankingStrategy orders recommendations: "distance", "score" or "blend";
+	// empty uses the server default.
+	RankingStrategy string `gorm:"size:20" json:"rankingStrategy"`
+	// DigestOptOut stops the daily recommendation digest; DigestEmailOptOut only its email.
+	DigestOptOut      bool `gorm:"default:false" json:"digestOptOut"`
+	DigestEmailOptOut bool `gorm:"default:false" json:"digestEmailOptOut"`
+	// Dealbreakers are Bio tokens that rule a candidate out. With MutualMatching the
+	// candidate's radius and dealbreakers must accept this user too.
+	Dealbreakers   Dealbreakers `gorm:"type:jsonb" json:"dealbreakers"`
+	MutualMatching bool         `gorm:"default:false" json:"mutualMatching"`
+	// MinAge/MaxAge (0 = no limit), Genders and Languages (empty = any; a candidate
+	// must speak one of them) are hard filters: candidates outside them are never shown.
+	// In mutual mode the candidate's filters must accept this user too.
+	MinAge    int        `gorm:"default:0" json:"minAge"`
+	MaxAge    int        `gorm:"default:0" json:"maxAge"`
+	Genders   StringList `gorm:"type:jsonb" json:"genders"`
+	Languages StringList `gorm:"type:jsonb" json:"languages"`
 }
 
-// Recommendation links a user to a recommended user and tracks status (pending/declined).
+// Recommendation links a user to a recommended user and tracks status
+// (pending/declined/liked/matched). A like becomes "matched" on both rows
+// once the other user likes back.
+// DeclinedAt is set when the user declines and drives decline expiry.
+// ServedAt is set when the user was sent this recommendation in a daily digest.
 type Recommendation struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    uuid.UUID `gorm:"type:uuid;not null;index" json:"userId"`
-	RecUserID uuid.UUID `gorm:"type:uuid;not null;index" json:"recUserId"`
-	Status    string    `gorm:"size:50;default:'pending'" json:"status"`
-	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"userId"`
+	RecUserID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"recUserId"`
+	Status     string     `gorm:"size:50;default:'pending'" json:"status"`
+	Score      float64    `json:"score"`
+	Distance   float64    `json:"distance"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+	DeclinedAt *time.Time `json:"declinedAt,omitempty"`
+	LikedAt    *time.Time `json:"likedAt,omitempty"`
+	ServedAt   *time.Time `gorm:"index" json:"servedAt,omitempty"`
 }
 
 // Connection represents a friendship or pending friend request between users.
@@ -86,13 +123,32 @@ type Connection struct {
 	CreatedAt    time.Time `gorm:"autoCreateTime" json:"createdAt"`
 }
 
-// Chat represents a chat between two users, with all messages as a slice.
+// Chat represents a conversation, with all messages as a slice. Participants lists who
+// is in it; direct chats also keep their two users in User1ID and User2ID so the pair
+// can be looked up, while group chats leave them empty and have a name and an avatar.
 type Chat struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	User1ID   uuid.UUID `gorm:"type:uuid;not null;index" json:"user1Id"`
-	User2ID   uuid.UUID `gorm:"type:uuid;not null;index" json:"user2Id"`
-	Messages  []Message `gorm:"foreignKey:ChatID;constraint:OnDelete:CASCADE;" json:"messages"`
-	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
+	ID           uint              `gorm:"primaryKey" json:"id"`
+	IsGroup      bool              `gorm:"default:false" json:"isGroup"`
+	Name         string            `gorm:"size:100" json:"name,omitempty"`
+	AvatarURL    string            `gorm:"size:255" json:"avatarUrl,omitempty"`
+	User1ID      *uuid.UUID        `gorm:"type:uuid;index" json:"user1Id,omitempty"`
+	User2ID      *uuid.UUID        `gorm:"type:uuid;index" json:"user2Id,omitempty"`
+	Participants []ChatParticipant `gorm:"foreignKey:ChatID;constraint:OnDelete:CASCADE;" json:"participants,omitempty"`
+	Messages     []Message         `gorm:"foreignKey:ChatID;constraint:OnDelete:CASCADE;" json:"messages"`
+	CreatedAt    time.Time         `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+// ChatParticipant is a member of a chat: Role is "owner", "admin" or "member" (always
+// "member" in direct chats). LastReadMessageID is the member's read position; messages
+// of others with a higher ID are unread.
+type ChatParticipant struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	ChatID            uint       `gorm:"not null;uniqueIndex:idx_chat_participant" json:"chatId"`
+	UserID            uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_chat_participant;index" json:"userId"`
+	Role              string     `gorm:"size:20;not null;default:member" json:"role"`
+	LastReadMessageID uint       `gorm:"default:0" json:"lastReadMessageId"`
+	LastReadAt        *time.Time `json:"lastReadAt,omitempty"`
+	JoinedAt          time.Time  `gorm:"autoCreateTime" json:"joinedAt"`
 }
 
 // Message is a single message in a chat, with sender and timestamp.
@@ -104,6 +160,239 @@ type Message struct {
 	Timestamp time.Time `gorm:"autoCreateTime" json:"timestamp"`
 	Read      bool      `gorm:"default:false" json:"read"`
 	Sender    User      `json:"sender" gorm:"foreignKey:SenderID"`
+	// EditedAt is set by the last edit (previous versions are in MessageEdit);
+	// DeletedAt when the sender deleted the message for everyone, which clears Content.
+	EditedAt  *time.Time `json:"editedAt,omitempty"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+// MessageEdit is a previous version of an edited message.
+type MessageEdit struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	MessageID uint      `gorm:"not null;index" json:"messageId"`
+	Content   string    `gorm:"type:text" json:"content"`
+	EditedAt  time.Time `gorm:"autoCreateTime" json:"editedAt"`
+}
+
+// HiddenMessage is a message a user deleted for themselves only.
+type HiddenMessage struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	MessageID uint      `gorm:"not null;uniqueIndex:idx_hidden_message" json:"messageId"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_hidden_message" json:"userId"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+// UserSimilarity stores a behavior-based similarity between two users,
+// rebuilt periodically by the collaborative filtering job.
+type UserSimilarity struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	UserID        uuid.UUID `gorm:"type:uuid;not null;index" json:"userId"`
+	SimilarUserID uuid.UUID `gorm:"type:uuid;not null;index" json:"similarUserId"`
+	Score         float64   `gorm:"not null" json:"score"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// Experiment is an A/B test of recommendation scoring. Only active experiments
+// assign variants; users are bucketed deterministically by experiment name and user ID.
+type Experiment struct {
+	ID        uint                `gorm:"primaryKey" json:"id"`
+	Name      string              `gorm:"size:100;uniqueIndex;not null" json:"name"`
+	Active    bool                `gorm:"default:false" json:"active"`
+	Variants  []ExperimentVariant `gorm:"foreignKey:ExperimentID;constraint:OnDelete:CASCADE;" json:"variants"`
+	CreatedAt time.Time           `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+// ExperimentVariant is a named arm of an experiment. Allocation is its relative share
+// of users; Config is a JSON-encoded scoring override (field weights, sort order).
+type ExperimentVariant struct {
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	ExperimentID uint   `gorm:"not null;index" json:"experimentId"`
+	Name         string `gorm:"size:100;not null" json:"name"`
+	Allocation   int    `gorm:"not null;default:1" json:"allocation"`
+	Config       string `gorm:"type:text" json:"config"`
+}
+
+// RecommendationImpression records a recommendation served to a user, with its
+// rank position and the experiment variant (if any) that produced it.
+type RecommendationImpression struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	UserID       uuid.UUID `gorm:"type:uuid;not null;index" json:"userId"`
+	RecUserID    uuid.UUID `gorm:"type:uuid;not null;index" json:"recUserId"`
+	ExperimentID *uint     `gorm:"index" json:"experimentId,omitempty"`
+	Variant      string    `gorm:"size:100" json:"variant"`
+	Position     int       `json:"position"`
+	Score        float64   `json:"score"`
+	ServedAt     time.Time `gorm:"autoCreateTime;index" json:"servedAt"`
+}
+
+// SavedLocation is a named place of a user (home, work, a trip). The active location is
+// copied into the user's Profile coordinates, so recommendations and nearby search use it.
+// A trip has StartsAt/EndsAt: the location job activates it when the trip starts
+// (Switched marks that) and restores ReturnToID, the previously active location, when it ends.
+type SavedLocation struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"userId"`
+	Name       string     `gorm:"size:100;not null" json:"name"`
+	City       string     `gorm:"size:100" json:"city"`
+	Latitude   float64    `json:"latitude"`
+	Longitude  float64    `json:"longitude"`
+	StartsAt   *time.Time `gorm:"index" json:"startsAt,omitempty"`
+	EndsAt     *time.Time `gorm:"index" json:"endsAt,omitempty"`
+	Active     bool       `gorm:"default:false" json:"active"`
+	Switched   bool       `gorm:"default:false" json:"-"`
+	ReturnToID *uint      `json:"-"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+// SavedSearch is a named custom recommendation search (RecommendationFilters as JSON)
+// that the saved search job re-runs to alert the owner about new matches.
+type SavedSearch struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"userId"`
+	Name      string     `gorm:"size:100;not null" json:"name"`
+	Mode      string     `gorm:"size:20;not null;default:'affinity'" json:"mode"`
+	Filters   string     `gorm:"type:text" json:"-"`
+	Alerts    bool       `gorm:"default:true" json:"alerts"`
+	LastRunAt *time.Time `json:"lastRunAt,omitempty"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+// SavedSearchMatch records a user already found by a saved search, so only
+// newly qualifying users trigger an alert.
+type SavedSearchMatch struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	SavedSearchID uint      `gorm:"not null;uniqueIndex:idx_saved_search_match" json:"savedSearchId"`
+	MatchedUserID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_saved_search_match" json:"matchedUserId"`
+	FoundAt       time.Time `gorm:"autoCreateTime" json:"foundAt"`
+}
+
+// Notification is a WebSocket event stored for a user who was offline when it happened.
+// Payload is the JSON event; DeliveredAt is set once it has been sent.
+type Notification struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"userId"`
+	Type        string     `gorm:"size:50;not null" json:"type"`
+	Payload     string     `gorm:"type:text" json:"-"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+	DeliveredAt *time.Time `gorm:"index" json:"deliveredAt,omitempty"`
+}
+
+// NearbyAlert records that UserID was told about MatchedUserID arriving nearby
+// (a new_match_nearby event), so each person is announced once and alerts can be rate limited.
+type NearbyAlert struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	UserID        uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_nearby_alert" json:"userId"`
+	MatchedUserID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_nearby_alert" json:"matchedUserId"`
+	Distance      float64   `json:"distance"`
+	Score         float64   `json:"score"`
+	SentAt        time.Time `gorm:"autoCreateTime;index" json:"sentAt"`
+}
+
+// DigestRun records when the daily digest last ran for a user, whether or not it found
+// anyone to send, so users without matches are not re-ranked on every run of the day.
+type DigestRun struct {
+	UserID uuid.UUID `gorm:"type:uuid;primaryKey" json:"userId"`
+	RunAt  time.Time `gorm:"index" json:"runAt"`
+}
+
+// Question is an admin-managed multiple-choice compatibility question.
+// Inactive questions are hidden from users and ignored by scoring.
+type Question struct {
+	ID        uint             `gorm:"primaryKey" json:"id"`
+	Text      string           `gorm:"size:500;not null" json:"text"`
+	Active    bool             `gorm:"default:true" json:"active"`
+	Choices   []QuestionChoice `gorm:"foreignKey:QuestionID;constraint:OnDelete:CASCADE;" json:"choices"`
+	CreatedAt time.Time        `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+// QuestionChoice is one possible answer to a Question, shown in Position order.
+type QuestionChoice struct {
+	ID         uint   `gorm:"primaryKey" json:"id"`
+	QuestionID uint   `gorm:"not null;index" json:"questionId"`
+	Text       string `gorm:"size:200;not null" json:"text"`
+	Position   int    `gorm:"not null;default:0" json:"position"`
+}
+
+// QuestionAnswer is a user's answer to a Question: their own choice, the choices they
+// accept from a partner (empty accepts any) and how much the question matters to them
+// ("irrelevant", "little", "somewhat", "very" or "mandatory").
+type QuestionAnswer struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_question_answer" json:"userId"`
+	QuestionID uint      `gorm:"not null;uniqueIndex:idx_question_answer;index" json:"questionId"`
+	ChoiceID   uint      `gorm:"not null" json:"choiceId"`
+	Acceptable IDList    `gorm:"type:jsonb" json:"acceptable"`
+	Importance string    `gorm:"size:20;not null;default:'somewhat'" json:"importance"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+// Event is a meetup created by a user. Tags are lowercase interest tokens, Capacity 0
+// means unlimited. Like profiles, events have an earth_loc column for geo search; it is
+// generated from latitude/longitude by SQL (see InitDB) and not part of the struct.
+type Event struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	CreatorID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"creatorId"`
+	Title       string     `gorm:"size:200;not null" json:"title"`
+	Description string     `gorm:"type:text" json:"description"`
+	City        string     `gorm:"size:100" json:"city"`
+	Address     string     `gorm:"size:255" json:"address"`
+	Latitude    float64    `json:"latitude"`
+	Longitude   float64    `json:"longitude"`
+	StartsAt    time.Time  `gorm:"not null;index" json:"startsAt"`
+	EndsAt      *time.Time `json:"endsAt"`
+	Capacity    int        `gorm:"default:0" json:"capacity"`
+	Tags        StringList `gorm:"type:jsonb" json:"tags"`
+	CanceledAt  *time.Time `json:"canceledAt,omitempty"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+// EventAttendee is an RSVP to an event: Status is "going" or "waitlisted". The waitlist
+// is served in CreatedAt order when a spot frees up.
+type EventAttendee struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	EventID   uint      `gorm:"not null;uniqueIndex:idx_event_attendee" json:"eventId"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_event_attendee;index" json:"userId"`
+	Status    string    `gorm:"size:20;not null" json:"status"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+// EventMessage is a message in an event's group chat.
+type EventMessage struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	EventID   uint      `gorm:"not null;index" json:"eventId"`
+	SenderID  uuid.UUID `gorm:"type:uuid;not null" json:"senderId"`
+	Content   string    `gorm:"type:text" json:"content"`
+	Timestamp time.Time `gorm:"autoCreateTime;index" json:"timestamp"`
+}
+
+// Community is a group built around an interest tag from Bio (e.g. "jazz"). City is
+// optional; communities with the same tag in different cities are separate groups.
+type Community struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	OwnerID     uuid.UUID `gorm:"type:uuid;not null;index" json:"ownerId"`
+	Tag         string    `gorm:"size:50;not null;index" json:"tag"`
+	Name        string    `gorm:"size:100;not null" json:"name"`
+	Description string    `gorm:"type:text" json:"description"`
+	City        string    `gorm:"size:100;index" json:"city"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+// CommunityMember is a membership: Role is "owner", "moderator" or "member".
+type CommunityMember struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	CommunityID uint      `gorm:"not null;uniqueIndex:idx_community_member" json:"communityId"`
+	UserID      uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_community_member;index" json:"userId"`
+	Role        string    `gorm:"size:20;not null" json:"role"`
+	CreatedAt   time.Time `gorm:"autoCreateTime;index" json:"joinedAt"`
+}
+
+// CommunityPost is a post on a community's board.
+type CommunityPost struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	CommunityID uint      `gorm:"not null;index" json:"communityId"`
+	AuthorID    uuid.UUID `gorm:"type:uuid;not null" json:"authorId"`
+	Content     string    `gorm:"type:text" json:"content"`
+	CreatedAt   time.Time `gorm:"autoCreateTime;index" json:"createdAt"`
 }
 
 // FakeUser is used for marking test/dummy users in the database.
@@ -170,6 +459,20 @@ func InitDB(databaseURL string) (*gorm.DB, error) {
 	`).Error; err != nil {
 		logrus.Warnf("InitDB: failed to create index idx_profiles_earth_loc: %v", err)
 	}
+	// Same for events, which are discovered by location too
+	if err := db.Exec(`
+		ALTER TABLE events
+		ADD COLUMN IF NOT EXISTS earth_loc cube
+		GENERATED ALWAYS AS (ll_to_earth(latitude, longitude)) STORED
+	`).Error; err != nil {
+		logrus.Warnf("InitDB: failed to add events earth_loc column: %v", err)
+	}
+	if err := db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_events_earth_loc
+		ON events USING GIST (earth_loc)
+	`).Error; err != nil {
+		logrus.Warnf("InitDB: failed to create index idx_events_earth_loc: %v", err)
+	}
 	logrus.Info("InitDB: database initialized successfully")
 	return db, nil
 }
@@ -185,9 +488,37 @@ func Migrate(db *gorm.DB) error {
 		&Recommendation{},
 		&Connection{},
 		&Chat{},
+		&ChatParticipant{},
 		&Message{},
+		&MessageEdit{},
+		&HiddenMessage{},
 		&FakeUser{},
+		&UserSimilarity{},
+		&Experiment{},
+		&ExperimentVariant{},
+		&RecommendationImpression{},
+		&SavedLocation{},
+		&SavedSearch{},
+		&SavedSearchMatch{},
+		&Notification{},
+		&NearbyAlert{},
+		&DigestRun{},
+		&Question{},
+		&QuestionChoice{},
+		&QuestionAnswer{},
+		&Event{},
+		&EventAttendee{},
+		&EventMessage{},
+		&Community{},
+		&CommunityMember{},
+		&CommunityPost{},
 	)
+	if err == nil {
+		err = migratePriorityFlags(db)
+	}
+	if err == nil {
+		err = migrateChatParticipants(db)
+	}
 	if err != nil {
 		logrus.Errorf("Migrate: migration error: %v", err)
 	} else {
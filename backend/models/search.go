@@ -0,0 +1,87 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Full-text search over profiles uses generated tsvector columns that are not part of the
+// Go structs (like earth_loc they are maintained by SQL only):
+//   - profiles.search_tsv: first/last name (weight A, 'simple' config so names are not
+//     stemmed) and About (weight B, the configured language);
+//   - bios.search_tsv: LookingFor (weight B, the configured language).
+// A trigram index on the full name backs fuzzy name matching (pg_trgm).
+// The language used is stored as the column comment; when SEARCH_LANGUAGE changes the
+// columns are rebuilt on the next start.
+
+// searchConfigName limits configuration names, which are interpolated into DDL.
+var searchConfigName = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// SetupFullTextSearch creates the search columns and indexes for the given text search
+// configuration (e.g. "english", "finnish", "simple"). Unknown configurations fall back
+// to "simple". Returns the configuration in use.
+func SetupFullTextSearch(db *gorm.DB, language string) (string, error) {
+	if err := db.Exec(`CREATE EXTENSION IF NOT EXISTS pg_trgm`).Error; err != nil {
+		logrus.Warnf("SetupFullTextSearch: failed to create extension pg_trgm: %v", err)
+	}
+
+	var known int64
+	if !searchConfigName.MatchString(language) {
+		language = ""
+	}
+	if err := db.Raw(`SELECT COUNT(*) FROM pg_ts_config WHERE cfgname = ?`, language).Scan(&known).Error; err != nil {
+		return "", err
+	}
+	if known == 0 {
+		logrus.Warnf("SetupFullTextSearch: unknown text search configuration %q, using simple", language)
+		language = "simple"
+	}
+
+	columns := []struct {
+		table string
+		expr  string
+	}{
+		{"profiles", fmt.Sprintf(
+			`setweight(to_tsvector('simple'::regconfig, coalesce(first_name, '') || ' ' || coalesce(last_name, '')), 'A') ||
+			 setweight(to_tsvector('%[1]s'::regconfig, coalesce(about, '')), 'B')`, language)},
+		{"bios", fmt.Sprintf(
+			`setweight(to_tsvector('%[1]s'::regconfig, coalesce(looking_for, '')), 'B')`, language)},
+	}
+	for _, c := range columns {
+		var current *string
+		if err := db.Raw(`
+			SELECT col_description(a.attrelid, a.attnum)
+			FROM pg_attribute a
+			WHERE a.attrelid = ?::regclass AND a.attname = 'search_tsv' AND NOT a.attisdropped
+		`, c.table).Scan(&current).Error; err != nil {
+			return "", err
+		}
+		if current != nil && *current == language {
+			continue
+		}
+		// Missing, or built for another language: (re)create the generated column
+		stmts := []string{
+			fmt.Sprintf(`ALTER TABLE %s DROP COLUMN IF EXISTS search_tsv`, c.table),
+			fmt.Sprintf(`ALTER TABLE %s ADD COLUMN search_tsv tsvector GENERATED ALWAYS AS (%s) STORED`, c.table, c.expr),
+			fmt.Sprintf(`COMMENT ON COLUMN %s.search_tsv IS '%s'`, c.table, language),
+			fmt.Sprintf(`CREATE INDEX IF NOT EXISTS idx_%[1]s_search_tsv ON %[1]s USING GIN (search_tsv)`, c.table),
+		}
+		for _, stmt := range stmts {
+			if err := db.Exec(stmt).Error; err != nil {
+				return "", fmt.Errorf("SetupFullTextSearch: %s: %w", c.table, err)
+			}
+		}
+		logrus.Infof("SetupFullTextSearch: %s.search_tsv built for %q", c.table, language)
+	}
+
+	if err := db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_profiles_full_name_trgm
+		ON profiles USING GIN ((coalesce(first_name, '') || ' ' || coalesce(last_name, '')) gin_trgm_ops)
+	`).Error; err != nil {
+		logrus.Warnf("SetupFullTextSearch: failed to create trigram index: %v", err)
+	}
+	return language, nil
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
@@ -18,3 +20,14 @@ func UpdateUserOnlineStatus(db *gorm.DB, userID uuid.UUID, isOnline bool) error
 	logrus.Infof("UpdateUserOnlineStatus: status of user %s updated to %v", userID, isOnline)
 	return nil
 }
+
+// LastActiveResolution is how stale Profile.LastActiveAt may get before activity is written
+// again, so frequent heartbeats do not turn into a write each.
+const LastActiveResolution = time.Minute
+
+// UpdateLastActive records that the user was active at the given time.
+func UpdateLastActive(db *gorm.DB, userID uuid.UUID, at time.Time) error {
+	return db.Model(&Profile{}).
+		Where("user_id = ? AND (last_active_at IS NULL OR last_active_at < ?)", userID, at.Add(-LastActiveResolution)).
+		Update("last_active_at", at).Error
+}
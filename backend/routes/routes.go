@@ -26,6 +26,15 @@ func InitRoutes(router *mux.Router, db *gorm.DB, ps *services.PresenceService) {
 	controllers.InitAuthenticationController(db)
 	controllers.InitPreferencesController(db)
 	controllers.InitCitiesController(db)
+	controllers.InitExperimentsController(db)
+	controllers.InitLocationsController(db)
+	controllers.InitSavedSearchesController(db)
+	controllers.InitNotificationsController(db)
+	controllers.InitSearchController(db)
+	controllers.InitDigestController(db)
+	controllers.InitQuestionnaireController(db)
+	controllers.InitEventsController(db)
+	controllers.InitCommunitiesController(db)
 	presenceCtrl := controllers.NewPresenceController(ps)
 
 	// Public routes (no authentication required)
@@ -33,6 +42,7 @@ func InitRoutes(router *mux.Router, db *gorm.DB, ps *services.PresenceService) {
 	router.HandleFunc("/refresh", controllers.RefreshToken).Methods(http.MethodPost)
 	router.HandleFunc("/login", controllers.Login).Methods(http.MethodPost)
 	router.HandleFunc("/cities", controllers.GetCities).Methods(http.MethodGet)
+	router.HandleFunc("/cities/communities", controllers.GetCityCommunityStats).Methods(http.MethodGet)
 
 	// Presence status endpoints
 	router.HandleFunc("/api/user/online", presenceCtrl.GetOnlineStatus).Methods("GET")
@@ -46,14 +56,21 @@ func InitRoutes(router *mux.Router, db *gorm.DB, ps *services.PresenceService) {
 	authRouter.HandleFunc("/users/{id}", controllers.GetUser).Methods(http.MethodGet)
 	authRouter.HandleFunc("/users/{id}/bio", controllers.GetUserBio).Methods(http.MethodGet)
 	authRouter.HandleFunc("/users/{id}/profile", controllers.GetUserProfile).Methods(http.MethodGet)
+	authRouter.HandleFunc("/users/{id}/compatibility", controllers.GetCompatibility).Methods(http.MethodGet)
 
 	authRouter.HandleFunc("/me", controllers.GetCurrentUser).Methods(http.MethodGet)
 	authRouter.HandleFunc("/me/profile", controllers.GetCurrentUserProfile).Methods(http.MethodGet)
+	authRouter.HandleFunc("/me/profile/completeness", controllers.GetProfileCompleteness).Methods(http.MethodGet)
 	authRouter.HandleFunc("/me/bio", controllers.GetCurrentUserBio).Methods(http.MethodGet)
 
 	authRouter.HandleFunc("/me/profile", controllers.UpdateCurrentUserProfile).Methods(http.MethodPut)
 	authRouter.HandleFunc("/me/bio", controllers.UpdateCurrentUserBio).Methods(http.MethodPut)
 	authRouter.HandleFunc("/me/location", controllers.UpdateCurrentUserLocation).Methods(http.MethodPut)
+	authRouter.HandleFunc("/me/locations", controllers.GetLocations).Methods(http.MethodGet)
+	authRouter.HandleFunc("/me/locations", controllers.CreateLocation).Methods(http.MethodPost)
+	authRouter.HandleFunc("/me/locations/{id}", controllers.UpdateLocation).Methods(http.MethodPut)
+	authRouter.HandleFunc("/me/locations/{id}", controllers.DeleteLocation).Methods(http.MethodDelete)
+	authRouter.HandleFunc("/me/locations/{id}/activate", controllers.ActivateLocation).Methods(http.MethodPost)
 
 	authRouter.HandleFunc("/me/photo", controllers.UploadUserPhoto).Methods(http.MethodPost)
 	authRouter.HandleFunc("/me/photo", controllers.DeleteUserPhoto).Methods(http.MethodDelete)
@@ -63,7 +80,12 @@ func InitRoutes(router *mux.Router, db *gorm.DB, ps *services.PresenceService) {
 
 	// Recommendation and connection routes
 	authRouter.HandleFunc("/recommendations", controllers.GetRecommendations).Methods(http.MethodGet)
+	authRouter.HandleFunc("/recommendations/declined", controllers.GetDeclinedRecommendations).Methods(http.MethodGet)
 	authRouter.HandleFunc("/recommendations/{id}/decline", controllers.DeclineRecommendation).Methods(http.MethodPost)
+	authRouter.HandleFunc("/recommendations/{id}/decline", controllers.UndoDeclineRecommendation).Methods(http.MethodDelete)
+	authRouter.HandleFunc("/recommendations/{id}/like", controllers.LikeRecommendation).Methods(http.MethodPost)
+	authRouter.HandleFunc("/likes/received", controllers.GetReceivedLikes).Methods(http.MethodGet)
+	authRouter.HandleFunc("/recommendations/{id}/explain", controllers.ExplainRecommendation).Methods(http.MethodGet)
 	authRouter.HandleFunc("/connections", controllers.GetConnections).Methods(http.MethodGet)
 	authRouter.HandleFunc("/connections/pending", controllers.GetPendingConnections).Methods(http.MethodGet)
 	authRouter.HandleFunc("/connections/sent", controllers.GetSentConnections).Methods(http.MethodGet)
@@ -74,8 +96,57 @@ func InitRoutes(router *mux.Router, db *gorm.DB, ps *services.PresenceService) {
 	authRouter.HandleFunc("/chats", controllers.GetChats).Methods(http.MethodGet)
 	authRouter.HandleFunc("/chats/{chatId}", controllers.GetChatHistory).Methods(http.MethodGet)
 	authRouter.HandleFunc("/chats/{chatId}/messages", controllers.PostMessage).Methods(http.MethodPost)
+	authRouter.HandleFunc("/chats/{chatId}/messages/{id}", controllers.EditMessage).Methods(http.MethodPatch)
+	authRouter.HandleFunc("/chats/{chatId}/messages/{id}", controllers.DeleteMessage).Methods(http.MethodDelete)
+	authRouter.HandleFunc("/chats/{chatId}/messages/{id}/history", controllers.GetMessageHistory).Methods(http.MethodGet)
+	authRouter.HandleFunc("/chats/groups", controllers.CreateGroupChat).Methods(http.MethodPost)
+	authRouter.HandleFunc("/chats/{chatId}", controllers.UpdateGroupChat).Methods(http.MethodPut)
+	authRouter.HandleFunc("/chats/{chatId}/avatar", controllers.UploadChatAvatar).Methods(http.MethodPost)
+	authRouter.HandleFunc("/chats/{chatId}/read", controllers.MarkChatRead).Methods(http.MethodPost)
+	authRouter.HandleFunc("/chats/{chatId}/participants", controllers.GetChatParticipants).Methods(http.MethodGet)
+	authRouter.HandleFunc("/chats/{chatId}/participants", controllers.AddChatParticipants).Methods(http.MethodPost)
+	authRouter.HandleFunc("/chats/{chatId}/participants/{userId}", controllers.UpdateChatParticipant).Methods(http.MethodPut)
+	authRouter.HandleFunc("/chats/{chatId}/participants/{userId}", controllers.RemoveChatParticipant).Methods(http.MethodDelete)
+	authRouter.HandleFunc("/me/searches", controllers.GetSavedSearches).Methods(http.MethodGet)
+	authRouter.HandleFunc("/me/searches", controllers.CreateSavedSearch).Methods(http.MethodPost)
+	authRouter.HandleFunc("/me/searches/{id}", controllers.DeleteSavedSearch).Methods(http.MethodDelete)
+	authRouter.HandleFunc("/me/searches/{id}/results", controllers.GetSavedSearchResults).Methods(http.MethodGet)
+	authRouter.HandleFunc("/notifications", controllers.GetNotifications).Methods(http.MethodGet)
+	authRouter.HandleFunc("/search/users", controllers.SearchUsers).Methods(http.MethodGet)
+	authRouter.HandleFunc("/me/digest", controllers.GetDigest).Methods(http.MethodGet)
 	authRouter.HandleFunc("/me/preferences", controllers.GetPreferences).Methods(http.MethodGet)
 	authRouter.HandleFunc("/me/preferences", controllers.UpdatePreferences).Methods(http.MethodPut)
+	authRouter.HandleFunc("/recommendations/events", controllers.GetEventRecommendations).Methods(http.MethodGet)
+	authRouter.HandleFunc("/events", controllers.GetEvents).Methods(http.MethodGet)
+	authRouter.HandleFunc("/events", controllers.CreateEvent).Methods(http.MethodPost)
+	authRouter.HandleFunc("/me/events", controllers.GetMyEvents).Methods(http.MethodGet)
+	authRouter.HandleFunc("/events/{id}", controllers.GetEvent).Methods(http.MethodGet)
+	authRouter.HandleFunc("/events/{id}", controllers.UpdateEvent).Methods(http.MethodPut)
+	authRouter.HandleFunc("/events/{id}", controllers.CancelEvent).Methods(http.MethodDelete)
+	authRouter.HandleFunc("/events/{id}/rsvp", controllers.RSVPEvent).Methods(http.MethodPost)
+	authRouter.HandleFunc("/events/{id}/rsvp", controllers.LeaveEvent).Methods(http.MethodDelete)
+	authRouter.HandleFunc("/events/{id}/attendees", controllers.GetEventAttendees).Methods(http.MethodGet)
+	authRouter.HandleFunc("/events/{id}/messages", controllers.GetEventMessages).Methods(http.MethodGet)
+	authRouter.HandleFunc("/events/{id}/messages", controllers.PostEventMessage).Methods(http.MethodPost)
+	authRouter.HandleFunc("/events/{id}/recommendations", controllers.GetEventRecommendations).Methods(http.MethodGet)
+	authRouter.HandleFunc("/communities", controllers.GetCommunities).Methods(http.MethodGet)
+	authRouter.HandleFunc("/communities", controllers.CreateCommunity).Methods(http.MethodPost)
+	authRouter.HandleFunc("/communities/suggested", controllers.GetSuggestedCommunities).Methods(http.MethodGet)
+	authRouter.HandleFunc("/me/communities", controllers.GetMyCommunities).Methods(http.MethodGet)
+	authRouter.HandleFunc("/communities/{id}", controllers.GetCommunity).Methods(http.MethodGet)
+	authRouter.HandleFunc("/communities/{id}", controllers.UpdateCommunity).Methods(http.MethodPut)
+	authRouter.HandleFunc("/communities/{id}", controllers.DeleteCommunity).Methods(http.MethodDelete)
+	authRouter.HandleFunc("/communities/{id}/membership", controllers.JoinCommunity).Methods(http.MethodPost)
+	authRouter.HandleFunc("/communities/{id}/membership", controllers.LeaveCommunity).Methods(http.MethodDelete)
+	authRouter.HandleFunc("/communities/{id}/members", controllers.GetCommunityMembers).Methods(http.MethodGet)
+	authRouter.HandleFunc("/communities/{id}/members/{userId}", controllers.UpdateCommunityMember).Methods(http.MethodPut)
+	authRouter.HandleFunc("/communities/{id}/members/{userId}", controllers.RemoveCommunityMember).Methods(http.MethodDelete)
+	authRouter.HandleFunc("/communities/{id}/posts", controllers.GetCommunityPosts).Methods(http.MethodGet)
+	authRouter.HandleFunc("/communities/{id}/posts", controllers.CreateCommunityPost).Methods(http.MethodPost)
+	authRouter.HandleFunc("/communities/{id}/posts/{postId}", controllers.DeleteCommunityPost).Methods(http.MethodDelete)
+	authRouter.HandleFunc("/questions", controllers.GetQuestions).Methods(http.MethodGet)
+	authRouter.HandleFunc("/questions/{id}/answer", controllers.AnswerQuestion).Methods(http.MethodPut)
+	authRouter.HandleFunc("/questions/{id}/answer", controllers.DeleteAnswer).Methods(http.MethodDelete)
 
 	// Admin-only routes (require AdminOnly middleware)
 	adminOnly := middleware.AdminOnly(db)
@@ -84,6 +155,14 @@ func InitRoutes(router *mux.Router, db *gorm.DB, ps *services.PresenceService) {
 
 	adminRouter.HandleFunc("/reset-fixtures", controllers.ResetFixtures).Methods(http.MethodPost)
 	adminRouter.HandleFunc("/generate-fixtures", controllers.GenerateFixtures).Methods(http.MethodPost)
+	adminRouter.HandleFunc("/rebuild-similarity", controllers.RebuildSimilarity).Methods(http.MethodPost)
+	adminRouter.HandleFunc("/experiments", controllers.CreateExperiment).Methods(http.MethodPost)
+	adminRouter.HandleFunc("/experiments", controllers.GetExperiments).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/experiments/{id}", controllers.UpdateExperiment).Methods(http.MethodPut)
+	adminRouter.HandleFunc("/experiments/{id}/metrics", controllers.GetExperimentMetrics).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/questions", controllers.CreateQuestion).Methods(http.MethodPost)
+	adminRouter.HandleFunc("/questions", controllers.GetAdminQuestions).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/questions/{id}", controllers.UpdateQuestion).Methods(http.MethodPut)
 
 	logrus.Info("Routes successfully initialized")
 }
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Presence-aware ranking. Online status comes from PresenceService (one batched Redis
+// call per request) and, without Redis or when the Redis call fails, from
+// Profile.LastActiveAt. With the activity boost on, a candidate's score is multiplied by
+// a factor that is 1 while online and decays with the days since the last activity,
+// halving the distance to activityFloor every activityHalfLifeDays, so abandoned
+// accounts sink below active ones without disappearing. OnlineOnly drops everyone offline.
+
+const (
+	// DefaultActivityHalfLifeDays is used when the boost is requested but disabled server-wide.
+	DefaultActivityHalfLifeDays = 14.0
+	// activityFloor is the factor of accounts that were never or very long ago active.
+	activityFloor = 0.5
+	// onlineFallbackWindow counts a user as online from LastActiveAt when presence is unavailable.
+	onlineFallbackWindow = 5 * time.Minute
+)
+
+var activityHalfLifeDays float64
+
+// SetActivityHalfLife sets the half-life in days of the recently-active boost. 0 disables it.
+func SetActivityHalfLife(days float64) {
+	if days < 0 {
+		days = 0
+	}
+	activityHalfLifeDays = days
+}
+
+// Activity is the presence of a candidate.
+type Activity struct {
+	Online       bool
+	LastActiveAt *time.Time
+}
+
+// WithOnlineOnly returns a copy of the service that only recommends online users.
+func (rs *RecommendationService) WithOnlineOnly() *RecommendationService {
+	c := *rs
+	c.OnlineOnly = true
+	return &c
+}
+
+// WithActivityBoost returns a copy of the service with the recently-active boost turned
+// on or off for this request.
+func (rs *RecommendationService) WithActivityBoost(on bool) *RecommendationService {
+	c := *rs
+	c.ActivityBoost = &on
+	return &c
+}
+
+// activityHalfLife returns the boost half-life of this service, 0 when the boost is off.
+func (rs *RecommendationService) activityHalfLife() float64 {
+	if rs.ActivityBoost == nil {
+		return activityHalfLifeDays
+	}
+	if !*rs.ActivityBoost {
+		return 0
+	}
+	if activityHalfLifeDays > 0 {
+		return activityHalfLifeDays
+	}
+	return DefaultActivityHalfLifeDays
+}
+
+// activityOf returns the presence of the candidates. Skipped (empty map) when neither
+// the boost nor the online filter is in use.
+func (rs *RecommendationService) activityOf(ids []uuid.UUID, now time.Time) (map[uuid.UUID]Activity, error) {
+	out := make(map[uuid.UUID]Activity, len(ids))
+	if rs.DB == nil || len(ids) == 0 || (!rs.OnlineOnly && rs.activityHalfLife() == 0) {
+		return out, nil
+	}
+	var rows []struct {
+		UserID       uuid.UUID
+		LastActiveAt *time.Time
+	}
+	if err := rs.DB.Raw(`SELECT user_id, last_active_at FROM profiles WHERE user_id IN ?`, ids).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	var online map[string]bool
+	if rs.Presence != nil {
+		keys := make([]string, len(ids))
+		for i, id := range ids {
+			keys[i] = id.String()
+		}
+		var err error
+		if online, err = rs.Presence.OnlineMany(keys); err != nil {
+			// Ranking must not fail with Redis; fall back to the last activity
+			logrus.Warnf("activityOf: presence unavailable, using last activity: %v", err)
+			online = nil
+		}
+	}
+	for _, r := range rows {
+		a := Activity{LastActiveAt: r.LastActiveAt}
+		if online != nil {
+			a.Online = online[r.UserID.String()]
+		} else {
+			a.Online = r.LastActiveAt != nil && now.Sub(*r.LastActiveAt) < onlineFallbackWindow
+		}
+		out[r.UserID] = a
+	}
+	return out, nil
+}
+
+// activityFactor returns the score multiplier of a candidate, 1 when the boost is off.
+func (rs *RecommendationService) activityFactor(a Activity, now time.Time) float64 {
+	halfLife := rs.activityHalfLife()
+	if halfLife <= 0 || a.Online {
+		return 1
+	}
+	if a.LastActiveAt == nil {
+		return activityFloor
+	}
+	days := now.Sub(*a.LastActiveAt).Hours() / 24
+	if days < 0 {
+		days = 0
+	}
+	return activityFloor + (1-activityFloor)*math.Pow(0.5, days/halfLife)
+}
+
+// applyActivity reports whether the candidate passes the online filter and returns its
+// score with the activity boost applied, recording the factor in expl when given.
+func (rs *RecommendationService) applyActivity(score float64, a Activity, now time.Time,
+	expl *RecommendationExplanation) (float64, bool) {
+	if rs.OnlineOnly && !a.Online {
+		return 0, false
+	}
+	f := rs.activityFactor(a, now)
+	if expl != nil && f != 1 {
+		expl.ActivityFactor = f
+	}
+	return score * f, true
+}
@@ -3,7 +3,9 @@ package services
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"m/backend/models"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,6 +13,40 @@ import (
 	"gorm.io/gorm"
 )
 
+// Chats are direct (two users) or groups. Who is in a chat is stored in
+// models.ChatParticipant for both kinds; a group is created by its owner from their
+// accepted connections, and the owner and admins add and remove members. Every
+// participant has their own read position: a message counts as read (Message.Read)
+// once all other participants have read it.
+
+var (
+	ErrInvalidChat    = errors.New("invalid chat")
+	ErrChatForbidden  = errors.New("not allowed in this chat")
+	ErrNotParticipant = errors.New("not a participant of the chat")
+	ErrNotConnected   = errors.New("only accepted connections can be added")
+)
+
+const (
+	// Roles of models.ChatParticipant.
+	ChatOwner  = "owner"
+	ChatAdmin  = "admin"
+	ChatMember = "member"
+
+	// MaxGroupParticipants caps the size of a group chat, owner included.
+	MaxGroupParticipants = 50
+)
+
+// ChatParticipantView is a participant with the public part of their profile.
+type ChatParticipantView struct {
+	UserID            uuid.UUID `json:"userId"`
+	Role              string    `json:"role"`
+	FirstName         string    `json:"firstName"`
+	LastName          string    `json:"lastName"`
+	PhotoURL          string    `json:"photoUrl"`
+	LastReadMessageID uint      `json:"lastReadMessageId"`
+	JoinedAt          time.Time `json:"joinedAt"`
+}
+
 type ChatService struct {
 	DB *gorm.DB
 }
@@ -20,6 +56,7 @@ func NewChatService(db *gorm.DB) *ChatService {
 	return &ChatService{DB: db}
 }
 
+// CreateChat returns the direct chat of two users, creating it with both as participants.
 func (cs *ChatService) CreateChat(user1ID, user2ID uuid.UUID) (*models.Chat, error) {
 	// Check if a chat already exists between these two users (in any order)
 	var chat models.Chat
@@ -37,9 +74,13 @@ func (cs *ChatService) CreateChat(user1ID, user2ID uuid.UUID) (*models.Chat, err
 
 	// If no chat exists, create a new one
 	chat = models.Chat{
-		User1ID:   user1ID,
-		User2ID:   user2ID,
+		User1ID:   &user1ID,
+		User2ID:   &user2ID,
 		CreatedAt: time.Now(),
+		Participants: []models.ChatParticipant{
+			{UserID: user1ID, Role: ChatMember},
+			{UserID: user2ID, Role: ChatMember},
+		},
 	}
 	if err := cs.DB.Create(&chat).Error; err != nil {
 		logrus.Errorf("CreateChat: error creating new chat: %v", err)
@@ -83,3 +124,358 @@ func TypingNotification(userID uuid.UUID, chatID uint, isTyping bool) ([]byte, e
 	logrus.Debugf("TypingNotification: typing notification created for user %s in chat %d", userID, chatID)
 	return data, nil
 }
+
+// Participant returns the user's membership of a chat: gorm.ErrRecordNotFound when the
+// chat does not exist, ErrNotParticipant when the user is not in it.
+func (cs *ChatService) Participant(chatID uint, userID uuid.UUID) (*models.ChatParticipant, error) {
+	if err := cs.DB.First(&models.Chat{}, chatID).Error; err != nil {
+		return nil, err
+	}
+	var p models.ChatParticipant
+	err := cs.DB.Where("chat_id = ? AND user_id = ?", chatID, userID).First(&p).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotParticipant
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ParticipantIDs returns the users in a chat.
+func (cs *ChatService) ParticipantIDs(chatID uint) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := cs.DB.Model(&models.ChatParticipant{}).Where("chat_id = ?", chatID).Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+// Participants lists the owner, the admins and then the members of a chat in join
+// order. Only participants see the list.
+func (cs *ChatService) Participants(chatID uint, userID uuid.UUID) ([]ChatParticipantView, error) {
+	if _, err := cs.Participant(chatID, userID); err != nil {
+		return nil, err
+	}
+	var list []ChatParticipantView
+	err := cs.DB.Raw(`
+		SELECT cp.user_id, cp.role, p.first_name, p.last_name, p.photo_url, cp.last_read_message_id, cp.joined_at
+		FROM chat_participants cp
+		LEFT JOIN profiles p ON p.user_id = cp.user_id
+		WHERE cp.chat_id = ?
+		ORDER BY CASE cp.role WHEN 'owner' THEN 0 WHEN 'admin' THEN 1 ELSE 2 END, cp.joined_at, cp.id
+	`, chatID).Scan(&list).Error
+	return list, err
+}
+
+// checkConnected returns ErrNotConnected unless every user is an accepted connection of userID.
+func (cs *ChatService) checkConnected(userID uuid.UUID, others []uuid.UUID) error {
+	if len(others) == 0 {
+		return nil
+	}
+	var n int64
+	if err := cs.DB.Raw(`
+		SELECT COUNT(DISTINCT other) FROM (
+		  SELECT connection_id AS other FROM connections WHERE user_id = ? AND status = 'accepted'
+		  UNION
+		  SELECT user_id FROM connections WHERE connection_id = ? AND status = 'accepted'
+		) c WHERE other IN ?
+	`, userID, userID, others).Scan(&n).Error; err != nil {
+		return err
+	}
+	if int(n) != len(others) {
+		return ErrNotConnected
+	}
+	return nil
+}
+
+// newMembers drops duplicates, the actor and users already in the chat.
+func (cs *ChatService) newMembers(chatID uint, actorID uuid.UUID, userIDs []uuid.UUID) ([]uuid.UUID, error) {
+	var existing []uuid.UUID
+	if chatID != 0 {
+		var err error
+		if existing, err = cs.ParticipantIDs(chatID); err != nil {
+			return nil, err
+		}
+	}
+	seen := map[uuid.UUID]bool{actorID: true}
+	for _, id := range existing {
+		seen[id] = true
+	}
+	var out []uuid.UUID
+	for _, id := range userIDs {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	if len(existing)+len(out) > MaxGroupParticipants {
+		return nil, fmt.Errorf("%w: a group has at most %d participants", ErrInvalidChat, MaxGroupParticipants)
+	}
+	return out, nil
+}
+
+// groupName validates the name of a group chat.
+func groupName(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || len(s) > 100 {
+		return "", fmt.Errorf("%w: name must be 1-100 characters", ErrInvalidChat)
+	}
+	return s, nil
+}
+
+// CreateGroup creates a group chat owned by ownerID with some of their accepted
+// connections, who get a chat_added event.
+func (cs *ChatService) CreateGroup(ownerID uuid.UUID, name string, memberIDs []uuid.UUID) (*models.Chat, error) {
+	name, err := groupName(name)
+	if err != nil {
+		return nil, err
+	}
+	members, err := cs.newMembers(0, ownerID, memberIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return nil, fmt.Errorf("%w: a group needs at least one other participant", ErrInvalidChat)
+	}
+	if err := cs.checkConnected(ownerID, members); err != nil {
+		return nil, err
+	}
+	chat := models.Chat{
+		IsGroup:      true,
+		Name:         name,
+		Participants: []models.ChatParticipant{{UserID: ownerID, Role: ChatOwner}},
+	}
+	for _, id := range members {
+		chat.Participants = append(chat.Participants, models.ChatParticipant{UserID: id, Role: ChatMember})
+	}
+	if err := cs.DB.Create(&chat).Error; err != nil {
+		return nil, err
+	}
+	cs.notifyMembers(chat, members, "chat_added", ownerID)
+	logrus.Infof("CreateGroup: group chat %d created by %s with %d members", chat.ID, ownerID, len(members))
+	return &chat, nil
+}
+
+// notifyMembers sends a chat membership event to users.
+func (cs *ChatService) notifyMembers(chat models.Chat, users []uuid.UUID, eventType string, actorID uuid.UUID) {
+	ns := NewNotificationService(cs.DB)
+	for _, id := range users {
+		if err := ns.Notify(id, eventType, map[string]interface{}{
+			"chat_id": chat.ID,
+			"name":    chat.Name,
+			"by":      actorID.String(),
+		}); err != nil {
+			logrus.Errorf("ChatService: notifying %s about chat %d failed: %v", id, chat.ID, err)
+		}
+	}
+}
+
+// group returns a group chat and the actor's role in it. Direct chats are ErrInvalidChat.
+func (cs *ChatService) group(chatID uint, actorID uuid.UUID) (*models.Chat, string, error) {
+	p, err := cs.Participant(chatID, actorID)
+	if err != nil {
+		return nil, "", err
+	}
+	var chat models.Chat
+	if err := cs.DB.First(&chat, chatID).Error; err != nil {
+		return nil, "", err
+	}
+	if !chat.IsGroup {
+		return nil, "", fmt.Errorf("%w: not a group chat", ErrInvalidChat)
+	}
+	return &chat, p.Role, nil
+}
+
+// CheckManager returns ErrChatForbidden unless the user is the owner or an admin of the group.
+func (cs *ChatService) CheckManager(chatID uint, userID uuid.UUID) error {
+	_, role, err := cs.group(chatID, userID)
+	if err != nil {
+		return err
+	}
+	if role != ChatOwner && role != ChatAdmin {
+		return ErrChatForbidden
+	}
+	return nil
+}
+
+// UpdateGroup renames a group or changes its avatar. Owner and admins only; nil
+// fields are left unchanged.
+func (cs *ChatService) UpdateGroup(chatID uint, actorID uuid.UUID, name, avatarURL *string) (*models.Chat, error) {
+	chat, role, err := cs.group(chatID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if role != ChatOwner && role != ChatAdmin {
+		return nil, ErrChatForbidden
+	}
+	if name != nil {
+		if chat.Name, err = groupName(*name); err != nil {
+			return nil, err
+		}
+	}
+	if avatarURL != nil {
+		if len(*avatarURL) > 255 {
+			return nil, fmt.Errorf("%w: avatarUrl is limited to 255 characters", ErrInvalidChat)
+		}
+		chat.AvatarURL = strings.TrimSpace(*avatarURL)
+	}
+	if err := cs.DB.Model(chat).Select("name", "avatar_url").Updates(chat).Error; err != nil {
+		return nil, err
+	}
+	return chat, nil
+}
+
+// AddParticipants adds accepted connections of the actor to a group. Owner and admins
+// only. Returns the users actually added, who get a chat_added event.
+func (cs *ChatService) AddParticipants(chatID uint, actorID uuid.UUID, userIDs []uuid.UUID) ([]uuid.UUID, error) {
+	chat, role, err := cs.group(chatID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if role != ChatOwner && role != ChatAdmin {
+		return nil, ErrChatForbidden
+	}
+	added, err := cs.newMembers(chatID, actorID, userIDs)
+	if err != nil || len(added) == 0 {
+		return []uuid.UUID{}, err
+	}
+	if err := cs.checkConnected(actorID, added); err != nil {
+		return nil, err
+	}
+	rows := make([]models.ChatParticipant, len(added))
+	for i, id := range added {
+		rows[i] = models.ChatParticipant{ChatID: chatID, UserID: id, Role: ChatMember}
+	}
+	if err := cs.DB.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	cs.notifyMembers(*chat, added, "chat_added", actorID)
+	return added, nil
+}
+
+// RemoveParticipant removes someone from a group: anyone can leave, the owner removes
+// anyone else and admins only members. When the owner leaves, the longest-standing
+// admin (or member) becomes the owner; a group left empty is deleted.
+func (cs *ChatService) RemoveParticipant(chatID uint, actorID, userID uuid.UUID) error {
+	chat, actorRole, err := cs.group(chatID, actorID)
+	if err != nil {
+		return err
+	}
+	target, err := cs.Participant(chatID, userID)
+	if err != nil {
+		return err
+	}
+	if actorID != userID {
+		allowed := (actorRole == ChatOwner) || (actorRole == ChatAdmin && target.Role == ChatMember)
+		if !allowed {
+			return ErrChatForbidden
+		}
+	}
+	err = cs.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(target).Error; err != nil {
+			return err
+		}
+		if target.Role != ChatOwner {
+			return nil
+		}
+		var next models.ChatParticipant
+		err := tx.Where("chat_id = ?", chatID).
+			Order("role = 'admin' DESC, joined_at, id").
+			First(&next).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return tx.Delete(chat).Error
+		} else if err != nil {
+			return err
+		}
+		return tx.Model(&next).Update("role", ChatOwner).Error
+	})
+	if err != nil {
+		return err
+	}
+	if actorID != userID {
+		cs.notifyMembers(*chat, []uuid.UUID{userID}, "chat_removed", actorID)
+	}
+	return nil
+}
+
+// SetParticipantRole makes a member an admin or a plain member again. Owner only.
+func (cs *ChatService) SetParticipantRole(chatID uint, actorID, userID uuid.UUID, role string) error {
+	if role != ChatAdmin && role != ChatMember {
+		return fmt.Errorf("%w: role must be %s or %s", ErrInvalidChat, ChatAdmin, ChatMember)
+	}
+	_, actorRole, err := cs.group(chatID, actorID)
+	if err != nil {
+		return err
+	}
+	if actorRole != ChatOwner {
+		return ErrChatForbidden
+	}
+	if actorID == userID {
+		return fmt.Errorf("%w: the owner's role cannot be changed", ErrInvalidChat)
+	}
+	res := cs.DB.Model(&models.ChatParticipant{}).
+		Where("chat_id = ? AND user_id = ?", chatID, userID).
+		Update("role", role)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotParticipant
+	}
+	return nil
+}
+
+// MarkRead moves the user's read position forward to upTo (the newest message when 0)
+// and returns the new position with the messages that became read by everyone.
+func (cs *ChatService) MarkRead(chatID uint, userID uuid.UUID, upTo uint) (uint, []uint, error) {
+	p, err := cs.Participant(chatID, userID)
+	if err != nil {
+		return 0, nil, err
+	}
+	var newest uint
+	if err := cs.DB.Model(&models.Message{}).
+		Select("COALESCE(MAX(id), 0)").
+		Where("chat_id = ?", chatID).
+		Scan(&newest).Error; err != nil {
+		return 0, nil, err
+	}
+	if upTo == 0 || upTo > newest {
+		upTo = newest
+	}
+	if upTo <= p.LastReadMessageID {
+		return p.LastReadMessageID, nil, nil
+	}
+	now := time.Now()
+	if err := cs.DB.Model(p).Updates(map[string]interface{}{
+		"last_read_message_id": upTo,
+		"last_read_at":         now,
+	}).Error; err != nil {
+		return 0, nil, err
+	}
+	var read []uint
+	if err := cs.DB.Raw(`
+		UPDATE messages m SET read = true
+		WHERE m.chat_id = ? AND NOT m.read AND m.id <= ?
+		  AND NOT EXISTS (
+		    SELECT 1 FROM chat_participants p
+		    WHERE p.chat_id = m.chat_id AND p.user_id <> m.sender_id AND p.last_read_message_id < m.id
+		  )
+		RETURNING m.id
+	`, chatID, upTo).Scan(&read).Error; err != nil {
+		return 0, nil, err
+	}
+	return upTo, read, nil
+}
+
+// UnreadCount counts the messages of others after the user's read position, leaving
+// out deleted messages and those the user hid.
+func (cs *ChatService) UnreadCount(chatID uint, userID uuid.UUID) (int64, error) {
+	var n int64
+	err := cs.DB.Raw(`
+		SELECT COUNT(*) FROM messages m
+		JOIN chat_participants p ON p.chat_id = m.chat_id AND p.user_id = ?
+		WHERE m.chat_id = ? AND m.sender_id <> ? AND m.id > p.last_read_message_id
+		  AND m.deleted_at IS NULL
+		  AND NOT EXISTS (SELECT 1 FROM hidden_messages h WHERE h.message_id = m.id AND h.user_id = p.user_id)
+	`, userID, chatID, userID).Scan(&n).Error
+	return n, err
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"time"
+
+	"m/backend/models"
+
+	"github.com/google/uuid"
+)
+
+// Cold start: users whose profile is incomplete (see profile_completeness.go) still get
+// recommendations. Candidates are the nearby users (within MaxRadius, at least
+// coldStartRadiusKm) or, without a location, the most popular users anywhere. They are
+// scored by the fields that are filled in, blended with popularity (likes received and
+// accepted connections), and zero scores are kept so a nearly empty profile still sees
+// the people around it. Explanations use the mode "cold-start".
+
+const (
+	// ColdStartMode is the explanation mode of cold-start recommendations.
+	ColdStartMode = "cold-start"
+	// coldStartRadiusKm is the smallest search radius in cold-start mode.
+	coldStartRadiusKm = 50.0
+	// coldStartContentWeight is the share of the content score; the rest is popularity.
+	coldStartContentWeight = 0.6
+	// popularityHalf is the number of likes and connections that gives a popularity of 0.5.
+	popularityHalf = 5.0
+)
+
+// popularity returns a popularity in [0, 1) for each candidate: n/(n+popularityHalf)
+// where n counts likes received and accepted connections.
+func (rs *RecommendationService) popularity(ids []uuid.UUID) (map[uuid.UUID]float64, error) {
+	out := make(map[uuid.UUID]float64, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	var rows []struct {
+		ID uuid.UUID
+		N  float64
+	}
+	err := rs.DB.Raw(`
+		SELECT id, COUNT(*) AS n FROM (
+		  SELECT rec_user_id AS id FROM recommendations WHERE rec_user_id IN ? AND status IN ('liked', 'matched')
+		  UNION ALL
+		  SELECT user_id FROM connections WHERE user_id IN ? AND status = 'accepted'
+		  UNION ALL
+		  SELECT connection_id FROM connections WHERE connection_id IN ? AND status = 'accepted'
+		) s
+		GROUP BY id
+	`, ids, ids, ids).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		out[r.ID] = r.N / (r.N + popularityHalf)
+	}
+	return out, nil
+}
+
+// popularUsers returns the most liked and connected users other than excludeID,
+// skipping users excludeID declined or liked. Dealbreakers, demographic filters and
+// mutual mode apply as in GetNearbyUsers; lat/lon stand for the user in the
+// candidates' radius check.
+func (rs *RecommendationService) popularUsers(excludeID uuid.UUID, lat, lon float64, limit int) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := rs.DB.Raw(`
+		SELECT p.user_id
+		FROM profiles p
+		LEFT JOIN (
+		  SELECT id, COUNT(*) AS n FROM (
+		    SELECT rec_user_id AS id FROM recommendations WHERE status IN ('liked', 'matched')
+		    UNION ALL
+		    SELECT user_id FROM connections WHERE status = 'accepted'
+		    UNION ALL
+		    SELECT connection_id FROM connections WHERE status = 'accepted'
+		  ) s GROUP BY id
+		) pop ON pop.id = p.user_id
+		LEFT JOIN bios cb ON cb.user_id = p.user_id
+		LEFT JOIN preferences cp ON cp.user_id = p.user_id
+		LEFT JOIN bios mb ON mb.user_id = ?
+		LEFT JOIN preferences mp ON mp.user_id = ?
+		LEFT JOIN profiles mf ON mf.user_id = ?
+		WHERE p.user_id <> ?
+		  AND `+nearbyDealbreakerCondition+`
+		  AND `+nearbyDemographicCondition+`
+		  AND `+nearbyMutualCondition+`
+		  AND NOT EXISTS (
+		    SELECT 1 FROM recommendations l
+		    WHERE l.user_id = ? AND l.rec_user_id = p.user_id AND l.status IN ('liked', 'matched')
+		  )
+		ORDER BY COALESCE(pop.n, 0) DESC, p.user_id
+		LIMIT ?
+	`, excludeID, excludeID, excludeID, excludeID, rs.Mutual, lat, lon, excludeID, limit).Scan(&ids).Error
+	return ids, err
+}
+
+// GetColdStartRecommendations returns up to limit recommendations for a user whose
+// profile is incomplete. A limit <= 0 returns every candidate.
+func (rs *RecommendationService) GetColdStartRecommendations(
+	currentUserID uuid.UUID, mode string, limit int,
+) ([]RecommendationWithDistance, error) {
+	if mode != "desire" {
+		mode = "affinity"
+	}
+	var me models.User
+	if err := rs.DB.
+		Preload("Profile").Preload("Bio").Preload("Preference").
+		First(&me, "id = ?", currentUserID).Error; err != nil {
+		return nil, err
+	}
+
+	distMap := make(map[uuid.UUID]float64)
+	var ids []uuid.UUID
+	hasLocation := me.Profile.ID != 0 && (me.Profile.Latitude != 0 || me.Profile.Longitude != 0)
+	if hasLocation {
+		radius := me.Preference.MaxRadius
+		if radius < coldStartRadiusKm {
+			radius = coldStartRadiusKm
+		}
+		nearby, err := rs.GetNearbyUsers(me.Profile.Latitude, me.Profile.Longitude, radius, rs.CandidateLimit, currentUserID)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range nearby {
+			ids = append(ids, n.ID)
+			distMap[n.ID] = n.Distance
+		}
+	} else {
+		popular, err := rs.popularUsers(currentUserID, me.Profile.Latitude, me.Profile.Longitude, rs.CandidateLimit)
+		if err != nil {
+			return nil, err
+		}
+		ids = popular
+	}
+	if len(ids) == 0 {
+		return []RecommendationWithDistance{}, nil
+	}
+
+	var users []models.User
+	if err := rs.DB.Preload("Bio").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	declined, err := rs.declinedSet(currentUserID)
+	if err != nil {
+		return nil, err
+	}
+	pop, err := rs.popularity(ids)
+	if err != nil {
+		return nil, err
+	}
+	compat, err := rs.compatibilityScores(currentUserID, ids)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := rs.sharedCommunities(currentUserID, ids)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	activity, err := rs.activityOf(ids, now)
+	if err != nil {
+		return nil, err
+	}
+
+	type outCand struct {
+		ID          uuid.UUID
+		Score       float64
+		Distance    float64
+		Online      bool
+		Explanation *RecommendationExplanation
+		Bio         models.Bio
+	}
+	var cands []outCand
+	for _, u := range users {
+		if _, ok := declined[u.ID]; ok {
+			continue
+		}
+		d := distMap[u.ID]
+		content, expl := rs.scoreCandidate(mode, me.Bio, me.Preference, u.Bio, d, 0.05)
+		expl.Mode = ColdStartMode
+		expl.ContentScore = content
+		expl.Popularity = pop[u.ID]
+		score := coldStartContentWeight*content + (1-coldStartContentWeight)*pop[u.ID]
+		score = blendCompatibility(score, compat, u.ID, expl)
+		score = boostCommunities(score, shared, u.ID, expl)
+		a := activity[u.ID]
+		score, ok := rs.applyActivity(score, a, now, expl)
+		if !ok {
+			continue
+		}
+		cands = append(cands, outCand{ID: u.ID, Score: score, Distance: d, Online: a.Online, Explanation: expl, Bio: u.Bio})
+	}
+
+	// Without a location every distance is 0, so rank by score
+	ranking := rs.rankingFor(me.Preference)
+	if !hasLocation {
+		ranking = RankingScore
+	}
+	order, demoted, err := rs.orderCandidates(currentUserID, ranking, cands,
+		func(i int) (models.Bio, float64, float64) { return cands[i].Bio, cands[i].Distance, cands[i].Score },
+		func(i int) uuid.UUID { return cands[i].ID })
+	if err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 || len(cands) < limit {
+		limit = len(cands)
+	}
+	out := make([]RecommendationWithDistance, limit)
+	for i := 0; i < limit; i++ {
+		c := cands[order[i]]
+		if demoted[c.ID] {
+			c.Explanation.ExposureCapped = true
+		}
+		out[i] = RecommendationWithDistance{
+			UserID:      c.ID,
+			Distance:    c.Distance,
+			Score:       c.Score,
+			Online:      c.Online,
+			Explanation: c.Explanation,
+		}
+	}
+	return out, nil
+}
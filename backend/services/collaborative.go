@@ -0,0 +1,226 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"m/backend/models"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+/*
+CollaborativeService — behavior-based similarity between users
+---------------------------------------------------------------
+Content scoring only looks at Bio fields. This service feeds behavior back into ranking:
+
+- Interactions: every (actor, target) pair gets a weight from accepted connections (+1 both ways),
+  likes/matches (+1), declines (-1) and messages sent in a shared direct chat (+0.1 each, up to +1).
+- Similarity: an offline job (Rebuild) computes item-item cosine similarity between targets over
+  the actors who interacted with them ("people who connected with A also connected with B"),
+  and stores the top neighbors per user in the user_similarities table.
+- Scoring: for the current user, the collaborative score of a candidate is the weighted average
+  similarity between the candidate and everyone the user interacted with, clamped to [0, 1].
+  blendScore mixes it with the content score using the configurable blend weight.
+*/
+
+// interactionsQuery yields (actor, target, weight) rows. Add a WHERE on actor to restrict it.
+const interactionsQuery = `
+	SELECT actor, target, SUM(w) AS weight FROM (
+	  SELECT user_id AS actor, connection_id AS target, 1.0 AS w FROM connections WHERE status = 'accepted'
+	  UNION ALL
+	  SELECT connection_id, user_id, 1.0 FROM connections WHERE status = 'accepted'
+	  UNION ALL
+	  SELECT user_id, rec_user_id, 1.0 FROM recommendations WHERE status IN ('liked', 'matched')
+	  UNION ALL
+	  SELECT user_id, rec_user_id, -1.0 FROM recommendations WHERE status = 'declined'
+	  UNION ALL
+	  SELECT sender, other, LEAST(COUNT(*), 10) * 0.1 FROM (
+	    SELECT m.sender_id AS sender,
+	      CASE WHEN c.user1_id = m.sender_id THEN c.user2_id ELSE c.user1_id END AS other
+	    FROM messages m JOIN chats c ON c.id = m.chat_id
+	    WHERE NOT c.is_group
+	  ) msgs GROUP BY sender, other
+	) i`
+
+type interaction struct {
+	Actor  uuid.UUID
+	Target uuid.UUID
+	Weight float64
+}
+
+// cfBlendWeight is the share of the collaborative score in the final score (0 = content only).
+var cfBlendWeight = 0.0
+
+// SetCollaborativeBlendWeight sets the blend weight, clamped to [0, 1].
+func SetCollaborativeBlendWeight(w float64) {
+	cfBlendWeight = math.Max(0, math.Min(1, w))
+}
+
+// CollaborativeService builds and stores user-user similarities from interaction history.
+// Neighbors is the number of most similar users kept per user.
+type CollaborativeService struct {
+	DB        *gorm.DB
+	Neighbors int
+}
+
+// NewCollaborativeService creates a service keeping up to neighbors similar users per user.
+func NewCollaborativeService(db *gorm.DB, neighbors int) *CollaborativeService {
+	if neighbors <= 0 {
+		neighbors = 50
+	}
+	logrus.Info("CollaborativeService initialized")
+	return &CollaborativeService{DB: db, Neighbors: neighbors}
+}
+
+// Rebuild recomputes all similarities and replaces the user_similarities table contents.
+func (cs *CollaborativeService) Rebuild() error {
+	started := time.Now()
+	var rows []interaction
+	if err := cs.DB.Raw(interactionsQuery + ` GROUP BY actor, target`).Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	// Target vectors over actors, and per-actor lists to enumerate co-occurring targets
+	norms := make(map[uuid.UUID]float64)
+	byActor := make(map[uuid.UUID][]interaction)
+	for _, r := range rows {
+		if r.Weight == 0 || r.Actor == r.Target {
+			continue
+		}
+		norms[r.Target] += r.Weight * r.Weight
+		byActor[r.Actor] = append(byActor[r.Actor], r)
+	}
+
+	dots := make(map[uuid.UUID]map[uuid.UUID]float64)
+	for _, list := range byActor {
+		for i := range list {
+			for j := range list {
+				if i == j {
+					continue
+				}
+				a, b := list[i].Target, list[j].Target
+				if dots[a] == nil {
+					dots[a] = make(map[uuid.UUID]float64)
+				}
+				dots[a][b] += list[i].Weight * list[j].Weight
+			}
+		}
+	}
+
+	var sims []models.UserSimilarity
+	now := time.Now()
+	for a, neighbors := range dots {
+		var list []models.UserSimilarity
+		for b, dot := range neighbors {
+			score := dot / (math.Sqrt(norms[a]) * math.Sqrt(norms[b]))
+			if score <= 0 {
+				continue
+			}
+			list = append(list, models.UserSimilarity{UserID: a, SimilarUserID: b, Score: score, UpdatedAt: now})
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Score > list[j].Score })
+		if len(list) > cs.Neighbors {
+			list = list[:cs.Neighbors]
+		}
+		sims = append(sims, list...)
+	}
+
+	err := cs.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.UserSimilarity{}).Error; err != nil {
+			return err
+		}
+		if len(sims) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(sims, 500).Error
+	})
+	if err != nil {
+		return err
+	}
+	logrus.Infof("CollaborativeService: %d similarities rebuilt from %d interactions in %s",
+		len(sims), len(rows), time.Since(started))
+	return nil
+}
+
+// Run rebuilds similarities immediately and then every interval. Blocks forever;
+// start it in a goroutine. A non-positive interval disables the job.
+func (cs *CollaborativeService) Run(interval time.Duration) {
+	if interval <= 0 {
+		logrus.Info("CollaborativeService: periodic rebuild disabled")
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := cs.Rebuild(); err != nil {
+			logrus.Errorf("CollaborativeService: rebuild failed: %v", err)
+		}
+		<-ticker.C
+	}
+}
+
+// collaborativeScores maps candidate IDs to collaborative scores.
+// It is nil when the user has no interactions yet (content-only ranking).
+type collaborativeScores map[uuid.UUID]float64
+
+// collaborativeScores computes the collaborative score of each candidate for userID.
+func (rs *RecommendationService) collaborativeScores(userID uuid.UUID, candidateIDs []uuid.UUID) (collaborativeScores, error) {
+	if cfBlendWeight <= 0 || len(candidateIDs) == 0 {
+		return nil, nil
+	}
+	var mine []interaction
+	if err := rs.DB.Raw(interactionsQuery+` WHERE actor = ? GROUP BY actor, target`, userID).
+		Scan(&mine).Error; err != nil {
+		return nil, err
+	}
+	if len(mine) == 0 {
+		return nil, nil
+	}
+
+	weights := make(map[uuid.UUID]float64, len(mine))
+	targets := make([]uuid.UUID, 0, len(mine))
+	var total float64
+	for _, m := range mine {
+		weights[m.Target] = m.Weight
+		targets = append(targets, m.Target)
+		total += math.Abs(m.Weight)
+	}
+	if total == 0 {
+		return nil, nil
+	}
+
+	var sims []models.UserSimilarity
+	if err := rs.DB.
+		Where("user_id IN ? AND similar_user_id IN ?", targets, candidateIDs).
+		Find(&sims).Error; err != nil {
+		return nil, err
+	}
+
+	scores := make(collaborativeScores, len(candidateIDs))
+	for _, s := range sims {
+		scores[s.SimilarUserID] += weights[s.UserID] * s.Score / total
+	}
+	for id, v := range scores {
+		scores[id] = math.Max(0, math.Min(1, v))
+	}
+	return scores, nil
+}
+
+// blendScore mixes the content score with the candidate's collaborative score and
+// records both parts in the explanation. Returns content unchanged without CF data.
+func blendScore(content float64, cf collaborativeScores, id uuid.UUID, expl *RecommendationExplanation) float64 {
+	if cf == nil || cfBlendWeight <= 0 {
+		return content
+	}
+	c := cf[id]
+	if expl != nil {
+		expl.ContentScore = content
+		expl.CollaborativeScore = &c
+		expl.BlendWeight = cfBlendWeight
+	}
+	return (1-cfBlendWeight)*content + cfBlendWeight*c
+}
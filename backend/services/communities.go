@@ -0,0 +1,619 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"m/backend/models"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Communities are groups built around one interest tag from Bio (jazz, hiking, ...):
+// the creator must have the tag in their Bio and becomes the owner. Anyone can join;
+// the owner appoints moderators, and both can remove members (moderators only plain
+// members). Members share a board of posts, pushed live as community_post WebSocket
+// events. Discovery suggests the communities matching the tags of the user's Bio, in
+// their city first, and recommendations add COMMUNITY_BOOST per shared community.
+// Membership counts and recent joins are aggregated per city from profiles.city, the
+// same data as GetCities.
+
+var (
+	ErrInvalidCommunity   = errors.New("invalid community")
+	ErrCommunityForbidden = errors.New("not allowed in this community")
+	ErrNotMember          = errors.New("not a member of the community")
+)
+
+const (
+	// Roles of models.CommunityMember.
+	CommunityOwner     = "owner"
+	CommunityModerator = "moderator"
+	CommunityMember    = "member"
+
+	// maxCommunityResults caps the communities returned per discovery request.
+	maxCommunityResults = 100
+	// maxBoostedCommunities caps how many shared communities raise a score.
+	maxBoostedCommunities = 3
+)
+
+var communityBoost float64
+
+// SetCommunityBoost sets the score added per shared community. 0 disables the boost.
+func SetCommunityBoost(b float64) {
+	if b < 0 {
+		b = 0
+	}
+	communityBoost = b
+}
+
+// CommunityInput is the editable part of a community. Nil fields are left unchanged on
+// update; the tag is required on create and cannot be changed. The name defaults to the tag.
+type CommunityInput struct {
+	Tag         *string `json:"tag"`
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	City        *string `json:"city"`
+}
+
+// CommunityView is a community with its member count and the current user's role
+// ("" when not a member).
+type CommunityView struct {
+	models.Community
+	Members int    `json:"members"`
+	MyRole  string `json:"myRole,omitempty"`
+}
+
+// CommunitySearch filters community discovery by tag and city (both optional).
+type CommunitySearch struct {
+	Tag   string
+	City  string
+	Limit int
+}
+
+// CommunityMemberView is a member with the public part of their profile.
+type CommunityMemberView struct {
+	UserID    uuid.UUID `json:"userId"`
+	Role      string    `json:"role"`
+	FirstName string    `json:"firstName"`
+	PhotoURL  string    `json:"photoUrl"`
+	JoinedAt  time.Time `json:"joinedAt"`
+}
+
+// CommunityCityStats are the memberships of the people living in a city: the
+// communities they are in, how many of them are members, and the joins since a date.
+type CommunityCityStats struct {
+	City        string `json:"city"`
+	Communities int    `json:"communities"`
+	Members     int    `json:"members"`
+	Joins       int    `json:"joins"`
+}
+
+// CommunityService manages communities, memberships and posts.
+type CommunityService struct {
+	DB *gorm.DB
+}
+
+// NewCommunityService creates a CommunityService.
+func NewCommunityService(db *gorm.DB) *CommunityService {
+	logrus.Info("CommunityService initialized")
+	return &CommunityService{DB: db}
+}
+
+// bioTokens returns the tokens of all Bio fields communities can be built around.
+func bioTokens(b models.Bio) []string {
+	var out []string
+	for _, f := range []string{b.Interests, b.Hobbies, b.Music, b.Food, b.Travel} {
+		out = append(out, splitTokens(f)...)
+	}
+	return out
+}
+
+// normalizeTag turns a tag into a single lowercase token.
+func normalizeTag(s string) (string, error) {
+	tokens := splitTokens(s)
+	if len(tokens) != 1 || len(tokens[0]) > 50 {
+		return "", fmt.Errorf("%w: the tag must be one word of at most 50 characters", ErrInvalidCommunity)
+	}
+	return tokens[0], nil
+}
+
+// apply validates the input and copies it to the community.
+func (in CommunityInput) apply(c *models.Community) error {
+	if in.Name != nil {
+		n := strings.TrimSpace(*in.Name)
+		if len(n) > 100 {
+			return fmt.Errorf("%w: name is limited to 100 characters", ErrInvalidCommunity)
+		}
+		c.Name = n
+	}
+	if c.Name == "" {
+		c.Name = c.Tag
+	}
+	if in.Description != nil {
+		if len(*in.Description) > 2000 {
+			return fmt.Errorf("%w: description is limited to 2000 characters", ErrInvalidCommunity)
+		}
+		c.Description = strings.TrimSpace(*in.Description)
+	}
+	if in.City != nil {
+		if len(*in.City) > 100 {
+			return fmt.Errorf("%w: city is limited to 100 characters", ErrInvalidCommunity)
+		}
+		c.City = strings.TrimSpace(*in.City)
+	}
+	return nil
+}
+
+// Create stores a new community around a tag of the owner's Bio; the owner is its first member.
+func (cs *CommunityService) Create(ownerID uuid.UUID, in CommunityInput) (*CommunityView, error) {
+	if in.Tag == nil {
+		return nil, fmt.Errorf("%w: tag is required", ErrInvalidCommunity)
+	}
+	tag, err := normalizeTag(*in.Tag)
+	if err != nil {
+		return nil, err
+	}
+	var bio models.Bio
+	if err := cs.DB.Where("user_id = ?", ownerID).First(&bio).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if !models.StringList(bioTokens(bio)).Contains(tag) {
+		return nil, fmt.Errorf("%w: %q is not in your bio", ErrInvalidCommunity, tag)
+	}
+	c := models.Community{OwnerID: ownerID, Tag: tag}
+	if err := in.apply(&c); err != nil {
+		return nil, err
+	}
+	err = cs.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&c).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.CommunityMember{CommunityID: c.ID, UserID: ownerID, Role: CommunityOwner}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("CommunityService: community %d (%s) created by %s", c.ID, c.Tag, ownerID)
+	return cs.Get(c.ID, ownerID)
+}
+
+// Update changes the name, description or city of a community of the owner.
+func (cs *CommunityService) Update(communityID uint, userID uuid.UUID, in CommunityInput) (*CommunityView, error) {
+	var c models.Community
+	if err := cs.DB.First(&c, communityID).Error; err != nil {
+		return nil, err
+	}
+	if c.OwnerID != userID {
+		return nil, ErrCommunityForbidden
+	}
+	if in.Tag != nil {
+		if tag, err := normalizeTag(*in.Tag); err != nil || tag != c.Tag {
+			return nil, fmt.Errorf("%w: the tag cannot be changed", ErrInvalidCommunity)
+		}
+	}
+	if err := in.apply(&c); err != nil {
+		return nil, err
+	}
+	if err := cs.DB.Save(&c).Error; err != nil {
+		return nil, err
+	}
+	return cs.Get(communityID, userID)
+}
+
+// Delete removes a community of the owner with its members and posts.
+func (cs *CommunityService) Delete(communityID uint, userID uuid.UUID) error {
+	var c models.Community
+	if err := cs.DB.First(&c, communityID).Error; err != nil {
+		return err
+	}
+	if c.OwnerID != userID {
+		return ErrCommunityForbidden
+	}
+	return cs.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("community_id = ?", communityID).Delete(&models.CommunityPost{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("community_id = ?", communityID).Delete(&models.CommunityMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&c).Error
+	})
+}
+
+// views adds member counts and the user's role to communities.
+func (cs *CommunityService) views(list []models.Community, userID uuid.UUID) ([]CommunityView, error) {
+	out := make([]CommunityView, len(list))
+	if len(list) == 0 {
+		return out, nil
+	}
+	ids := make([]uint, len(list))
+	index := make(map[uint]int, len(list))
+	for i, c := range list {
+		ids[i] = c.ID
+		index[c.ID] = i
+		out[i] = CommunityView{Community: c}
+	}
+	var counts []struct {
+		CommunityID uint
+		N           int
+	}
+	if err := cs.DB.Model(&models.CommunityMember{}).
+		Select("community_id, COUNT(*) AS n").
+		Where("community_id IN ?", ids).
+		Group("community_id").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	var mine []models.CommunityMember
+	if err := cs.DB.Where("community_id IN ? AND user_id = ?", ids, userID).Find(&mine).Error; err != nil {
+		return nil, err
+	}
+	for _, c := range counts {
+		out[index[c.CommunityID]].Members = c.N
+	}
+	for _, m := range mine {
+		out[index[m.CommunityID]].MyRole = m.Role
+	}
+	return out, nil
+}
+
+// Get returns a community as seen by the user.
+func (cs *CommunityService) Get(communityID uint, userID uuid.UUID) (*CommunityView, error) {
+	var c models.Community
+	if err := cs.DB.First(&c, communityID).Error; err != nil {
+		return nil, err
+	}
+	list, err := cs.views([]models.Community{c}, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// byMembers orders communities by member count, largest first.
+const byMembers = "(SELECT COUNT(*) FROM community_members m WHERE m.community_id = communities.id) DESC, id"
+
+// Search returns the communities with the tag and in the city, largest first.
+func (cs *CommunityService) Search(userID uuid.UUID, s CommunitySearch) ([]CommunityView, error) {
+	query := cs.DB.Model(&models.Community{})
+	if strings.TrimSpace(s.Tag) != "" {
+		tag, err := normalizeTag(s.Tag)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("tag = ?", tag)
+	}
+	if city := strings.TrimSpace(s.City); city != "" {
+		query = query.Where("LOWER(city) = LOWER(?)", city)
+	}
+	if s.Limit <= 0 || s.Limit > maxCommunityResults {
+		s.Limit = maxCommunityResults
+	}
+	var list []models.Community
+	if err := query.Order(byMembers).Limit(s.Limit).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return cs.views(list, userID)
+}
+
+// Suggested returns the communities built around tags of the user's Bio that they have
+// not joined: the ones in their city (or without a city) first, then the largest.
+func (cs *CommunityService) Suggested(userID uuid.UUID, limit int) ([]CommunityView, error) {
+	var me models.User
+	if err := cs.DB.Preload("Profile").Preload("Bio").First(&me, "id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	tokens := bioTokens(me.Bio)
+	if len(tokens) == 0 {
+		return []CommunityView{}, nil
+	}
+	if limit <= 0 || limit > maxCommunityResults {
+		limit = maxCommunityResults
+	}
+	var list []models.Community
+	if err := cs.DB.Raw(`
+		SELECT * FROM communities
+		WHERE tag IN ? AND id NOT IN (SELECT community_id FROM community_members WHERE user_id = ?)
+		ORDER BY (city = '' OR LOWER(city) = LOWER(?)) DESC, `+byMembers+`
+		LIMIT ?
+	`, tokens, userID, me.Profile.City, limit).Scan(&list).Error; err != nil {
+		return nil, err
+	}
+	return cs.views(list, userID)
+}
+
+// Mine returns the communities the user is a member of, by name.
+func (cs *CommunityService) Mine(userID uuid.UUID) ([]CommunityView, error) {
+	var list []models.Community
+	if err := cs.DB.
+		Where("id IN (?)", cs.DB.Model(&models.CommunityMember{}).Select("community_id").Where("user_id = ?", userID)).
+		Order("name, id").
+		Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return cs.views(list, userID)
+}
+
+// Join makes the user a member. Joining again returns the existing membership.
+func (cs *CommunityService) Join(communityID uint, userID uuid.UUID) (*models.CommunityMember, error) {
+	if err := cs.DB.First(&models.Community{}, communityID).Error; err != nil {
+		return nil, err
+	}
+	m := models.CommunityMember{CommunityID: communityID, UserID: userID, Role: CommunityMember}
+	err := cs.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&m).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := cs.DB.Where("community_id = ? AND user_id = ?", communityID, userID).First(&m).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Leave ends the user's membership. The owner cannot leave, they delete the community instead.
+func (cs *CommunityService) Leave(communityID uint, userID uuid.UUID) error {
+	role, err := cs.role(communityID, userID)
+	if err != nil {
+		return err
+	}
+	switch role {
+	case "":
+		return ErrNotMember
+	case CommunityOwner:
+		return fmt.Errorf("%w: the owner cannot leave, delete the community instead", ErrInvalidCommunity)
+	}
+	return cs.DB.Where("community_id = ? AND user_id = ?", communityID, userID).Delete(&models.CommunityMember{}).Error
+}
+
+// role returns the user's role in the community, "" when not a member.
+// Returns gorm.ErrRecordNotFound when the community does not exist.
+func (cs *CommunityService) role(communityID uint, userID uuid.UUID) (string, error) {
+	if err := cs.DB.First(&models.Community{}, communityID).Error; err != nil {
+		return "", err
+	}
+	var m models.CommunityMember
+	err := cs.DB.Where("community_id = ? AND user_id = ?", communityID, userID).First(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	return m.Role, err
+}
+
+// Members lists the owner, the moderators and then the members in join order. Only
+// members see the list.
+func (cs *CommunityService) Members(communityID uint, userID uuid.UUID) ([]CommunityMemberView, error) {
+	role, err := cs.role(communityID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if role == "" {
+		return nil, ErrNotMember
+	}
+	var list []CommunityMemberView
+	err = cs.DB.Raw(`
+		SELECT m.user_id, m.role, p.first_name, p.photo_url, m.created_at AS joined_at
+		FROM community_members m
+		LEFT JOIN profiles p ON p.user_id = m.user_id
+		WHERE m.community_id = ?
+		ORDER BY CASE m.role WHEN 'owner' THEN 0 WHEN 'moderator' THEN 1 ELSE 2 END, m.created_at, m.id
+	`, communityID).Scan(&list).Error
+	return list, err
+}
+
+// SetRole makes a member a moderator or a plain member again. Only the owner can.
+func (cs *CommunityService) SetRole(communityID uint, actorID, memberID uuid.UUID, role string) error {
+	if role != CommunityModerator && role != CommunityMember {
+		return fmt.Errorf("%w: role must be %s or %s", ErrInvalidCommunity, CommunityModerator, CommunityMember)
+	}
+	actorRole, err := cs.role(communityID, actorID)
+	if err != nil {
+		return err
+	}
+	if actorRole != CommunityOwner {
+		return ErrCommunityForbidden
+	}
+	if memberID == actorID {
+		return fmt.Errorf("%w: the owner's role cannot be changed", ErrInvalidCommunity)
+	}
+	res := cs.DB.Model(&models.CommunityMember{}).
+		Where("community_id = ? AND user_id = ?", communityID, memberID).
+		Update("role", role)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotMember
+	}
+	return nil
+}
+
+// RemoveMember removes someone from the community. The owner can remove anyone else,
+// moderators only plain members.
+func (cs *CommunityService) RemoveMember(communityID uint, actorID, memberID uuid.UUID) error {
+	actorRole, err := cs.role(communityID, actorID)
+	if err != nil {
+		return err
+	}
+	memberRole, err := cs.role(communityID, memberID)
+	if err != nil {
+		return err
+	}
+	if memberRole == "" {
+		return ErrNotMember
+	}
+	allowed := (actorRole == CommunityOwner && memberRole != CommunityOwner) ||
+		(actorRole == CommunityModerator && memberRole == CommunityMember)
+	if !allowed {
+		return ErrCommunityForbidden
+	}
+	return cs.DB.Where("community_id = ? AND user_id = ?", communityID, memberID).Delete(&models.CommunityMember{}).Error
+}
+
+// Post adds a post to the community board and pushes it to the other members.
+// Only members can post.
+func (cs *CommunityService) Post(communityID uint, userID uuid.UUID, content string) (*models.CommunityPost, error) {
+	content = strings.TrimSpace(content)
+	if content == "" || len(content) > 2000 {
+		return nil, fmt.Errorf("%w: post must be 1-2000 characters", ErrInvalidCommunity)
+	}
+	role, err := cs.role(communityID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if role == "" {
+		return nil, ErrNotMember
+	}
+	post := models.CommunityPost{CommunityID: communityID, AuthorID: userID, Content: content}
+	if err := cs.DB.Create(&post).Error; err != nil {
+		return nil, err
+	}
+
+	var author models.Profile
+	if err := cs.DB.Select("first_name", "last_name").Where("user_id = ?", userID).First(&author).Error; err != nil {
+		logrus.Warnf("CommunityService: profile of %s not found: %v", userID, err)
+	}
+	var members []uuid.UUID
+	if err := cs.DB.Model(&models.CommunityMember{}).
+		Where("community_id = ? AND user_id <> ?", communityID, userID).
+		Pluck("user_id", &members).Error; err != nil {
+		logrus.Errorf("CommunityService: loading members of community %d failed: %v", communityID, err)
+	}
+	// Posts are only pushed live; offline members read them on the board
+	if notifier != nil {
+		payload := map[string]interface{}{
+			"type":         "community_post",
+			"community_id": communityID,
+			"id":           post.ID,
+			"author_id":    userID.String(),
+			"author_name":  strings.TrimSpace(author.FirstName + " " + author.LastName),
+			"content":      post.Content,
+			"timestamp":    post.CreatedAt.UnixNano() / int64(time.Millisecond),
+		}
+		for _, id := range members {
+			notifier(id, payload)
+		}
+	}
+	return &post, nil
+}
+
+// Posts returns a page of the community board, newest first. Only members can read it.
+func (cs *CommunityService) Posts(communityID uint, userID uuid.UUID, page, limit int) ([]models.CommunityPost, int64, error) {
+	role, err := cs.role(communityID, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if role == "" {
+		return nil, 0, ErrNotMember
+	}
+	var total int64
+	if err := cs.DB.Model(&models.CommunityPost{}).Where("community_id = ?", communityID).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var list []models.CommunityPost
+	err = cs.DB.Where("community_id = ?", communityID).
+		Order("created_at desc, id desc").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&list).Error
+	return list, total, err
+}
+
+// DeletePost removes a post. Authors remove their own posts, the owner and moderators any.
+func (cs *CommunityService) DeletePost(communityID, postID uint, userID uuid.UUID) error {
+	var post models.CommunityPost
+	if err := cs.DB.Where("id = ? AND community_id = ?", postID, communityID).First(&post).Error; err != nil {
+		return err
+	}
+	if post.AuthorID != userID {
+		role, err := cs.role(communityID, userID)
+		if err != nil {
+			return err
+		}
+		if role != CommunityOwner && role != CommunityModerator {
+			return ErrCommunityForbidden
+		}
+	}
+	return cs.DB.Delete(&post).Error
+}
+
+// CityStats aggregates memberships by the members' profile city, most members first.
+// Joins counts the memberships created since the given time. A communityID other than
+// 0 restricts the stats to that community, a city to that city.
+func (cs *CommunityService) CityStats(communityID uint, city string, since time.Time) ([]CommunityCityStats, error) {
+	query := `
+		SELECT p.city,
+		       COUNT(DISTINCT m.community_id) AS communities,
+		       COUNT(DISTINCT m.user_id) AS members,
+		       COUNT(*) FILTER (WHERE m.created_at >= ?) AS joins
+		FROM community_members m
+		JOIN profiles p ON p.user_id = m.user_id
+		WHERE p.city <> ''`
+	args := []interface{}{since}
+	if communityID != 0 {
+		query += ` AND m.community_id = ?`
+		args = append(args, communityID)
+	}
+	if city = strings.TrimSpace(city); city != "" {
+		query += ` AND LOWER(p.city) = LOWER(?)`
+		args = append(args, city)
+	}
+	var out []CommunityCityStats
+	if err := cs.DB.Raw(query+` GROUP BY p.city ORDER BY members DESC, p.city`, args...).Scan(&out).Error; err != nil {
+		return nil, err
+	}
+	if out == nil {
+		out = []CommunityCityStats{}
+	}
+	return out, nil
+}
+
+// sharedCommunities maps candidate IDs to the number of communities they share with the
+// current user, nil when the boost is off.
+func (rs *RecommendationService) sharedCommunities(userID uuid.UUID, candidateIDs []uuid.UUID) (map[uuid.UUID]int, error) {
+	if communityBoost <= 0 || len(candidateIDs) == 0 {
+		return nil, nil
+	}
+	var rows []struct {
+		UserID uuid.UUID
+		N      int
+	}
+	if err := rs.DB.Raw(`
+		SELECT o.user_id, COUNT(*) AS n
+		FROM community_members me
+		JOIN community_members o ON o.community_id = me.community_id
+		WHERE me.user_id = ? AND o.user_id IN ?
+		GROUP BY o.user_id
+	`, userID, candidateIDs).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[uuid.UUID]int, len(rows))
+	for _, r := range rows {
+		out[r.UserID] = r.N
+	}
+	return out, nil
+}
+
+// boostCommunities adds communityBoost per shared community (at most
+// maxBoostedCommunities) to the score, capped at 1, and records it in the explanation.
+func boostCommunities(score float64, shared map[uuid.UUID]int, id uuid.UUID, expl *RecommendationExplanation) float64 {
+	n := shared[id]
+	if n == 0 || communityBoost <= 0 {
+		return score
+	}
+	if expl != nil {
+		expl.SharedCommunities = n
+	}
+	if n > maxBoostedCommunities {
+		n = maxBoostedCommunities
+	}
+	score += communityBoost * float64(n)
+	if score > 1 {
+		score = 1
+	}
+	return score
+}
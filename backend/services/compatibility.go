@@ -0,0 +1,165 @@
+package services
+
+import (
+	"math"
+
+	"m/backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Compatibility from the questionnaire (see questionnaire.go). On the active questions
+// both users answered, A's satisfaction with B is the importance-weighted share of
+// questions where B's own choice is one A accepts (accepting nothing means accepting
+// anything); a side that finds every common question irrelevant is fully satisfied.
+// Compatibility is the geometric mean of both satisfactions, so a match has to work
+// both ways. Pairs with fewer than MinCommonQuestions common answers have no score.
+// In recommendations, blendCompatibility mixes it into the score with the configurable
+// blend weight (off by default). Candidates without a score are blended with
+// neutralCompatibility, so scored and unscored candidates stay on one scale and
+// answering the questionnaire neither lifts nor sinks anyone by itself.
+
+// MinCommonQuestions is the number of questions both users must answer to be scored.
+const MinCommonQuestions = 3
+
+// neutralCompatibility stands in for candidates without a compatibility score.
+const neutralCompatibility = 0.5
+
+// compatBlendWeight is the share of compatibility in the final score (0 = off).
+var compatBlendWeight = 0.0
+
+// SetCompatibilityBlendWeight sets the questionnaire blend weight, clamped to [0, 1].
+func SetCompatibilityBlendWeight(w float64) {
+	compatBlendWeight = math.Max(0, math.Min(1, w))
+}
+
+// Compatibility is the questionnaire match of two users.
+type Compatibility struct {
+	Score           float64 `json:"score"`
+	Percent         int     `json:"percent"`
+	CommonQuestions int     `json:"commonQuestions"`
+}
+
+// compatibilityScores maps candidate IDs to their compatibility with the current user.
+// Candidates without enough common answers are missing.
+type compatibilityScores map[uuid.UUID]Compatibility
+
+// activeAnswers returns the answers of the users to active questions.
+func activeAnswers(db *gorm.DB, userIDs []uuid.UUID, questionIDs []uint) ([]models.QuestionAnswer, error) {
+	var list []models.QuestionAnswer
+	query := db.Joins("JOIN questions q ON q.id = question_answers.question_id AND q.active").
+		Where("question_answers.user_id IN ?", userIDs)
+	if questionIDs != nil {
+		query = query.Where("question_answers.question_id IN ?", questionIDs)
+	}
+	err := query.Find(&list).Error
+	return list, err
+}
+
+// satisfaction returns how well theirs satisfies mine over the given common questions.
+func satisfaction(mine, theirs map[uint]models.QuestionAnswer, common []uint) float64 {
+	var total, met float64
+	for _, q := range common {
+		w := importanceWeights[mine[q].Importance]
+		total += w
+		if len(mine[q].Acceptable) == 0 || mine[q].Acceptable.Contains(theirs[q].ChoiceID) {
+			met += w
+		}
+	}
+	if total == 0 {
+		return 1
+	}
+	return met / total
+}
+
+// compatibilityOf scores two answer sets, ok is false below MinCommonQuestions.
+func compatibilityOf(mine, theirs map[uint]models.QuestionAnswer) (Compatibility, bool) {
+	var common []uint
+	for q := range mine {
+		if _, ok := theirs[q]; ok {
+			common = append(common, q)
+		}
+	}
+	if len(common) < MinCommonQuestions {
+		return Compatibility{CommonQuestions: len(common)}, false
+	}
+	s := math.Sqrt(satisfaction(mine, theirs, common) * satisfaction(theirs, mine, common))
+	return Compatibility{Score: s, Percent: int(math.Round(s * 100)), CommonQuestions: len(common)}, true
+}
+
+// questionnaireCompatibility returns the compatibility of userID with each candidate
+// that has enough answers in common.
+func questionnaireCompatibility(db *gorm.DB, userID uuid.UUID, candidateIDs []uuid.UUID) (compatibilityScores, error) {
+	if len(candidateIDs) == 0 {
+		return nil, nil
+	}
+	mineList, err := activeAnswers(db, []uuid.UUID{userID}, nil)
+	if err != nil || len(mineList) < MinCommonQuestions {
+		return nil, err
+	}
+	mine := make(map[uint]models.QuestionAnswer, len(mineList))
+	questionIDs := make([]uint, len(mineList))
+	for i, a := range mineList {
+		mine[a.QuestionID] = a
+		questionIDs[i] = a.QuestionID
+	}
+	theirList, err := activeAnswers(db, candidateIDs, questionIDs)
+	if err != nil {
+		return nil, err
+	}
+	theirs := make(map[uuid.UUID]map[uint]models.QuestionAnswer)
+	for _, a := range theirList {
+		if theirs[a.UserID] == nil {
+			theirs[a.UserID] = make(map[uint]models.QuestionAnswer)
+		}
+		theirs[a.UserID][a.QuestionID] = a
+	}
+	out := make(compatibilityScores, len(theirs))
+	for id, answers := range theirs {
+		if c, ok := compatibilityOf(mine, answers); ok {
+			out[id] = c
+		}
+	}
+	return out, nil
+}
+
+// Compatibility returns the questionnaire match of two users, nil when they have fewer
+// than MinCommonQuestions answers in common.
+func (qs *QuestionnaireService) Compatibility(userID, otherID uuid.UUID) (*Compatibility, error) {
+	scores, err := questionnaireCompatibility(qs.DB, userID, []uuid.UUID{otherID})
+	if err != nil {
+		return nil, err
+	}
+	c, ok := scores[otherID]
+	if !ok {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+// compatibilityScores returns the compatibility of each candidate, nil when blending is off.
+func (rs *RecommendationService) compatibilityScores(userID uuid.UUID, candidateIDs []uuid.UUID) (compatibilityScores, error) {
+	if compatBlendWeight <= 0 {
+		return nil, nil
+	}
+	return questionnaireCompatibility(rs.DB, userID, candidateIDs)
+}
+
+// blendCompatibility mixes the score with the candidate's compatibility and records it
+// in the explanation. Candidates without compatibility get neutralCompatibility.
+func blendCompatibility(score float64, compat compatibilityScores, id uuid.UUID, expl *RecommendationExplanation) float64 {
+	if compatBlendWeight <= 0 {
+		return score
+	}
+	c, ok := compat[id]
+	if !ok {
+		return (1-compatBlendWeight)*score + compatBlendWeight*neutralCompatibility
+	}
+	if expl != nil {
+		s := c.Score
+		expl.Compatibility = &s
+		expl.CommonQuestions = c.CommonQuestions
+	}
+	return (1-compatBlendWeight)*score + compatBlendWeight*c.Score
+}
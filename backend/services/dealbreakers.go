@@ -0,0 +1,120 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"m/backend/models"
+)
+
+// Dealbreakers (Preference.Dealbreakers) are Bio tokens that rule a candidate out:
+// nobody whose Music contains "metal" for {"Music": ["metal"]}. They always apply to
+// the user's own results. In mutual mode (Preference.MutualMatching, or ?mutual= per
+// request) the candidate must accept the user as well: the user has to be within the
+// candidate's MaxRadius (0 means no limit), must not hit the candidate's dealbreakers
+// and must pass the candidate's age, gender and language filters (see demographics.go).
+// The checks run in SQL in GetNearbyUsers by joining the candidate's preferences and
+// bio, so excluded people never reach scoring.
+// Tokens are compared like scoring does: lowercase, split on commas and spaces.
+
+var ErrInvalidDealbreakers = errors.New("invalid dealbreakers")
+
+// MaxDealbreakersPerField is the number of tokens a user can rule out per field.
+const MaxDealbreakersPerField = 20
+
+// dealbreakerColumns maps the fields dealbreakers can name to bios columns.
+var dealbreakerColumns = map[string]string{
+	"Interests":  "interests",
+	"Hobbies":    "hobbies",
+	"Music":      "music",
+	"Food":       "food",
+	"Travel":     "travel",
+	"LookingFor": "looking_for",
+}
+
+// dealbreakerFields returns the field names in a stable order.
+func dealbreakerFields() []string {
+	fields := make([]string, 0, len(dealbreakerColumns))
+	for f := range dealbreakerColumns {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// NormalizeDealbreakers validates dealbreakers and returns them keyed by the canonical
+// field name (matching is case-insensitive) with lowercase, de-duplicated tokens.
+// Fields without tokens are dropped.
+func NormalizeDealbreakers(in map[string][]string) (models.Dealbreakers, error) {
+	canonical := make(map[string]string, len(dealbreakerColumns))
+	for f := range dealbreakerColumns {
+		canonical[strings.ToLower(f)] = f
+	}
+	out := models.Dealbreakers{}
+	for name, values := range in {
+		field, ok := canonical[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown field %q (expected one of %s)",
+				ErrInvalidDealbreakers, name, strings.Join(dealbreakerFields(), ", "))
+		}
+		seen := map[string]bool{}
+		var tokens []string
+		for _, v := range values {
+			for _, t := range splitTokens(v) {
+				if len(t) > 50 {
+					return nil, fmt.Errorf("%w: %s token %q is too long", ErrInvalidDealbreakers, field, t)
+				}
+				if !seen[t] {
+					seen[t] = true
+					tokens = append(tokens, t)
+				}
+			}
+		}
+		if len(tokens) > MaxDealbreakersPerField {
+			return nil, fmt.Errorf("%w: at most %d tokens per field", ErrInvalidDealbreakers, MaxDealbreakersPerField)
+		}
+		if len(tokens) > 0 {
+			out[field] = append(out[field], tokens...)
+		}
+	}
+	return out, nil
+}
+
+// dealbreakerHitSQL returns an SQL condition that is true when the bio aliased bioAlias
+// contains a dealbreaker of the preferences aliased prefAlias. Missing rows never hit.
+func dealbreakerHitSQL(prefAlias, bioAlias string) string {
+	parts := make([]string, 0, len(dealbreakerColumns))
+	for _, f := range dealbreakerFields() {
+		parts = append(parts, fmt.Sprintf(
+			`regexp_split_to_array(lower(COALESCE(%s.%s, '')), '[,[:space:]]+') && `+
+				`ARRAY(SELECT jsonb_array_elements_text(COALESCE(%s.dealbreakers -> '%s', '[]'::jsonb)))`,
+			bioAlias, dealbreakerColumns[f], prefAlias, f))
+	}
+	return "COALESCE(" + strings.Join(parts, " OR ") + ", false)"
+}
+
+// WithMutual returns a copy of the service with mutual matching turned on or off for
+// this request instead of the user's preference.
+func (rs *RecommendationService) WithMutual(on bool) *RecommendationService {
+	c := *rs
+	c.Mutual = &on
+	return &c
+}
+
+// Conditions of GetNearbyUsers: p is the candidate profile, cb/cp the candidate's bio and
+// preferences, mf/mb/mp the requesting user's profile, bio and preferences.
+// nearbyMutualCondition takes the mutual override (NULL uses the preference) and the
+// search coordinates.
+var (
+	nearbyDealbreakerCondition = "NOT " + dealbreakerHitSQL("mp", "cb")
+	nearbyMutualCondition      = `(
+		NOT COALESCE(?::boolean, mp.mutual_matching, false)
+		OR (
+		  (COALESCE(cp.max_radius, 0) <= 0 OR earth_distance(p.earth_loc, ll_to_earth(?, ?)) <= cp.max_radius * 1000.0)
+		  AND NOT ` + dealbreakerHitSQL("cp", "mb") + `
+		  AND ` + demographicMatchSQL("cp", "mf") + `
+		)
+	)`
+)
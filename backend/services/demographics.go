@@ -0,0 +1,132 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"m/backend/models"
+)
+
+// Demographics (Profile.Birthdate, Gender, Languages, Pronouns) are optional, but the
+// age range, genders and languages of Preference are hard filters: GetNearbyUsers only
+// returns candidates inside them, and a candidate without a birthdate never passes an
+// age limit. In mutual mode the candidate's own filters must accept the user as well
+// (see dealbreakers.go). The birthdate is stored as a date and only served as an age.
+
+var ErrInvalidDemographics = errors.New("invalid demographics")
+
+const (
+	// MinUserAge and MaxUserAge bound birthdates and age ranges.
+	MinUserAge = 18
+	MaxUserAge = 120
+	// MaxLanguages is the number of languages a profile or preference can list.
+	MaxLanguages = 10
+	// BirthdateLayout is the format of birthdates in requests and responses.
+	BirthdateLayout = "2006-01-02"
+)
+
+// Genders are the accepted values of Profile.Gender and Preference.Genders.
+var Genders = []string{"woman", "man", "non-binary", "other"}
+
+// ParseBirthdate parses a birthdate in BirthdateLayout. An empty string clears it.
+func ParseBirthdate(s string, now time.Time) (*time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(BirthdateLayout, s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: birthdate must be YYYY-MM-DD", ErrInvalidDemographics)
+	}
+	if age := models.AgeAt(t, now); age < MinUserAge || age > MaxUserAge {
+		return nil, fmt.Errorf("%w: age must be between %d and %d", ErrInvalidDemographics, MinUserAge, MaxUserAge)
+	}
+	return &t, nil
+}
+
+// NormalizeGender lowercases a gender and checks it is one of Genders. Empty is allowed.
+func NormalizeGender(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" || models.StringList(Genders).Contains(s) {
+		return s, nil
+	}
+	return "", fmt.Errorf("%w: gender must be one of %s", ErrInvalidDemographics, strings.Join(Genders, ", "))
+}
+
+// NormalizeGenders normalizes a set of genders, dropping duplicates.
+func NormalizeGenders(in []string) (models.StringList, error) {
+	var out models.StringList
+	for _, g := range in {
+		g, err := NormalizeGender(g)
+		if err != nil {
+			return nil, err
+		}
+		if g != "" && !out.Contains(g) {
+			out = append(out, g)
+		}
+	}
+	return out, nil
+}
+
+// NormalizeLanguages lowercases language codes and drops duplicates. Codes must be
+// 2 or 3 letters (ISO 639-1/639-3).
+func NormalizeLanguages(in []string) (models.StringList, error) {
+	var out models.StringList
+	for _, l := range in {
+		l = strings.ToLower(strings.TrimSpace(l))
+		if l == "" {
+			continue
+		}
+		if len(l) < 2 || len(l) > 3 || strings.IndexFunc(l, func(r rune) bool { return r < 'a' || r > 'z' }) >= 0 {
+			return nil, fmt.Errorf("%w: %q is not a language code", ErrInvalidDemographics, l)
+		}
+		if !out.Contains(l) {
+			out = append(out, l)
+		}
+	}
+	if len(out) > MaxLanguages {
+		return nil, fmt.Errorf("%w: at most %d languages", ErrInvalidDemographics, MaxLanguages)
+	}
+	return out, nil
+}
+
+// ValidatePronouns trims pronouns and checks their length.
+func ValidatePronouns(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if len(s) > 30 {
+		return "", fmt.Errorf("%w: pronouns are limited to 30 characters", ErrInvalidDemographics)
+	}
+	return s, nil
+}
+
+// ValidateAgeRange checks a preference age range; 0 means no limit on that side.
+func ValidateAgeRange(minAge, maxAge int) error {
+	for _, a := range []int{minAge, maxAge} {
+		if a != 0 && (a < MinUserAge || a > MaxUserAge) {
+			return fmt.Errorf("%w: ages must be 0 or between %d and %d", ErrInvalidDemographics, MinUserAge, MaxUserAge)
+		}
+	}
+	if minAge > 0 && maxAge > 0 && minAge > maxAge {
+		return fmt.Errorf("%w: minAge must not exceed maxAge", ErrInvalidDemographics)
+	}
+	return nil
+}
+
+// demographicMatchSQL returns an SQL condition that is true when the profile aliased
+// profAlias passes the age range, genders and languages of the preferences aliased
+// prefAlias. A missing preferences row accepts everyone.
+func demographicMatchSQL(prefAlias, profAlias string) string {
+	return fmt.Sprintf(`(
+		(COALESCE(%[1]s.min_age, 0) <= 0 OR COALESCE(%[2]s.birthdate <= CURRENT_DATE - %[1]s.min_age * INTERVAL '1 year', false))
+		AND (COALESCE(%[1]s.max_age, 0) <= 0 OR COALESCE(%[2]s.birthdate > CURRENT_DATE - (%[1]s.max_age + 1) * INTERVAL '1 year', false))
+		AND (COALESCE(jsonb_array_length(%[1]s.genders), 0) = 0 OR COALESCE(%[1]s.genders @> jsonb_build_array(%[2]s.gender), false))
+		AND (COALESCE(jsonb_array_length(%[1]s.languages), 0) = 0
+		  OR COALESCE(jsonb_exists_any(%[2]s.languages, ARRAY(SELECT jsonb_array_elements_text(%[1]s.languages))), false))
+	)`, prefAlias, profAlias)
+}
+
+// nearbyDemographicCondition is the condition of GetNearbyUsers on the candidate
+// profile p for the requesting user's preferences mp.
+var nearbyDemographicCondition = demographicMatchSQL("mp", "p")
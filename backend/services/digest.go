@@ -0,0 +1,322 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"m/backend/models"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// The daily digest computes the top matches of every active user in the background,
+// once per calendar day. Matches are stored as pending Recommendation rows with ServedAt
+// set, sent as a recommendation_digest event (stored for offline users, see
+// notifications.go) and, when a mailer is configured, emailed.
+// Users active in the last ActiveDays count as active: they changed their account,
+// were served recommendations, sent a message or a connection request, or are online
+// or were active over WebSocket (Profile.LastActiveAt).
+// Users opt out with Preference.DigestOptOut (everything) or DigestEmailOptOut (email).
+// People sent in a digest are not repeated for digestRepeatDays.
+// Every user is tried once a day (models.DigestRun), also when nothing was sent.
+
+const (
+	// digestRepeatDays keeps the same people out of consecutive digests.
+	digestRepeatDays = 7
+	// digestCandidateFactor is how many more candidates are ranked than sent, so
+	// recently sent ones can be skipped.
+	digestCandidateFactor = 3
+)
+
+// DigestItem is one match in a digest.
+type DigestItem struct {
+	UserID    uuid.UUID `json:"id"`
+	FirstName string    `json:"firstName"`
+	PhotoURL  string    `json:"photoUrl"`
+	City      string    `json:"city"`
+	Distance  float64   `json:"distance"`
+	Score     float64   `json:"score"`
+}
+
+// DigestService builds and delivers daily digests.
+type DigestService struct {
+	DB            *gorm.DB
+	Recs          *RecommendationService
+	Notifications *NotificationService
+	Mailer        *Mailer
+	Size          int
+	ActiveDays    int
+}
+
+// NewDigestService creates a digest service sending up to size matches per user.
+// mailer may be nil to send no email.
+func NewDigestService(db *gorm.DB, recs *RecommendationService, mailer *Mailer, size, activeDays int) *DigestService {
+	logrus.Info("DigestService initialized")
+	return &DigestService{
+		DB:            db,
+		Recs:          recs,
+		Notifications: NewNotificationService(db),
+		Mailer:        mailer,
+		Size:          size,
+		ActiveDays:    activeDays,
+	}
+}
+
+// startOfDay returns midnight of t's day in t's location.
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// pendingUsers returns active users who have not opted out and have not been tried today.
+func (ds *DigestService) pendingUsers(now time.Time) ([]uuid.UUID, error) {
+	since := now.AddDate(0, 0, -ds.ActiveDays)
+	var ids []uuid.UUID
+	err := ds.DB.Raw(`
+		SELECT u.id
+		FROM users u
+		LEFT JOIN preferences pr ON pr.user_id = u.id
+		LEFT JOIN profiles p ON p.user_id = u.id
+		WHERE NOT COALESCE(pr.digest_opt_out, false)
+		  AND NOT EXISTS (
+		    SELECT 1 FROM digest_runs d WHERE d.user_id = u.id AND d.run_at >= ?
+		  )
+		  AND (
+		    u.updated_at > ?
+		    OR COALESCE(p.online, false)
+		    OR p.last_active_at > ?
+		    OR EXISTS (SELECT 1 FROM recommendation_impressions i WHERE i.user_id = u.id AND i.served_at > ?)
+		    OR EXISTS (SELECT 1 FROM messages m WHERE m.sender_id = u.id AND m.timestamp > ?)
+		    OR EXISTS (SELECT 1 FROM connections c WHERE c.user_id = u.id AND c.created_at > ?)
+		  )
+		ORDER BY u.id
+	`, startOfDay(now), since, since, since, since, since).Scan(&ids).Error
+	return ids, err
+}
+
+// BuildDigest ranks the user's top matches, skipping people sent in recent digests,
+// and stores them as served recommendations.
+func (ds *DigestService) BuildDigest(userID uuid.UUID, now time.Time) ([]DigestItem, error) {
+	recs, err := ds.Recs.GetRankedRecommendations(userID, "affinity", ds.Size*digestCandidateFactor)
+	if err != nil {
+		return nil, err
+	}
+
+	var recent []uuid.UUID
+	if err := ds.DB.Model(&models.Recommendation{}).
+		Where("user_id = ? AND served_at > ?", userID, now.AddDate(0, 0, -digestRepeatDays)).
+		Pluck("rec_user_id", &recent).Error; err != nil {
+		return nil, err
+	}
+	skip := make(map[uuid.UUID]bool, len(recent))
+	for _, id := range recent {
+		skip[id] = true
+	}
+	var picked []RecommendationWithDistance
+	for _, rec := range recs {
+		if !skip[rec.UserID] {
+			picked = append(picked, rec)
+		}
+		if len(picked) == ds.Size {
+			break
+		}
+	}
+	if len(picked) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uuid.UUID, len(picked))
+	for i, rec := range picked {
+		ids[i] = rec.UserID
+	}
+	var profiles []models.Profile
+	if err := ds.DB.Where("user_id IN ?", ids).Find(&profiles).Error; err != nil {
+		return nil, err
+	}
+	byUser := make(map[uuid.UUID]models.Profile, len(profiles))
+	for _, p := range profiles {
+		byUser[p.UserID] = p
+	}
+
+	items := make([]DigestItem, len(picked))
+	err = ds.DB.Transaction(func(tx *gorm.DB) error {
+		for i, rec := range picked {
+			p := byUser[rec.UserID]
+			items[i] = DigestItem{
+				UserID:    rec.UserID,
+				FirstName: p.FirstName,
+				PhotoURL:  p.PhotoURL,
+				City:      p.City,
+				Distance:  rec.Distance,
+				Score:     rec.Score,
+			}
+
+			// Ranked candidates are never liked or matched, but one whose decline expired
+			// comes back; sending it in a digest brings it back to pending
+			var row models.Recommendation
+			err := tx.Where("user_id = ? AND rec_user_id = ?", userID, rec.UserID).First(&row).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if row.Status == "declined" {
+				row.Status = "pending"
+				row.DeclinedAt = nil
+			}
+			row.UserID = userID
+			row.RecUserID = rec.UserID
+			row.Score = rec.Score
+			row.Distance = rec.Distance
+			row.ServedAt = &now
+			if err := tx.Save(&row).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// SendDigest builds and delivers the digest of one user. Returns the number of matches sent.
+func (ds *DigestService) SendDigest(userID uuid.UUID, now time.Time) (int, error) {
+	items, err := ds.BuildDigest(userID, now)
+	if err != nil || len(items) == 0 {
+		return 0, err
+	}
+	if err := ds.Notifications.Notify(userID, "recommendation_digest", map[string]interface{}{
+		"date":  now.Format("2006-01-02"),
+		"count": len(items),
+		"items": items,
+	}); err != nil {
+		logrus.Errorf("SendDigest: notifying %s failed: %v", userID, err)
+	}
+	if ds.Mailer != nil {
+		if err := ds.emailDigest(userID, items); err != nil {
+			logrus.Errorf("SendDigest: emailing %s failed: %v", userID, err)
+		}
+	}
+	return len(items), nil
+}
+
+// emailDigest emails the digest unless the user opted out of digest email.
+func (ds *DigestService) emailDigest(userID uuid.UUID, items []DigestItem) error {
+	var row struct {
+		Email             string
+		DigestEmailOptOut bool
+	}
+	if err := ds.DB.Raw(`
+		SELECT u.email, COALESCE(pr.digest_email_opt_out, false) AS digest_email_opt_out
+		FROM users u LEFT JOIN preferences pr ON pr.user_id = u.id
+		WHERE u.id = ?
+	`, userID).Scan(&row).Error; err != nil {
+		return err
+	}
+	if row.Email == "" || row.DigestEmailOptOut {
+		return nil
+	}
+	var body strings.Builder
+	body.WriteString("Your matches for today:\n\n")
+	for _, item := range items {
+		fmt.Fprintf(&body, "- %s, %s (%.1f km, %.0f%% match)\n", item.FirstName, item.City, item.Distance, item.Score*100)
+	}
+	body.WriteString("\nOpen the app to say hello. To stop these emails, turn off the digest email in your settings.\n")
+	return ds.Mailer.Send(row.Email, fmt.Sprintf("%d new matches for you", len(items)), body.String())
+}
+
+// markRun records that the user's digest ran at now.
+func (ds *DigestService) markRun(userID uuid.UUID, now time.Time) error {
+	return ds.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"run_at"}),
+	}).Create(&models.DigestRun{UserID: userID, RunAt: now}).Error
+}
+
+// RunAll sends today's digest to every active user who has not been tried yet today.
+// Failed users other than incomplete profiles are retried on the next run.
+func (ds *DigestService) RunAll() error {
+	now := time.Now()
+	users, err := ds.pendingUsers(now)
+	if err != nil {
+		return err
+	}
+	sent := 0
+	for _, id := range users {
+		n, err := ds.SendDigest(id, now)
+		var incomplete *ErrIncompleteProfile
+		if errors.As(err, &incomplete) {
+			// Incomplete profiles have no recommendations; that is not a failure of the job
+			logrus.Debugf("DigestService: no digest for %s: %v", id, err)
+		} else if err != nil {
+			logrus.Errorf("DigestService: digest for %s failed: %v", id, err)
+			continue
+		}
+		if n > 0 {
+			sent++
+		}
+		if err := ds.markRun(id, now); err != nil {
+			logrus.Errorf("DigestService: recording run for %s failed: %v", id, err)
+		}
+	}
+	logrus.Infof("DigestService: %d users checked, %d digests sent", len(users), sent)
+	return nil
+}
+
+// Run checks for due digests every interval. Blocks forever; start it in a goroutine.
+// A non-positive interval disables the job.
+func (ds *DigestService) Run(interval time.Duration) {
+	if interval <= 0 {
+		logrus.Info("DigestService: daily digest disabled")
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := ds.RunAll(); err != nil {
+			logrus.Errorf("DigestService: run failed: %v", err)
+		}
+		<-ticker.C
+	}
+}
+
+// LatestDigest returns the matches of the user's most recent digest, best first,
+// and when it was sent (nil if the user never had one).
+func (ds *DigestService) LatestDigest(userID uuid.UUID) ([]DigestItem, *time.Time, error) {
+	var rows []struct {
+		DigestItem
+		ServedAt time.Time
+	}
+	err := ds.DB.Raw(`
+		SELECT r.rec_user_id AS user_id, p.first_name, p.photo_url, p.city, r.distance, r.score, r.served_at
+		FROM recommendations r
+		LEFT JOIN profiles p ON p.user_id = r.rec_user_id
+		WHERE r.user_id = ?
+		  AND r.served_at = (SELECT MAX(served_at) FROM recommendations WHERE user_id = ?)
+		ORDER BY r.score DESC, r.distance
+	`, userID, userID).Scan(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return []DigestItem{}, nil, err
+	}
+	items := make([]DigestItem, len(rows))
+	for i, row := range rows {
+		items[i] = row.DigestItem
+	}
+	return items, &rows[0].ServedAt, nil
+}
+
+// ServedInDigest reports whether candidateID was sent to the user in a recent digest.
+// Used for access checks so digest recipients can open the profiles they were sent.
+func (ds *DigestService) ServedInDigest(userID, candidateID uuid.UUID) (bool, error) {
+	var count int64
+	err := ds.DB.Model(&models.Recommendation{}).
+		Where("user_id = ? AND rec_user_id = ? AND served_at > ?",
+			userID, candidateID, time.Now().AddDate(0, 0, -digestRepeatDays)).
+		Count(&count).Error
+	return count > 0, err
+}
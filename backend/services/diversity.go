@@ -0,0 +1,172 @@
+package services
+
+import (
+	"math"
+
+	"m/backend/models"
+)
+
+// Diversity re-ranking runs after scoring and sorting. Without it the top of a list is
+// often a run of near-identical profiles from the same area. Maximal marginal relevance
+// (MMR) refills the first diversityWindow positions greedily: each step takes the
+// candidate with the best
+//
+//	lambda*relevance - (1-lambda)*max similarity to the candidates already taken
+//
+// Relevance is the ranking strategy's own sort key (distance decay, score or blend),
+// normalized to [0, 1] within the window. Two candidates are similar when they share Bio
+// tokens (Jaccard over the scoring fields and LookingFor) and are in the same
+// distance band. A lambda of 1 keeps the original order; lower values favor variety.
+// The lambda is DIVERSITY_LAMBDA, overridable per request with WithDiversity.
+
+const (
+	// diversityWindow is how many top positions are re-ranked; the rest keep their order.
+	diversityWindow = 50
+	// diversityBandWeight is the share of the similarity that comes from the distance band.
+	diversityBandWeight = 0.3
+)
+
+var diversityLambda = 1.0
+
+// SetDiversityLambda sets the default MMR lambda, clamped to [0, 1].
+func SetDiversityLambda(lambda float64) {
+	diversityLambda = clampLambda(lambda)
+}
+
+func clampLambda(lambda float64) float64 {
+	if math.IsNaN(lambda) {
+		return 1
+	}
+	return math.Max(0, math.Min(1, lambda))
+}
+
+// WithDiversity returns a copy of the service that re-ranks with the given lambda.
+func (rs *RecommendationService) WithDiversity(lambda float64) *RecommendationService {
+	c := *rs
+	l := clampLambda(lambda)
+	c.Diversity = &l
+	return &c
+}
+
+// diversityLambdaFor returns the lambda of this service.
+func (rs *RecommendationService) diversityLambdaFor() float64 {
+	if rs.Diversity != nil {
+		return *rs.Diversity
+	}
+	return diversityLambda
+}
+
+// rankKey is the relevance of a candidate under a ranking strategy (higher is better).
+func rankKey(strategy string, km, score float64) float64 {
+	switch strategy {
+	case RankingScore:
+		return score
+	case RankingBlend:
+		return blendedRank(score, km)
+	}
+	return distanceDecay(km)
+}
+
+// diversityProfile is what the similarity between two candidates is computed from.
+type diversityProfile struct {
+	tokens map[string]struct{}
+	band   string
+}
+
+// jaccard returns |a∩b| / |a∪b|, 0 for two empty sets.
+func jaccard(a, b map[string]struct{}) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 0
+	}
+	common := 0
+	for t := range a {
+		if _, ok := b[t]; ok {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+// diversityProfileOf collects the Bio tokens of the scoring fields and LookingFor,
+// prefixed with the field name so "rock" in Music and in Hobbies differ.
+func (rs *RecommendationService) diversityProfileOf(b models.Bio, km float64) diversityProfile {
+	p := diversityProfile{tokens: make(map[string]struct{}), band: distanceBand(km)}
+	for _, fc := range rs.FieldConfigs {
+		for _, t := range splitTokens(fc.Extractor(b)) {
+			p.tokens[fc.Name+":"+t] = struct{}{}
+		}
+	}
+	for _, t := range splitTokens(b.LookingFor) {
+		p.tokens["LookingFor:"+t] = struct{}{}
+	}
+	return p
+}
+
+func (p diversityProfile) similarity(o diversityProfile) float64 {
+	sim := (1 - diversityBandWeight) * jaccard(p.tokens, o.tokens)
+	if p.band == o.band {
+		sim += diversityBandWeight
+	}
+	return sim
+}
+
+// diversify returns the new order (indices into the ranked list) of n ranked candidates.
+// candidate(i) returns the Bio, distance and score of the i-th candidate in the current order.
+func (rs *RecommendationService) diversify(
+	strategy string,
+	n int,
+	candidate func(i int) (models.Bio, float64, float64),
+) []int {
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	lambda := rs.diversityLambdaFor()
+	window := n
+	if window > diversityWindow {
+		window = diversityWindow
+	}
+	if lambda >= 1 || window < 3 {
+		return order
+	}
+
+	relevance := make([]float64, window)
+	maxRel := 0.0
+	profiles := make([]diversityProfile, window)
+	for i := 0; i < window; i++ {
+		bio, km, score := candidate(i)
+		relevance[i] = rankKey(strategy, km, score)
+		maxRel = math.Max(maxRel, relevance[i])
+		profiles[i] = rs.diversityProfileOf(bio, km)
+	}
+	if maxRel > 0 {
+		for i := range relevance {
+			relevance[i] /= maxRel
+		}
+	}
+
+	// maxSim[i] is the highest similarity of candidate i to anything selected so far
+	maxSim := make([]float64, window)
+	taken := make([]bool, window)
+	for pos := 0; pos < window; pos++ {
+		best, bestVal := -1, math.Inf(-1)
+		for i := 0; i < window; i++ {
+			if taken[i] {
+				continue
+			}
+			val := lambda*relevance[i] - (1-lambda)*maxSim[i]
+			// Ties keep the original order
+			if val > bestVal+1e-12 {
+				best, bestVal = i, val
+			}
+		}
+		taken[best] = true
+		order[pos] = best
+		for i := 0; i < window; i++ {
+			if !taken[i] {
+				maxSim[i] = math.Max(maxSim[i], profiles[best].similarity(profiles[i]))
+			}
+		}
+	}
+	return order
+}
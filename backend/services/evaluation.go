@@ -0,0 +1,290 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"m/backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Offline evaluation replays historical outcomes against the scoring code without a
+// running server: a snapshot holds users (location, bio, preferences) and what each user
+// did with other users (accepted or declined). For every user with at least one accepted
+// outcome the candidates within their radius are ranked exactly like GetRankedRecommendations
+// ranks them (scoreCandidate, zero scores dropped, the user's ranking strategy), and the top k
+// is compared with the outcomes. Collaborative blending and decline filtering are left out on purpose:
+// past declines are exactly what is being replayed.
+
+// ScoringModes lists the recommendation modes evaluated by default.
+var ScoringModes = []string{"affinity", "desire"}
+
+// EvalUser is a user in an evaluation snapshot.
+type EvalUser struct {
+	ID         uuid.UUID         `json:"id"`
+	Latitude   float64           `json:"latitude"`
+	Longitude  float64           `json:"longitude"`
+	Bio        models.Bio        `json:"bio"`
+	Preference models.Preference `json:"preference"`
+}
+
+// EvalOutcome is what UserID did with RecUserID: "accepted" (connected, liked or matched)
+// or "declined".
+type EvalOutcome struct {
+	UserID    uuid.UUID `json:"userId"`
+	RecUserID uuid.UUID `json:"recUserId"`
+	Outcome   string    `json:"outcome"`
+}
+
+// EvalSnapshot is the input of an offline evaluation. Seed is set for generated snapshots.
+type EvalSnapshot struct {
+	Source    string        `json:"source"`
+	Seed      *int64        `json:"seed,omitempty"`
+	CreatedAt time.Time     `json:"createdAt"`
+	Users     []EvalUser    `json:"users"`
+	Outcomes  []EvalOutcome `json:"outcomes"`
+}
+
+// EvalReport holds the metrics of one scoring mode at cut-off K, averaged over
+// evaluated users (users with at least one accepted outcome).
+// Coverage is the share of all users that appear in at least one top-K list;
+// AvgDistance is the mean distance (km) of recommended users; DeclineRate is
+// the share of top-K items the user had declined.
+type EvalReport struct {
+	Mode           string  `json:"mode"`
+	K              int     `json:"k"`
+	EvaluatedUsers int     `json:"evaluatedUsers"`
+	PrecisionAtK   float64 `json:"precisionAtK"`
+	RecallAtK      float64 `json:"recallAtK"`
+	NDCGAtK        float64 `json:"ndcgAtK"`
+	Coverage       float64 `json:"coverage"`
+	AvgDistance    float64 `json:"avgDistanceKm"`
+	DeclineRate    float64 `json:"declineRate"`
+}
+
+// ExportEvalSnapshot builds a snapshot from the database. Accepted connections count
+// as accepted for the requester; likes and matches as accepted and declines as declined
+// for the recommendation owner. Users without a complete profile are skipped.
+func ExportEvalSnapshot(db *gorm.DB) (*EvalSnapshot, error) {
+	var users []models.User
+	if err := db.Preload("Profile").Preload("Bio").Preload("Preference").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	snap := &EvalSnapshot{Source: "database", CreatedAt: time.Now()}
+	for _, u := range users {
+		if validateUserData(u) != nil {
+			continue
+		}
+		snap.Users = append(snap.Users, EvalUser{
+			ID:         u.ID,
+			Latitude:   u.Profile.Latitude,
+			Longitude:  u.Profile.Longitude,
+			Bio:        u.Bio,
+			Preference: u.Preference,
+		})
+	}
+
+	err := db.Raw(`
+		SELECT user_id, connection_id AS rec_user_id, 'accepted' AS outcome
+		FROM connections WHERE status = 'accepted'
+		UNION
+		SELECT user_id, rec_user_id, 'accepted' FROM recommendations WHERE status IN ('liked', 'matched')
+		UNION
+		SELECT user_id, rec_user_id, 'declined' FROM recommendations WHERE status = 'declined'
+	`).Scan(&snap.Outcomes).Error
+	if err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// GenerateEvalSnapshot builds a synthetic snapshot of n users from the fixture generator.
+// Each user reacts to a few random users within maxRadius; the chance of accepting grows
+// with shared bio tokens and shrinks with distance. Useful to smoke-test metrics and to
+// compare runs on identical data; real tuning decisions need an exported snapshot.
+func GenerateEvalSnapshot(n int, seed int64, maxRadius float64) *EvalSnapshot {
+	gen := NewFixtureGenerator(seed)
+	rng := gen.Rand()
+	snap := &EvalSnapshot{Source: "generated", Seed: &seed, CreatedAt: time.Now()}
+	for i := 0; i < n; i++ {
+		fp := gen.Profile()
+		id := uuid.NewSHA1(uuid.NameSpaceOID, []byte(fmt.Sprintf("receval-%d-%d", seed, i)))
+		snap.Users = append(snap.Users, EvalUser{
+			ID:        id,
+			Latitude:  fp.Latitude,
+			Longitude: fp.Longitude,
+			Bio: models.Bio{
+				UserID:     id,
+				Interests:  fp.Interests,
+				Hobbies:    fp.Hobbies,
+				Music:      fp.Music,
+				Food:       fp.Food,
+				Travel:     fp.Travel,
+				LookingFor: fp.LookingFor,
+			},
+			Preference: models.Preference{UserID: id, MaxRadius: maxRadius},
+		})
+	}
+
+	for _, u := range snap.Users {
+		for j := 0; j < 10 && len(snap.Users) > 1; j++ {
+			o := snap.Users[rng.Intn(len(snap.Users))]
+			if o.ID == u.ID {
+				continue
+			}
+			d := haversineKm(u.Latitude, u.Longitude, o.Latitude, o.Longitude)
+			if d > maxRadius {
+				continue
+			}
+			shared := 0
+			for _, pair := range [][2]string{
+				{u.Bio.Interests, o.Bio.Interests}, {u.Bio.Hobbies, o.Bio.Hobbies}, {u.Bio.Music, o.Bio.Music},
+				{u.Bio.Food, o.Bio.Food}, {u.Bio.Travel, o.Bio.Travel}, {u.Bio.LookingFor, o.Bio.LookingFor},
+			} {
+				shared += countCommon(splitTokens(pair[0]), splitTokens(pair[1]))
+			}
+			p := (0.1 + 0.15*float64(shared)) * math.Exp(-d/200)
+			outcome := "declined"
+			if rng.Float64() < p {
+				outcome = "accepted"
+			}
+			snap.Outcomes = append(snap.Outcomes, EvalOutcome{UserID: u.ID, RecUserID: o.ID, Outcome: outcome})
+		}
+	}
+	return snap
+}
+
+// haversineKm returns the great-circle distance between two points in km.
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371.0
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+// rankOffline ranks candidates for u the same way GetRankedRecommendations does,
+// using in-memory distances instead of the earthdistance query.
+func (rs *RecommendationService) rankOffline(mode string, u EvalUser, users []EvalUser) []RecommendationWithDistance {
+	var nearby []RecommendationWithDistance
+	for _, o := range users {
+		if o.ID == u.ID {
+			continue
+		}
+		d := haversineKm(u.Latitude, u.Longitude, o.Latitude, o.Longitude)
+		if d > u.Preference.MaxRadius {
+			continue
+		}
+		nearby = append(nearby, RecommendationWithDistance{UserID: o.ID, Distance: d, Score: 0})
+	}
+	// Like GetNearbyUsers: only the nearest CandidateLimit users are scored
+	sort.Slice(nearby, func(i, j int) bool { return nearby[i].Distance < nearby[j].Distance })
+	if rs.CandidateLimit > 0 && len(nearby) > rs.CandidateLimit {
+		nearby = nearby[:rs.CandidateLimit]
+	}
+
+	bios := make(map[uuid.UUID]models.Bio, len(users))
+	for _, o := range users {
+		bios[o.ID] = o.Bio
+	}
+	ranked := make([]RecommendationWithDistance, 0, len(nearby))
+	for _, c := range nearby {
+		score, _ := rs.scoreCandidate(mode, u.Bio, u.Preference, bios[c.UserID], c.Distance, 0.05)
+		if score > 0 {
+			c.Score = score
+			ranked = append(ranked, c)
+		}
+	}
+	ranking := rs.rankingFor(u.Preference)
+	sort.Slice(ranked, func(i, j int) bool {
+		return rankLess(ranking, ranked[i].Distance, ranked[i].Score, ranked[j].Distance, ranked[j].Score)
+	})
+	order := rs.diversify(ranking, len(ranked), func(i int) (models.Bio, float64, float64) {
+		return bios[ranked[i].UserID], ranked[i].Distance, ranked[i].Score
+	})
+	out := make([]RecommendationWithDistance, len(ranked))
+	for i, j := range order {
+		out[i] = ranked[j]
+	}
+	return out
+}
+
+// Evaluate ranks recommendations for every user in the snapshot and scores the top k
+// against the recorded outcomes. It does not touch the database.
+func (rs *RecommendationService) Evaluate(snap *EvalSnapshot, mode string, k int) EvalReport {
+	report := EvalReport{Mode: mode, K: k}
+	if k <= 0 || len(snap.Users) == 0 {
+		return report
+	}
+
+	accepted := make(map[uuid.UUID]map[uuid.UUID]bool)
+	declined := make(map[uuid.UUID]map[uuid.UUID]bool)
+	for _, o := range snap.Outcomes {
+		target := declined
+		if o.Outcome == "accepted" {
+			target = accepted
+		}
+		if target[o.UserID] == nil {
+			target[o.UserID] = make(map[uuid.UUID]bool)
+		}
+		target[o.UserID][o.RecUserID] = true
+	}
+
+	covered := make(map[uuid.UUID]struct{})
+	var sumP, sumR, sumNDCG, sumDist, sumDeclined float64
+	served := 0
+	for _, u := range snap.Users {
+		ranked := rs.rankOffline(mode, u, snap.Users)
+		if len(ranked) > k {
+			ranked = ranked[:k]
+		}
+		for _, rec := range ranked {
+			covered[rec.UserID] = struct{}{}
+			sumDist += rec.Distance
+			if declined[u.ID][rec.UserID] {
+				sumDeclined++
+			}
+		}
+		served += len(ranked)
+
+		rel := accepted[u.ID]
+		if len(rel) == 0 {
+			continue
+		}
+		report.EvaluatedUsers++
+		hits := 0
+		dcg := 0.0
+		for i, rec := range ranked {
+			if rel[rec.UserID] {
+				hits++
+				dcg += 1 / math.Log2(float64(i+2))
+			}
+		}
+		ideal := 0.0
+		for i := 0; i < len(rel) && i < k; i++ {
+			ideal += 1 / math.Log2(float64(i+2))
+		}
+		sumP += float64(hits) / float64(k)
+		sumR += float64(hits) / float64(len(rel))
+		sumNDCG += dcg / ideal
+	}
+
+	if report.EvaluatedUsers > 0 {
+		n := float64(report.EvaluatedUsers)
+		report.PrecisionAtK = sumP / n
+		report.RecallAtK = sumR / n
+		report.NDCGAtK = sumNDCG / n
+	}
+	if served > 0 {
+		report.AvgDistance = sumDist / float64(served)
+		report.DeclineRate = sumDeclined / float64(served)
+	}
+	report.Coverage = float64(len(covered)) / float64(len(snap.Users))
+	return report
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"sort"
+	"time"
+
+	"m/backend/models"
+
+	"github.com/google/uuid"
+)
+
+// Event-based recommendations: the people going to the same upcoming events as the user
+// who share interests with them. Candidates are scored like regular recommendations
+// (content score and questionnaire compatibility); only candidates with a positive
+// score are kept, and declined users are skipped. Explanations use the mode "event".
+
+// EventMode is the explanation mode of event-based recommendations.
+const EventMode = "event"
+
+// EventRecommendation is a recommended co-attendee with the shared events.
+type EventRecommendation struct {
+	RecommendationWithDistance
+	EventIDs []uint `json:"eventIds"`
+}
+
+// GetEventRecommendations returns up to limit co-attendees of the user's upcoming events
+// (only eventID when it is not 0), best match first. A limit <= 0 returns all of them.
+func (rs *RecommendationService) GetEventRecommendations(
+	currentUserID uuid.UUID, eventID uint, mode string, limit int,
+) ([]EventRecommendation, error) {
+	if mode != "desire" {
+		mode = "affinity"
+	}
+	query := `
+		SELECT o.user_id, o.event_id
+		FROM event_attendees me
+		JOIN event_attendees o ON o.event_id = me.event_id AND o.user_id <> me.user_id AND o.status = 'going'
+		JOIN events e ON e.id = me.event_id
+		WHERE me.user_id = ? AND me.status = 'going'
+		  AND e.canceled_at IS NULL
+		  AND COALESCE(e.ends_at, e.starts_at) >= ?`
+	args := []interface{}{currentUserID, time.Now()}
+	if eventID != 0 {
+		query += ` AND me.event_id = ?`
+		args = append(args, eventID)
+	}
+	var pairs []struct {
+		UserID  uuid.UUID
+		EventID uint
+	}
+	if err := rs.DB.Raw(query+` ORDER BY o.event_id`, args...).Scan(&pairs).Error; err != nil {
+		return nil, err
+	}
+	if len(pairs) == 0 {
+		return []EventRecommendation{}, nil
+	}
+	events := make(map[uuid.UUID][]uint)
+	var ids []uuid.UUID
+	for _, p := range pairs {
+		if events[p.UserID] == nil {
+			ids = append(ids, p.UserID)
+		}
+		events[p.UserID] = append(events[p.UserID], p.EventID)
+	}
+
+	var me models.User
+	if err := rs.DB.
+		Preload("Profile").Preload("Bio").Preload("Preference").
+		First(&me, "id = ?", currentUserID).Error; err != nil {
+		return nil, err
+	}
+	var users []models.User
+	if err := rs.DB.Preload("Bio").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	distances := make(map[uuid.UUID]float64, len(ids))
+	if me.Profile.Latitude != 0 || me.Profile.Longitude != 0 {
+		var nearby []Nearby
+		if err := rs.DB.Raw(`
+			SELECT user_id AS id, earth_distance(earth_loc, ll_to_earth(?, ?)) / 1000.0 AS distance
+			FROM profiles WHERE user_id IN ? AND earth_loc IS NOT NULL
+		`, me.Profile.Latitude, me.Profile.Longitude, ids).Scan(&nearby).Error; err != nil {
+			return nil, err
+		}
+		for _, n := range nearby {
+			distances[n.ID] = n.Distance
+		}
+	}
+	declined, err := rs.declinedSet(currentUserID)
+	if err != nil {
+		return nil, err
+	}
+	compat, err := rs.compatibilityScores(currentUserID, ids)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := rs.sharedCommunities(currentUserID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []EventRecommendation
+	for _, u := range users {
+		if _, ok := declined[u.ID]; ok {
+			continue
+		}
+		d := distances[u.ID]
+		score, expl := rs.scoreCandidate(mode, me.Bio, me.Preference, u.Bio, d, 0.05)
+		if score <= 0 {
+			continue
+		}
+		expl.Mode = EventMode
+		score = blendCompatibility(score, compat, u.ID, expl)
+		score = boostCommunities(score, shared, u.ID, expl)
+		out = append(out, EventRecommendation{
+			RecommendationWithDistance: RecommendationWithDistance{
+				UserID:      u.ID,
+				Distance:    d,
+				Score:       score,
+				Explanation: expl,
+			},
+			EventIDs: events[u.ID],
+		})
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].Distance < out[j].Distance
+	})
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	if out == nil {
+		out = []EventRecommendation{}
+	}
+	return out, nil
+}
@@ -0,0 +1,687 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"m/backend/models"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Events are meetups users create with a place, a time, a capacity and interest tags.
+// Nearby users discover upcoming events with the same earth_loc search used for people
+// (events have their own generated earth_loc column, see models.InitDB). An RSVP is
+// "going" while there is room and "waitlisted" otherwise; when someone going leaves or
+// the capacity grows, the waitlist moves up in RSVP order and promoted users get an
+// event_rsvp_promoted event. Everyone going (the creator always is) shares the event's
+// group chat, whose messages are pushed as event_message WebSocket events. Canceling an
+// event sends event_canceled to everyone who RSVPed.
+
+var (
+	ErrInvalidEvent   = errors.New("invalid event")
+	ErrEventForbidden = errors.New("only the creator can change the event")
+	ErrEventClosed    = errors.New("event is canceled or over")
+	ErrNotAttending   = errors.New("not going to the event")
+)
+
+const (
+	// RSVP statuses of models.EventAttendee.
+	EventGoing      = "going"
+	EventWaitlisted = "waitlisted"
+
+	// MaxEventTags is the number of interest tags an event can have.
+	MaxEventTags = 10
+	// MaxEventCapacity bounds Event.Capacity (0 = unlimited).
+	MaxEventCapacity = 10000
+	// DefaultEventRadiusKm is the discovery radius for users without a MaxRadius.
+	DefaultEventRadiusKm = 25.0
+	// maxEventResults caps the events returned per discovery request.
+	maxEventResults = 100
+)
+
+// EventInput is the editable part of an event. Nil fields are left unchanged on update;
+// title, coordinates and start time are required on create.
+type EventInput struct {
+	Title       *string    `json:"title"`
+	Description *string    `json:"description"`
+	City        *string    `json:"city"`
+	Address     *string    `json:"address"`
+	Latitude    *float64   `json:"latitude"`
+	Longitude   *float64   `json:"longitude"`
+	StartsAt    *time.Time `json:"startsAt"`
+	EndsAt      *time.Time `json:"endsAt"`
+	Capacity    *int       `json:"capacity"`
+	Tags        []string   `json:"tags"`
+}
+
+// EventView is an event with its RSVP counts, the current user's status ("" when not
+// RSVPed) and, in discovery results, the distance in km.
+type EventView struct {
+	models.Event
+	Going      int      `json:"going"`
+	Waitlisted int      `json:"waitlisted"`
+	MyStatus   string   `json:"myStatus,omitempty"`
+	Distance   *float64 `json:"distance,omitempty"`
+}
+
+// EventSearch filters event discovery. Without coordinates the user's profile location
+// is used; a zero radius uses the user's MaxRadius, or DefaultEventRadiusKm.
+type EventSearch struct {
+	Latitude  *float64
+	Longitude *float64
+	RadiusKm  float64
+	Tag       string
+	From      *time.Time
+	To        *time.Time
+	Limit     int
+}
+
+// EventAttendeeView is an attendee with the public part of their profile.
+type EventAttendeeView struct {
+	UserID    uuid.UUID `json:"userId"`
+	Status    string    `json:"status"`
+	FirstName string    `json:"firstName"`
+	PhotoURL  string    `json:"photoUrl"`
+	RSVPAt    time.Time `json:"rsvpAt"`
+}
+
+// EventService manages events, RSVPs and event chats.
+type EventService struct {
+	DB            *gorm.DB
+	Notifications *NotificationService
+}
+
+// NewEventService creates an EventService.
+func NewEventService(db *gorm.DB) *EventService {
+	logrus.Info("EventService initialized")
+	return &EventService{DB: db, Notifications: NewNotificationService(db)}
+}
+
+// apply validates the input and copies it to the event.
+func (in EventInput) apply(e *models.Event, creating bool, now time.Time) error {
+	if creating && (in.Title == nil || in.Latitude == nil || in.Longitude == nil || in.StartsAt == nil) {
+		return fmt.Errorf("%w: title, latitude, longitude and startsAt are required", ErrInvalidEvent)
+	}
+	if in.Title != nil {
+		t := strings.TrimSpace(*in.Title)
+		if t == "" || len(t) > 200 {
+			return fmt.Errorf("%w: title must be 1-200 characters", ErrInvalidEvent)
+		}
+		e.Title = t
+	}
+	if in.Description != nil {
+		if len(*in.Description) > 2000 {
+			return fmt.Errorf("%w: description is limited to 2000 characters", ErrInvalidEvent)
+		}
+		e.Description = strings.TrimSpace(*in.Description)
+	}
+	if in.City != nil {
+		if len(*in.City) > 100 {
+			return fmt.Errorf("%w: city is limited to 100 characters", ErrInvalidEvent)
+		}
+		e.City = strings.TrimSpace(*in.City)
+	}
+	if in.Address != nil {
+		if len(*in.Address) > 255 {
+			return fmt.Errorf("%w: address is limited to 255 characters", ErrInvalidEvent)
+		}
+		e.Address = strings.TrimSpace(*in.Address)
+	}
+	if in.Latitude != nil {
+		e.Latitude = *in.Latitude
+	}
+	if in.Longitude != nil {
+		e.Longitude = *in.Longitude
+	}
+	if e.Latitude < -90 || e.Latitude > 90 || e.Longitude < -180 || e.Longitude > 180 ||
+		(e.Latitude == 0 && e.Longitude == 0) {
+		return fmt.Errorf("%w: invalid coordinates", ErrInvalidEvent)
+	}
+	if in.StartsAt != nil {
+		if !in.StartsAt.After(now) {
+			return fmt.Errorf("%w: startsAt must be in the future", ErrInvalidEvent)
+		}
+		e.StartsAt = *in.StartsAt
+	}
+	if in.EndsAt != nil {
+		e.EndsAt = in.EndsAt
+	}
+	if e.EndsAt != nil && !e.EndsAt.After(e.StartsAt) {
+		return fmt.Errorf("%w: endsAt must be after startsAt", ErrInvalidEvent)
+	}
+	if in.Capacity != nil {
+		if *in.Capacity < 0 || *in.Capacity > MaxEventCapacity {
+			return fmt.Errorf("%w: capacity must be between 0 (unlimited) and %d", ErrInvalidEvent, MaxEventCapacity)
+		}
+		e.Capacity = *in.Capacity
+	}
+	if in.Tags != nil {
+		var tags models.StringList
+		for _, t := range in.Tags {
+			for _, tok := range splitTokens(t) {
+				if len(tok) > 50 {
+					return fmt.Errorf("%w: tag %q is too long", ErrInvalidEvent, tok)
+				}
+				if !tags.Contains(tok) {
+					tags = append(tags, tok)
+				}
+			}
+		}
+		if len(tags) > MaxEventTags {
+			return fmt.Errorf("%w: at most %d tags", ErrInvalidEvent, MaxEventTags)
+		}
+		e.Tags = tags
+	}
+	return nil
+}
+
+// eventOpen reports whether RSVPs are still accepted: not canceled and not over.
+func eventOpen(e models.Event, now time.Time) bool {
+	end := e.StartsAt
+	if e.EndsAt != nil {
+		end = *e.EndsAt
+	}
+	return e.CanceledAt == nil && end.After(now)
+}
+
+// Create stores a new event; the creator is going to it.
+func (es *EventService) Create(creatorID uuid.UUID, in EventInput) (*EventView, error) {
+	e := models.Event{CreatorID: creatorID}
+	if err := in.apply(&e, true, time.Now()); err != nil {
+		return nil, err
+	}
+	err := es.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&e).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.EventAttendee{EventID: e.ID, UserID: creatorID, Status: EventGoing}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("EventService: event %d created by %s", e.ID, creatorID)
+	return es.Get(e.ID, creatorID)
+}
+
+// Update changes an event of the creator. A larger capacity promotes waitlisted users.
+func (es *EventService) Update(eventID uint, userID uuid.UUID, in EventInput) (*EventView, error) {
+	var promoted []uuid.UUID
+	var e models.Event
+	err := es.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&e, eventID).Error; err != nil {
+			return err
+		}
+		if e.CreatorID != userID {
+			return ErrEventForbidden
+		}
+		now := time.Now()
+		if !eventOpen(e, now) {
+			return ErrEventClosed
+		}
+		// Past start times may stay as they are; only a new one must be in the future
+		if in.StartsAt != nil && in.StartsAt.Equal(e.StartsAt) {
+			in.StartsAt = nil
+		}
+		if err := in.apply(&e, false, now); err != nil {
+			return err
+		}
+		if err := tx.Save(&e).Error; err != nil {
+			return err
+		}
+		var err error
+		promoted, err = promoteWaitlist(tx, e)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	es.notifyPromoted(e, promoted)
+	return es.Get(eventID, userID)
+}
+
+// Cancel cancels an event of the creator and tells everyone who RSVPed.
+func (es *EventService) Cancel(eventID uint, userID uuid.UUID) error {
+	var e models.Event
+	if err := es.DB.First(&e, eventID).Error; err != nil {
+		return err
+	}
+	if e.CreatorID != userID {
+		return ErrEventForbidden
+	}
+	if e.CanceledAt != nil {
+		return nil
+	}
+	now := time.Now()
+	if err := es.DB.Model(&e).Update("canceled_at", now).Error; err != nil {
+		return err
+	}
+	var attendees []uuid.UUID
+	if err := es.DB.Model(&models.EventAttendee{}).
+		Where("event_id = ? AND user_id <> ?", eventID, userID).
+		Pluck("user_id", &attendees).Error; err != nil {
+		return err
+	}
+	for _, id := range attendees {
+		if err := es.Notifications.Notify(id, "event_canceled", map[string]interface{}{
+			"event_id":    e.ID,
+			"title":       e.Title,
+			"starts_at":   e.StartsAt.Unix(),
+			"canceled_at": now.Unix(),
+		}); err != nil {
+			logrus.Errorf("EventService: notifying %s about canceled event %d failed: %v", id, e.ID, err)
+		}
+	}
+	logrus.Infof("EventService: event %d canceled, %d attendees notified", e.ID, len(attendees))
+	return nil
+}
+
+// views adds RSVP counts and the user's status to events.
+func (es *EventService) views(events []models.Event, userID uuid.UUID) ([]EventView, error) {
+	out := make([]EventView, len(events))
+	if len(events) == 0 {
+		return out, nil
+	}
+	ids := make([]uint, len(events))
+	for i, e := range events {
+		ids[i] = e.ID
+		out[i] = EventView{Event: e}
+	}
+	var counts []struct {
+		EventID uint
+		Status  string
+		N       int
+	}
+	if err := es.DB.Model(&models.EventAttendee{}).
+		Select("event_id, status, COUNT(*) AS n").
+		Where("event_id IN ?", ids).
+		Group("event_id, status").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	var mine []models.EventAttendee
+	if err := es.DB.Where("event_id IN ? AND user_id = ?", ids, userID).Find(&mine).Error; err != nil {
+		return nil, err
+	}
+	index := make(map[uint]int, len(ids))
+	for i, id := range ids {
+		index[id] = i
+	}
+	for _, c := range counts {
+		v := &out[index[c.EventID]]
+		if c.Status == EventGoing {
+			v.Going = c.N
+		} else {
+			v.Waitlisted = c.N
+		}
+	}
+	for _, a := range mine {
+		out[index[a.EventID]].MyStatus = a.Status
+	}
+	return out, nil
+}
+
+// Get returns an event as seen by the user.
+func (es *EventService) Get(eventID uint, userID uuid.UUID) (*EventView, error) {
+	var e models.Event
+	if err := es.DB.First(&e, eventID).Error; err != nil {
+		return nil, err
+	}
+	list, err := es.views([]models.Event{e}, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// Nearby returns the upcoming, not canceled events around the user, soonest first.
+func (es *EventService) Nearby(userID uuid.UUID, s EventSearch) ([]EventView, error) {
+	lat, lon, radius := s.Latitude, s.Longitude, s.RadiusKm
+	if lat == nil || lon == nil || radius <= 0 {
+		var me models.User
+		if err := es.DB.Preload("Profile").Preload("Preference").First(&me, "id = ?", userID).Error; err != nil {
+			return nil, err
+		}
+		if lat == nil || lon == nil {
+			if me.Profile.Latitude == 0 && me.Profile.Longitude == 0 {
+				return nil, fmt.Errorf("%w: no location given and none in the profile", ErrInvalidEvent)
+			}
+			lat, lon = &me.Profile.Latitude, &me.Profile.Longitude
+		}
+		if radius <= 0 {
+			radius = me.Preference.MaxRadius
+		}
+		if radius <= 0 {
+			radius = DefaultEventRadiusKm
+		}
+	}
+	limit := s.Limit
+	if limit <= 0 || limit > maxEventResults {
+		limit = maxEventResults
+	}
+	now := time.Now()
+	from := now
+	if s.From != nil && s.From.After(now) {
+		from = *s.From
+	}
+
+	query := `
+		SELECT e.id, earth_distance(e.earth_loc, ll_to_earth(?, ?)) / 1000.0 AS distance
+		FROM events e
+		WHERE earth_box(ll_to_earth(?, ?), ?) @> e.earth_loc
+		  AND earth_distance(e.earth_loc, ll_to_earth(?, ?)) <= ?
+		  AND e.canceled_at IS NULL
+		  AND COALESCE(e.ends_at, e.starts_at) >= ?`
+	meters := radius * 1000.0
+	args := []interface{}{*lat, *lon, *lat, *lon, meters, *lat, *lon, meters, from}
+	if s.To != nil {
+		query += ` AND e.starts_at <= ?`
+		args = append(args, *s.To)
+	}
+	if tag := strings.ToLower(strings.TrimSpace(s.Tag)); tag != "" {
+		query += ` AND COALESCE(e.tags, '[]'::jsonb) @> jsonb_build_array(?::text)`
+		args = append(args, tag)
+	}
+	query += ` ORDER BY e.starts_at, distance LIMIT ?`
+	args = append(args, limit)
+
+	var found []struct {
+		ID       uint
+		Distance float64
+	}
+	if err := es.DB.Raw(query, args...).Scan(&found).Error; err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return []EventView{}, nil
+	}
+	ids := make([]uint, len(found))
+	for i, f := range found {
+		ids[i] = f.ID
+	}
+	var events []models.Event
+	if err := es.DB.Where("id IN ?", ids).Order("starts_at, id").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	list, err := es.views(events, userID)
+	if err != nil {
+		return nil, err
+	}
+	distances := make(map[uint]float64, len(found))
+	for _, f := range found {
+		distances[f.ID] = f.Distance
+	}
+	for i := range list {
+		d := distances[list[i].ID]
+		list[i].Distance = &d
+	}
+	return list, nil
+}
+
+// Mine returns the events the user created or RSVPed to that are not over, soonest first.
+func (es *EventService) Mine(userID uuid.UUID) ([]EventView, error) {
+	var events []models.Event
+	if err := es.DB.
+		Where("COALESCE(ends_at, starts_at) >= ?", time.Now()).
+		Where("creator_id = ? OR id IN (?)", userID,
+			es.DB.Model(&models.EventAttendee{}).Select("event_id").Where("user_id = ?", userID)).
+		Order("starts_at, id").
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return es.views(events, userID)
+}
+
+// promoteWaitlist moves waitlisted users to going while the event has room and returns
+// them. Must run in the transaction that locked the event.
+func promoteWaitlist(tx *gorm.DB, e models.Event) ([]uuid.UUID, error) {
+	var going int64
+	if err := tx.Model(&models.EventAttendee{}).
+		Where("event_id = ? AND status = ?", e.ID, EventGoing).
+		Count(&going).Error; err != nil {
+		return nil, err
+	}
+	query := tx.Where("event_id = ? AND status = ?", e.ID, EventWaitlisted).Order("created_at, id")
+	if e.Capacity > 0 {
+		free := e.Capacity - int(going)
+		if free <= 0 {
+			return nil, nil
+		}
+		query = query.Limit(free)
+	}
+	var next []models.EventAttendee
+	if err := query.Find(&next).Error; err != nil || len(next) == 0 {
+		return nil, err
+	}
+	ids := make([]uint, len(next))
+	users := make([]uuid.UUID, len(next))
+	for i, a := range next {
+		ids[i] = a.ID
+		users[i] = a.UserID
+	}
+	if err := tx.Model(&models.EventAttendee{}).Where("id IN ?", ids).Update("status", EventGoing).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// notifyPromoted tells users they moved from the waitlist to going.
+func (es *EventService) notifyPromoted(e models.Event, users []uuid.UUID) {
+	for _, id := range users {
+		if err := es.Notifications.Notify(id, "event_rsvp_promoted", map[string]interface{}{
+			"event_id":  e.ID,
+			"title":     e.Title,
+			"starts_at": e.StartsAt.Unix(),
+		}); err != nil {
+			logrus.Errorf("EventService: notifying %s about event %d failed: %v", id, e.ID, err)
+		}
+	}
+}
+
+// RSVP signs the user up for an open event: going while there is room, waitlisted
+// otherwise. RSVPing again returns the existing RSVP.
+func (es *EventService) RSVP(eventID uint, userID uuid.UUID) (*models.EventAttendee, error) {
+	var a models.EventAttendee
+	err := es.DB.Transaction(func(tx *gorm.DB) error {
+		var e models.Event
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&e, eventID).Error; err != nil {
+			return err
+		}
+		if !eventOpen(e, time.Now()) {
+			return ErrEventClosed
+		}
+		err := tx.Where("event_id = ? AND user_id = ?", eventID, userID).First(&a).Error
+		if err == nil {
+			return nil
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		var going int64
+		if err := tx.Model(&models.EventAttendee{}).
+			Where("event_id = ? AND status = ?", eventID, EventGoing).
+			Count(&going).Error; err != nil {
+			return err
+		}
+		a = models.EventAttendee{EventID: eventID, UserID: userID, Status: EventGoing}
+		if e.Capacity > 0 && int(going) >= e.Capacity {
+			a.Status = EventWaitlisted
+		}
+		return tx.Create(&a).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// Leave removes the user's RSVP; a freed spot goes to the waitlist. The creator cannot
+// leave their own event, they cancel it instead.
+func (es *EventService) Leave(eventID uint, userID uuid.UUID) error {
+	var promoted []uuid.UUID
+	var e models.Event
+	err := es.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&e, eventID).Error; err != nil {
+			return err
+		}
+		if e.CreatorID == userID {
+			return fmt.Errorf("%w: the creator cannot leave, cancel the event instead", ErrInvalidEvent)
+		}
+		res := tx.Where("event_id = ? AND user_id = ?", eventID, userID).Delete(&models.EventAttendee{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrNotAttending
+		}
+		if !eventOpen(e, time.Now()) {
+			return nil
+		}
+		var err error
+		promoted, err = promoteWaitlist(tx, e)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	es.notifyPromoted(e, promoted)
+	return nil
+}
+
+// attendeeStatus returns the user's RSVP status for the event, "" when there is none.
+func (es *EventService) attendeeStatus(eventID uint, userID uuid.UUID) (string, error) {
+	var a models.EventAttendee
+	err := es.DB.Where("event_id = ? AND user_id = ?", eventID, userID).First(&a).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	return a.Status, err
+}
+
+// Attendees lists the people going (then the waitlist in order) to an event. Only users
+// who RSVPed see the list; others get ErrNotAttending.
+func (es *EventService) Attendees(eventID uint, userID uuid.UUID) ([]EventAttendeeView, error) {
+	if err := es.DB.First(&models.Event{}, eventID).Error; err != nil {
+		return nil, err
+	}
+	status, err := es.attendeeStatus(eventID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if status == "" {
+		return nil, ErrNotAttending
+	}
+	var list []EventAttendeeView
+	err = es.DB.Raw(`
+		SELECT a.user_id, a.status, p.first_name, p.photo_url, a.created_at AS rsvp_at
+		FROM event_attendees a
+		LEFT JOIN profiles p ON p.user_id = a.user_id
+		WHERE a.event_id = ?
+		ORDER BY a.status = 'going' DESC, a.created_at, a.id
+	`, eventID).Scan(&list).Error
+	return list, err
+}
+
+// SharesEvent reports whether both users are going to the same upcoming event.
+func (es *EventService) SharesEvent(userID, otherID uuid.UUID) (bool, error) {
+	var n int64
+	err := es.DB.Raw(`
+		SELECT COUNT(*)
+		FROM event_attendees a
+		JOIN event_attendees b ON b.event_id = a.event_id AND b.user_id = ? AND b.status = 'going'
+		JOIN events e ON e.id = a.event_id
+		WHERE a.user_id = ? AND a.status = 'going'
+		  AND e.canceled_at IS NULL
+		  AND COALESCE(e.ends_at, e.starts_at) >= ?
+	`, otherID, userID, time.Now()).Scan(&n).Error
+	return n > 0, err
+}
+
+// PostMessage adds a message to the event chat and pushes it to the other people going.
+// Only people going can post, and not in canceled events.
+func (es *EventService) PostMessage(eventID uint, userID uuid.UUID, content string) (*models.EventMessage, error) {
+	content = strings.TrimSpace(content)
+	if content == "" || len(content) > 2000 {
+		return nil, fmt.Errorf("%w: message must be 1-2000 characters", ErrInvalidEvent)
+	}
+	var e models.Event
+	if err := es.DB.First(&e, eventID).Error; err != nil {
+		return nil, err
+	}
+	if e.CanceledAt != nil {
+		return nil, ErrEventClosed
+	}
+	status, err := es.attendeeStatus(eventID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if status != EventGoing {
+		return nil, ErrNotAttending
+	}
+	msg := models.EventMessage{EventID: eventID, SenderID: userID, Content: content}
+	if err := es.DB.Create(&msg).Error; err != nil {
+		return nil, err
+	}
+
+	var sender models.Profile
+	if err := es.DB.Select("first_name", "last_name").Where("user_id = ?", userID).First(&sender).Error; err != nil {
+		logrus.Warnf("EventService: profile of %s not found: %v", userID, err)
+	}
+	var members []uuid.UUID
+	if err := es.DB.Model(&models.EventAttendee{}).
+		Where("event_id = ? AND status = ? AND user_id <> ?", eventID, EventGoing, userID).
+		Pluck("user_id", &members).Error; err != nil {
+		logrus.Errorf("EventService: loading members of event %d failed: %v", eventID, err)
+	}
+	// Chat messages are only pushed live; offline members read them from the history
+	if notifier != nil {
+		payload := map[string]interface{}{
+			"type":        "event_message",
+			"event_id":    eventID,
+			"id":          msg.ID,
+			"sender_id":   userID.String(),
+			"sender_name": strings.TrimSpace(sender.FirstName + " " + sender.LastName),
+			"content":     msg.Content,
+			"timestamp":   msg.Timestamp.UnixNano() / int64(time.Millisecond),
+		}
+		for _, id := range members {
+			notifier(id, payload)
+		}
+	}
+	return &msg, nil
+}
+
+// Messages returns a page of the event chat in chronological order, with the total count.
+// Only people going can read it.
+func (es *EventService) Messages(eventID uint, userID uuid.UUID, page, limit int) ([]models.EventMessage, int64, error) {
+	if err := es.DB.First(&models.Event{}, eventID).Error; err != nil {
+		return nil, 0, err
+	}
+	status, err := es.attendeeStatus(eventID, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if status != EventGoing {
+		return nil, 0, ErrNotAttending
+	}
+	var total int64
+	if err := es.DB.Model(&models.EventMessage{}).Where("event_id = ?", eventID).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var list []models.EventMessage
+	if err := es.DB.Where("event_id = ?", eventID).
+		Order("timestamp desc, id desc").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&list).Error; err != nil {
+		return nil, 0, err
+	}
+	for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+		list[i], list[j] = list[j], list[i]
+	}
+	return list, total, nil
+}
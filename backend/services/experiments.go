@@ -0,0 +1,282 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+
+	"m/backend/models"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Experiments let scoring changes be tested safely. Each active experiment splits users
+// into variants by hashing experiment name + user ID, so a user always sees the same
+// variant. A variant overrides field weights and/or the sort order on a per-request copy
+// of RecommendationService (see WithVariant); the shared instance is never mutated.
+// Every served recommendation is stored as an impression with its variant, and
+// Metrics compares connection-request and acceptance rates per variant.
+
+var ErrInvalidExperiment = errors.New("invalid experiment")
+
+// VariantConfig is the scoring override of a variant.
+// FieldWeights replaces the weight of the named FieldConfigs; SortOrder is a ranking strategy.
+type VariantConfig struct {
+	FieldWeights map[string]float64 `json:"fieldWeights,omitempty"`
+	SortOrder    string             `json:"sortOrder,omitempty"`
+}
+
+// Assignment is the variant a user was bucketed into.
+type Assignment struct {
+	ExperimentID uint
+	Experiment   string
+	Variant      string
+	Config       VariantConfig
+}
+
+// VariantMetrics compares outcomes of a variant. Rates are 0 when there is no data.
+type VariantMetrics struct {
+	Variant        string  `json:"variant"`
+	Users          int     `json:"users"`
+	Impressions    int     `json:"impressions"`
+	Requests       int     `json:"connectionRequests"`
+	Accepted       int     `json:"accepted"`
+	RequestRate    float64 `json:"requestRate"`
+	AcceptanceRate float64 `json:"acceptanceRate"`
+}
+
+// WithVariant returns a copy of the service with the variant's overrides applied.
+func (rs *RecommendationService) WithVariant(cfg VariantConfig) *RecommendationService {
+	c := *rs
+	c.FieldConfigs = make([]FieldConfig, len(rs.FieldConfigs))
+	copy(c.FieldConfigs, rs.FieldConfigs)
+	for i, fc := range c.FieldConfigs {
+		if w, ok := cfg.FieldWeights[fc.Name]; ok {
+			c.FieldConfigs[i].Weight = w
+		}
+	}
+	if cfg.SortOrder != "" {
+		c.SortOrder = cfg.SortOrder
+	}
+	return &c
+}
+
+// FieldNames returns the names of the scoring fields, in scoring order.
+func (rs *RecommendationService) FieldNames() []string {
+	names := make([]string, len(rs.FieldConfigs))
+	for i, fc := range rs.FieldConfigs {
+		names[i] = fc.Name
+	}
+	return names
+}
+
+// ExperimentService manages experiments, user bucketing, impressions and metrics.
+type ExperimentService struct {
+	DB *gorm.DB
+}
+
+// NewExperimentService creates a service for recommendation experiments.
+func NewExperimentService(db *gorm.DB) *ExperimentService {
+	logrus.Info("ExperimentService initialized")
+	return &ExperimentService{DB: db}
+}
+
+// ValidateExperiment checks the name, variants, allocations and variant configs.
+// fieldNames are the scoring fields a variant may override.
+func ValidateExperiment(exp *models.Experiment, fieldNames []string) error {
+	if strings.TrimSpace(exp.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidExperiment)
+	}
+	if len(exp.Variants) < 2 {
+		return fmt.Errorf("%w: at least two variants are required", ErrInvalidExperiment)
+	}
+	known := make(map[string]bool, len(fieldNames))
+	for _, n := range fieldNames {
+		known[n] = true
+	}
+	seen := make(map[string]bool)
+	for _, v := range exp.Variants {
+		if strings.TrimSpace(v.Name) == "" || seen[v.Name] {
+			return fmt.Errorf("%w: variant names must be unique and non-empty", ErrInvalidExperiment)
+		}
+		seen[v.Name] = true
+		if v.Allocation <= 0 {
+			return fmt.Errorf("%w: variant %q must have a positive allocation", ErrInvalidExperiment, v.Name)
+		}
+		cfg, err := parseVariantConfig(v.Config)
+		if err != nil {
+			return fmt.Errorf("%w: variant %q: %v", ErrInvalidExperiment, v.Name, err)
+		}
+		for name, w := range cfg.FieldWeights {
+			if !known[name] {
+				return fmt.Errorf("%w: variant %q: unknown field %q", ErrInvalidExperiment, v.Name, name)
+			}
+			if w < 0 || w > 1 {
+				return fmt.Errorf("%w: variant %q: weight of %q must be between 0 and 1", ErrInvalidExperiment, v.Name, name)
+			}
+		}
+		if cfg.SortOrder != "" && !IsRankingStrategy(cfg.SortOrder) {
+			return fmt.Errorf("%w: variant %q: unknown sort order %q", ErrInvalidExperiment, v.Name, cfg.SortOrder)
+		}
+	}
+	return nil
+}
+
+func parseVariantConfig(raw string) (VariantConfig, error) {
+	var cfg VariantConfig
+	if strings.TrimSpace(raw) == "" {
+		return cfg, nil
+	}
+	err := json.Unmarshal([]byte(raw), &cfg)
+	return cfg, err
+}
+
+// bucket deterministically maps a user to one of the variants by allocation share.
+func bucket(experiment string, userID uuid.UUID, variants []models.ExperimentVariant) *models.ExperimentVariant {
+	total := 0
+	for _, v := range variants {
+		total += v.Allocation
+	}
+	if total <= 0 {
+		return nil
+	}
+	h := fnv.New32a()
+	h.Write([]byte(experiment + ":" + userID.String()))
+	point := int(h.Sum32() % uint32(total))
+	for i := range variants {
+		point -= variants[i].Allocation
+		if point < 0 {
+			return &variants[i]
+		}
+	}
+	return nil
+}
+
+// Assign returns the user's variant in the most recently created active experiment,
+// or nil if no experiment is running.
+func (es *ExperimentService) Assign(userID uuid.UUID) (*Assignment, error) {
+	var exp models.Experiment
+	err := es.DB.
+		Preload("Variants", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Where("active = ?", true).
+		Order("created_at desc").
+		First(&exp).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	v := bucket(exp.Name, userID, exp.Variants)
+	if v == nil {
+		return nil, nil
+	}
+	cfg, err := parseVariantConfig(v.Config)
+	if err != nil {
+		logrus.Warnf("Assign: bad config of variant %q in experiment %q: %v", v.Name, exp.Name, err)
+	}
+	return &Assignment{ExperimentID: exp.ID, Experiment: exp.Name, Variant: v.Name, Config: cfg}, nil
+}
+
+// RecordImpressions stores the served recommendations with their rank positions
+// (starting at offset) and the variant that produced them. a may be nil.
+func (es *ExperimentService) RecordImpressions(
+	userID uuid.UUID, a *Assignment, recs []RecommendationWithDistance, offset int,
+) error {
+	if len(recs) == 0 {
+		return nil
+	}
+	now := time.Now()
+	rows := make([]models.RecommendationImpression, len(recs))
+	for i, rec := range recs {
+		rows[i] = models.RecommendationImpression{
+			UserID:    userID,
+			RecUserID: rec.UserID,
+			Position:  offset + i,
+			Score:     rec.Score,
+			ServedAt:  now,
+		}
+		if a != nil {
+			id := a.ExperimentID
+			rows[i].ExperimentID = &id
+			rows[i].Variant = a.Variant
+		}
+	}
+	return es.DB.CreateInBatches(rows, 200).Error
+}
+
+// Metrics compares variants of an experiment: distinct (user, recommendation) pairs served,
+// connection requests sent to served users after they were first served, and how many of
+// those requests were accepted.
+func (es *ExperimentService) Metrics(experimentID uint) ([]VariantMetrics, error) {
+	var rows []VariantMetrics
+	err := es.DB.Raw(`
+		WITH served AS (
+		  SELECT variant, user_id, rec_user_id, MIN(served_at) AS first_served
+		  FROM recommendation_impressions
+		  WHERE experiment_id = ?
+		  GROUP BY variant, user_id, rec_user_id
+		)
+		SELECT
+		  s.variant,
+		  COUNT(DISTINCT s.user_id) AS users,
+		  COUNT(*) AS impressions,
+		  COUNT(c.id) AS requests,
+		  COUNT(c.id) FILTER (WHERE c.status = 'accepted') AS accepted
+		FROM served s
+		LEFT JOIN connections c
+		  ON c.user_id = s.user_id
+		  AND c.connection_id = s.rec_user_id
+		  AND c.created_at >= s.first_served
+		GROUP BY s.variant
+		ORDER BY s.variant
+	`, experimentID).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		if rows[i].Impressions > 0 {
+			rows[i].RequestRate = float64(rows[i].Requests) / float64(rows[i].Impressions)
+		}
+		if rows[i].Requests > 0 {
+			rows[i].AcceptanceRate = float64(rows[i].Accepted) / float64(rows[i].Requests)
+		}
+	}
+	return rows, nil
+}
+
+// CreateExperiment validates and stores an experiment with its variants.
+func (es *ExperimentService) CreateExperiment(exp *models.Experiment, fieldNames []string) error {
+	if err := ValidateExperiment(exp, fieldNames); err != nil {
+		return err
+	}
+	return es.DB.Create(exp).Error
+}
+
+// ListExperiments returns all experiments with their variants, newest first.
+func (es *ExperimentService) ListExperiments() ([]models.Experiment, error) {
+	var list []models.Experiment
+	err := es.DB.
+		Preload("Variants", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Order("created_at desc").
+		Find(&list).Error
+	return list, err
+}
+
+// SetActive starts or stops an experiment.
+// Returns gorm.ErrRecordNotFound if the experiment does not exist.
+func (es *ExperimentService) SetActive(id uint, active bool) (*models.Experiment, error) {
+	var exp models.Experiment
+	if err := es.DB.Preload("Variants").First(&exp, id).Error; err != nil {
+		return nil, err
+	}
+	if err := es.DB.Model(&exp).Update("active", active).Error; err != nil {
+		return nil, err
+	}
+	return &exp, nil
+}
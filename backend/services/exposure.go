@@ -0,0 +1,119 @@
+package services
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Exposure caps keep popular profiles from appearing in every list nearby. A candidate
+// is over its limits when, in the last exposureWindow, it was served (see
+// ExperimentService.RecordImpressions) to at least DailyImpressions other users, or when
+// it has at least PendingRequests unanswered incoming connection requests. Such
+// candidates are not removed, only moved below everyone within their limits, so they
+// still show up when there is nobody else. A cap of 0 disables that check.
+
+// ExposureCaps are the limits that demote a candidate.
+type ExposureCaps struct {
+	DailyImpressions int `json:"dailyImpressions"`
+	PendingRequests  int `json:"pendingRequests"`
+}
+
+// exposureWindow is the period impressions are counted over.
+const exposureWindow = 24 * time.Hour
+
+var exposureCaps ExposureCaps
+
+// SetExposureCaps sets the exposure limits. Negative values are treated as 0 (off).
+func SetExposureCaps(dailyImpressions, pendingRequests int) {
+	if dailyImpressions < 0 {
+		dailyImpressions = 0
+	}
+	if pendingRequests < 0 {
+		pendingRequests = 0
+	}
+	exposureCaps = ExposureCaps{DailyImpressions: dailyImpressions, PendingRequests: pendingRequests}
+}
+
+// GetExposureCaps returns the current exposure limits.
+func GetExposureCaps() ExposureCaps {
+	return exposureCaps
+}
+
+// overExposed returns the candidates that are over an exposure cap. Impressions served to
+// viewerID do not count, so paging through a list does not demote its own items.
+func (rs *RecommendationService) overExposed(viewerID uuid.UUID, ids []uuid.UUID) (map[uuid.UUID]bool, error) {
+	over := make(map[uuid.UUID]bool)
+	if rs.DB == nil || len(ids) == 0 {
+		return over, nil
+	}
+	type count struct {
+		ID uuid.UUID
+		N  int
+	}
+
+	if exposureCaps.DailyImpressions > 0 {
+		var rows []count
+		err := rs.DB.Raw(`
+			SELECT rec_user_id AS id, COUNT(DISTINCT user_id) AS n
+			FROM recommendation_impressions
+			WHERE rec_user_id IN ? AND user_id <> ? AND served_at > ?
+			GROUP BY rec_user_id
+			HAVING COUNT(DISTINCT user_id) >= ?
+		`, ids, viewerID, time.Now().Add(-exposureWindow), exposureCaps.DailyImpressions).Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			over[r.ID] = true
+		}
+	}
+
+	if exposureCaps.PendingRequests > 0 {
+		var rows []count
+		err := rs.DB.Raw(`
+			SELECT connection_id AS id, COUNT(*) AS n
+			FROM connections
+			WHERE connection_id IN ? AND status = 'pending'
+			GROUP BY connection_id
+			HAVING COUNT(*) >= ?
+		`, ids, exposureCaps.PendingRequests).Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			over[r.ID] = true
+		}
+	}
+	return over, nil
+}
+
+// demoteOverExposed moves candidates over their exposure caps to the end of order,
+// keeping the relative order of both groups. id(i) returns the user ID of the i-th
+// candidate of the ranked list order points into. Returns the new order and the set of
+// demoted users.
+func (rs *RecommendationService) demoteOverExposed(
+	viewerID uuid.UUID, order []int, id func(i int) uuid.UUID,
+) ([]int, map[uuid.UUID]bool, error) {
+	if exposureCaps.DailyImpressions == 0 && exposureCaps.PendingRequests == 0 {
+		return order, nil, nil
+	}
+	ids := make([]uuid.UUID, len(order))
+	for i := range order {
+		ids[i] = id(i)
+	}
+	over, err := rs.overExposed(viewerID, ids)
+	if err != nil || len(over) == 0 {
+		return order, nil, err
+	}
+	out := make([]int, 0, len(order))
+	var demoted []int
+	for _, i := range order {
+		if over[id(i)] {
+			demoted = append(demoted, i)
+		} else {
+			out = append(out, i)
+		}
+	}
+	return append(out, demoted...), over, nil
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"m/backend/models"
+)
+
+// Per-user field weights (Preference.FieldWeights) multiply the base weight of each
+// FieldConfig when scoring in affinity mode: 0 ignores a field, 1 is neutral and
+// MaxFieldWeight makes it count five times as much. They replace the old boolean
+// priorities, which are still accepted by the API and map to a weight of 2.
+
+var ErrInvalidFieldWeights = errors.New("invalid field weights")
+
+const (
+	// MaxFieldWeight is the largest multiplier a user can give a field.
+	MaxFieldWeight = 5.0
+	// priorityFieldWeight is what a legacy priority flag means.
+	priorityFieldWeight = 2.0
+)
+
+// NormalizeFieldWeights validates user field weights against the scoring fields and
+// returns them keyed by the canonical field name (matching is case-insensitive).
+// Weights equal to 1 are dropped since that is the default.
+func (rs *RecommendationService) NormalizeFieldWeights(in map[string]float64) (models.FieldWeights, error) {
+	canonical := make(map[string]string, len(rs.FieldConfigs))
+	for _, fc := range rs.FieldConfigs {
+		canonical[strings.ToLower(fc.Name)] = fc.Name
+	}
+	out := models.FieldWeights{}
+	for name, w := range in {
+		field, ok := canonical[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown field %q (expected one of %s)",
+				ErrInvalidFieldWeights, name, strings.Join(rs.FieldNames(), ", "))
+		}
+		if math.IsNaN(w) || w < 0 || w > MaxFieldWeight {
+			return nil, fmt.Errorf("%w: %s must be between 0 and %g", ErrInvalidFieldWeights, field, MaxFieldWeight)
+		}
+		if w != 1 {
+			out[field] = w
+		}
+	}
+	return out, nil
+}
+
+// ApplyPriorityFlags folds legacy priority flags (field name -> flag) into weights:
+// a set flag gives the field priorityFieldWeight, a cleared flag removes a weight that
+// only came from a flag. Nil flags are left alone.
+func ApplyPriorityFlags(weights models.FieldWeights, flags map[string]*bool) models.FieldWeights {
+	for field, flag := range flags {
+		if flag == nil {
+			continue
+		}
+		if weights == nil {
+			weights = models.FieldWeights{}
+		}
+		if *flag {
+			if weights.Weight(field) <= 1 {
+				weights[field] = priorityFieldWeight
+			}
+		} else if weights[field] == priorityFieldWeight {
+			delete(weights, field)
+		}
+	}
+	return weights
+}
+
+// priorityFlags returns the legacy priority flags of a custom search. Only set flags
+// count, so a search without flags keeps its field weights.
+func (f RecommendationFilters) priorityFlags() map[string]*bool {
+	flags := map[string]*bool{}
+	set := func(field string, on bool) {
+		if on {
+			flags[field] = &on
+		}
+	}
+	set("Interests", f.PriorityInterests)
+	set("Hobbies", f.PriorityHobbies)
+	set("Music", f.PriorityMusic)
+	set("Food", f.PriorityFood)
+	set("Travel", f.PriorityTravel)
+	return flags
+}
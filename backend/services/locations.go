@@ -0,0 +1,339 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"m/backend/models"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Saved locations let a user keep several named places and choose which one is used
+// for recommendations. Activating a location copies it into Profile (latitude, longitude,
+// city and earth_loc), so GetNearbyUsers and everyone else's nearby search see the user
+// there. Trips carry a date range; the location job (Run) activates a trip when it
+// starts and switches back to the previously active location when it ends.
+// Every move of the profile is reported to the nearby matcher (see nearby_matcher.go).
+
+var (
+	ErrInvalidLocation    = errors.New("invalid location")
+	ErrTooManyLocations   = errors.New("too many saved locations")
+	ErrLocationNotInRange = errors.New("location is outside of its trip dates")
+)
+
+// MaxSavedLocations is the number of locations a user can save.
+const MaxSavedLocations = 10
+
+// LocationInput is the editable part of a saved location.
+type LocationInput struct {
+	Name      string     `json:"name"`
+	City      string     `json:"city"`
+	Latitude  float64    `json:"latitude"`
+	Longitude float64    `json:"longitude"`
+	StartsAt  *time.Time `json:"startsAt"`
+	EndsAt    *time.Time `json:"endsAt"`
+}
+
+// LocationService manages saved locations and switches the active one for trips.
+type LocationService struct {
+	DB *gorm.DB
+}
+
+// NewLocationService creates a service for saved locations.
+func NewLocationService(db *gorm.DB) *LocationService {
+	logrus.Info("LocationService initialized")
+	return &LocationService{DB: db}
+}
+
+// validate checks name, coordinates and the trip date range.
+func (in LocationInput) validate() error {
+	if strings.TrimSpace(in.Name) == "" || len(in.Name) > 100 {
+		return fmt.Errorf("%w: name is required (max 100 characters)", ErrInvalidLocation)
+	}
+	if len(in.City) > 100 {
+		return fmt.Errorf("%w: city is too long", ErrInvalidLocation)
+	}
+	if in.Latitude < -90 || in.Latitude > 90 || in.Longitude < -180 || in.Longitude > 180 {
+		return fmt.Errorf("%w: coordinates are out of range", ErrInvalidLocation)
+	}
+	if (in.StartsAt == nil) != (in.EndsAt == nil) {
+		return fmt.Errorf("%w: a trip needs both startsAt and endsAt", ErrInvalidLocation)
+	}
+	if in.StartsAt != nil && !in.EndsAt.After(*in.StartsAt) {
+		return fmt.Errorf("%w: endsAt must be after startsAt", ErrInvalidLocation)
+	}
+	return nil
+}
+
+// List returns the user's saved locations, oldest first.
+func (ls *LocationService) List(userID uuid.UUID) ([]models.SavedLocation, error) {
+	var list []models.SavedLocation
+	err := ls.DB.Where("user_id = ?", userID).Order("created_at, id").Find(&list).Error
+	return list, err
+}
+
+// Get returns a saved location owned by the user, or gorm.ErrRecordNotFound.
+func (ls *LocationService) Get(userID uuid.UUID, id uint) (*models.SavedLocation, error) {
+	var loc models.SavedLocation
+	if err := ls.DB.Where("id = ? AND user_id = ?", id, userID).First(&loc).Error; err != nil {
+		return nil, err
+	}
+	return &loc, nil
+}
+
+// Create saves a new location. The user's first location becomes active.
+func (ls *LocationService) Create(userID uuid.UUID, in LocationInput) (*models.SavedLocation, error) {
+	if err := in.validate(); err != nil {
+		return nil, err
+	}
+	loc := models.SavedLocation{
+		UserID:    userID,
+		Name:      strings.TrimSpace(in.Name),
+		City:      in.City,
+		Latitude:  in.Latitude,
+		Longitude: in.Longitude,
+		StartsAt:  in.StartsAt,
+		EndsAt:    in.EndsAt,
+	}
+	err := ls.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.SavedLocation{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= MaxSavedLocations {
+			return ErrTooManyLocations
+		}
+		if err := tx.Create(&loc).Error; err != nil {
+			return err
+		}
+		if count == 0 && loc.StartsAt == nil {
+			return activate(tx, &loc)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if loc.Active {
+		ProfileChanged(userID)
+	}
+	return &loc, nil
+}
+
+// Update changes a saved location. If it is active, the profile is updated too.
+// Changing trip dates lets the job handle the new range again.
+func (ls *LocationService) Update(userID uuid.UUID, id uint, in LocationInput) (*models.SavedLocation, error) {
+	if err := in.validate(); err != nil {
+		return nil, err
+	}
+	loc, err := ls.Get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	datesChanged := !sameTime(loc.StartsAt, in.StartsAt) || !sameTime(loc.EndsAt, in.EndsAt)
+	loc.Name = strings.TrimSpace(in.Name)
+	loc.City = in.City
+	loc.Latitude = in.Latitude
+	loc.Longitude = in.Longitude
+	loc.StartsAt = in.StartsAt
+	loc.EndsAt = in.EndsAt
+	if datesChanged && !loc.Active {
+		loc.Switched = false
+	}
+	err = ls.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(loc).Error; err != nil {
+			return err
+		}
+		if loc.Active {
+			return applyToProfile(tx, loc)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if loc.Active {
+		ProfileChanged(userID)
+	}
+	return loc, nil
+}
+
+// Delete removes a saved location. Deleting the active location keeps the profile
+// coordinates where they are.
+func (ls *LocationService) Delete(userID uuid.UUID, id uint) error {
+	res := ls.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&models.SavedLocation{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Activate makes the location the one used for recommendations.
+// A trip can only be activated while it is in progress.
+func (ls *LocationService) Activate(userID uuid.UUID, id uint) (*models.SavedLocation, error) {
+	loc, err := ls.Get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if loc.StartsAt != nil {
+		now := time.Now()
+		if now.Before(*loc.StartsAt) || !now.Before(*loc.EndsAt) {
+			return nil, ErrLocationNotInRange
+		}
+	}
+	if err := ls.DB.Transaction(func(tx *gorm.DB) error { return activate(tx, loc) }); err != nil {
+		return nil, err
+	}
+	ProfileChanged(userID)
+	return loc, nil
+}
+
+// activate marks loc as the user's only active location and copies it into the profile.
+func activate(tx *gorm.DB, loc *models.SavedLocation) error {
+	if err := tx.Model(&models.SavedLocation{}).
+		Where("user_id = ? AND id <> ?", loc.UserID, loc.ID).
+		Update("active", false).Error; err != nil {
+		return err
+	}
+	loc.Active = true
+	if err := tx.Model(loc).Updates(map[string]interface{}{
+		"active":       true,
+		"switched":     loc.Switched,
+		"return_to_id": loc.ReturnToID,
+	}).Error; err != nil {
+		return err
+	}
+	return applyToProfile(tx, loc)
+}
+
+// applyToProfile moves the user's profile (and earth_loc) to the location.
+func applyToProfile(tx *gorm.DB, loc *models.SavedLocation) error {
+	updates := map[string]interface{}{"latitude": loc.Latitude, "longitude": loc.Longitude}
+	if loc.City != "" {
+		updates["city"] = loc.City
+	}
+	if err := tx.Model(&models.Profile{}).Where("user_id = ?", loc.UserID).Updates(updates).Error; err != nil {
+		return err
+	}
+	return tx.Exec(`
+		UPDATE profiles
+		SET earth_loc = ll_to_earth(?, ?)
+		WHERE user_id = ?`,
+		loc.Latitude, loc.Longitude, loc.UserID,
+	).Error
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// SwitchTrips activates trips that have started and restores the previous location
+// for trips that have ended. Returns the number of users whose location changed.
+func (ls *LocationService) SwitchTrips(now time.Time) (int, error) {
+	switched := 0
+
+	// Trips that started: remember the active location, then activate the trip
+	var started []models.SavedLocation
+	if err := ls.DB.
+		Where("starts_at <= ? AND ends_at > ? AND active = ? AND switched = ?", now, now, false, false).
+		Order("starts_at").
+		Find(&started).Error; err != nil {
+		return 0, err
+	}
+	seen := make(map[uuid.UUID]bool)
+	for i := range started {
+		trip := &started[i]
+		if seen[trip.UserID] {
+			continue // overlapping trips: the earliest one wins
+		}
+		seen[trip.UserID] = true
+		err := ls.DB.Transaction(func(tx *gorm.DB) error {
+			var current models.SavedLocation
+			err := tx.Where("user_id = ? AND active = ?", trip.UserID, true).First(&current).Error
+			if err == nil {
+				if current.ReturnToID != nil {
+					trip.ReturnToID = current.ReturnToID // trip follows another trip
+				} else {
+					trip.ReturnToID = &current.ID
+				}
+			} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			trip.Switched = true
+			return activate(tx, trip)
+		})
+		if err != nil {
+			logrus.Errorf("SwitchTrips: activating trip %d of %s failed: %v", trip.ID, trip.UserID, err)
+			continue
+		}
+		logrus.Infof("SwitchTrips: trip %q started for %s", trip.Name, trip.UserID)
+		ProfileChanged(trip.UserID)
+		switched++
+	}
+
+	// Trips that ended: go back to the location that was active before
+	var ended []models.SavedLocation
+	if err := ls.DB.
+		Where("active = ? AND ends_at <= ?", true, now).
+		Find(&ended).Error; err != nil {
+		return switched, err
+	}
+	for i := range ended {
+		trip := &ended[i]
+		err := ls.DB.Transaction(func(tx *gorm.DB) error {
+			var back models.SavedLocation
+			err := gorm.ErrRecordNotFound
+			if trip.ReturnToID != nil {
+				err = tx.Where("id = ? AND user_id = ?", *trip.ReturnToID, trip.UserID).First(&back).Error
+			}
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				// Fall back to the oldest permanent location
+				err = tx.Where("user_id = ? AND starts_at IS NULL", trip.UserID).
+					Order("created_at, id").First(&back).Error
+			}
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return tx.Model(trip).Update("active", false).Error
+			} else if err != nil {
+				return err
+			}
+			back.ReturnToID = nil
+			return activate(tx, &back)
+		})
+		if err != nil {
+			logrus.Errorf("SwitchTrips: ending trip %d of %s failed: %v", trip.ID, trip.UserID, err)
+			continue
+		}
+		logrus.Infof("SwitchTrips: trip %q ended for %s", trip.Name, trip.UserID)
+		ProfileChanged(trip.UserID)
+		switched++
+	}
+	return switched, nil
+}
+
+// Run switches trip locations every interval. Blocks forever; start it in a
+// goroutine. A non-positive interval disables the job.
+func (ls *LocationService) Run(interval time.Duration) {
+	if interval <= 0 {
+		logrus.Info("LocationService: trip switching disabled")
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := ls.SwitchTrips(time.Now()); err != nil {
+			logrus.Errorf("LocationService: switching trips failed: %v", err)
+		}
+		<-ticker.C
+	}
+}
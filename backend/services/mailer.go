@@ -0,0 +1,43 @@
+package services
+
+import (
+	"fmt"
+	"net/smtp"
+	"strings"
+)
+
+// Mailer sends plain text email through an SMTP server with PLAIN authentication.
+type Mailer struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	From     string
+}
+
+// NewMailer creates a mailer sending from the SMTP user's address.
+func NewMailer(host string, port int, user, password string) *Mailer {
+	return &Mailer{Host: host, Port: port, User: user, Password: password, From: user}
+}
+
+// Send sends a plain text email to one recipient.
+func (m *Mailer) Send(to, subject, body string) error {
+	if strings.ContainsAny(to, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+		return fmt.Errorf("Mailer: invalid header value")
+	}
+	msg := strings.Join([]string{
+		"From: " + m.From,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+	var auth smtp.Auth
+	if m.User != "" {
+		auth = smtp.PlainAuth("", m.User, m.Password, m.Host)
+	}
+	addr := fmt.Sprintf("%s:%d", m.Host, m.Port)
+	return smtp.SendMail(addr, auth, m.From, []string{to}, []byte(msg))
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"m/backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Message editing and deletion. Senders can edit their messages, and delete them for
+// everyone, during MESSAGE_EDIT_WINDOW after sending; every edit keeps the previous
+// text in models.MessageEdit. A message deleted for everyone stays in the history as
+// an empty placeholder with DeletedAt set, and its edit history is dropped. Any
+// participant can delete a message for themselves at any time, which only hides it
+// from their own history (models.HiddenMessage).
+
+var ErrMessageLocked = errors.New("message can no longer be changed")
+
+var messageEditWindow = time.Hour
+
+// SetMessageEditWindow sets how long after sending a message can be edited or deleted
+// for everyone. 0 removes the limit.
+func SetMessageEditWindow(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	messageEditWindow = d
+}
+
+// ownMessage loads a message of the chat for a change by its sender: not deleted and
+// still inside the edit window. Must run in the transaction that changes it.
+func ownMessage(tx *gorm.DB, chatID, messageID uint, userID uuid.UUID) (*models.Message, error) {
+	var msg models.Message
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND chat_id = ?", messageID, chatID).
+		First(&msg).Error; err != nil {
+		return nil, err
+	}
+	if msg.SenderID != userID {
+		return nil, ErrChatForbidden
+	}
+	if msg.DeletedAt != nil {
+		return nil, fmt.Errorf("%w: the message was deleted", ErrMessageLocked)
+	}
+	if messageEditWindow > 0 && time.Since(msg.Timestamp) > messageEditWindow {
+		return nil, fmt.Errorf("%w: messages can only be changed for %s after sending", ErrMessageLocked, messageEditWindow)
+	}
+	return &msg, nil
+}
+
+// EditMessage replaces the text of the user's own message and keeps the previous one.
+func (cs *ChatService) EditMessage(chatID, messageID uint, userID uuid.UUID, content string) (*models.Message, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, fmt.Errorf("%w: content cannot be empty", ErrInvalidChat)
+	}
+	if _, err := cs.Participant(chatID, userID); err != nil {
+		return nil, err
+	}
+	var msg *models.Message
+	err := cs.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if msg, err = ownMessage(tx, chatID, messageID, userID); err != nil {
+			return err
+		}
+		if msg.Content == content {
+			return nil
+		}
+		if err := tx.Create(&models.MessageEdit{MessageID: msg.ID, Content: msg.Content}).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		msg.Content = content
+		msg.EditedAt = &now
+		return tx.Model(msg).Updates(map[string]interface{}{"content": content, "edited_at": now}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// DeleteMessage deletes a message for everyone (sender only, inside the edit window)
+// or hides it for the user. Returns the message as it is now.
+func (cs *ChatService) DeleteMessage(chatID, messageID uint, userID uuid.UUID, forEveryone bool) (*models.Message, error) {
+	if _, err := cs.Participant(chatID, userID); err != nil {
+		return nil, err
+	}
+	if !forEveryone {
+		var msg models.Message
+		if err := cs.DB.Where("id = ? AND chat_id = ?", messageID, chatID).First(&msg).Error; err != nil {
+			return nil, err
+		}
+		hidden := models.HiddenMessage{MessageID: msg.ID, UserID: userID}
+		if err := cs.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&hidden).Error; err != nil {
+			return nil, err
+		}
+		return &msg, nil
+	}
+	var msg *models.Message
+	err := cs.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if msg, err = ownMessage(tx, chatID, messageID, userID); err != nil {
+			return err
+		}
+		if err := tx.Where("message_id = ?", msg.ID).Delete(&models.MessageEdit{}).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		msg.Content = ""
+		msg.DeletedAt = &now
+		return tx.Model(msg).Updates(map[string]interface{}{"content": "", "deleted_at": now}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// MessageEdits returns the previous versions of a message, oldest first. Only
+// participants who have not hidden the message can see them.
+func (cs *ChatService) MessageEdits(chatID, messageID uint, userID uuid.UUID) ([]models.MessageEdit, error) {
+	if _, err := cs.Participant(chatID, userID); err != nil {
+		return nil, err
+	}
+	var msg models.Message
+	if err := cs.DB.
+		Where("id = ? AND chat_id = ?", messageID, chatID).
+		Where("id NOT IN (?)", cs.DB.Model(&models.HiddenMessage{}).Select("message_id").Where("user_id = ?", userID)).
+		First(&msg).Error; err != nil {
+		return nil, err
+	}
+	list := []models.MessageEdit{}
+	err := cs.DB.Where("message_id = ?", msg.ID).Order("edited_at, id").Find(&list).Error
+	return list, err
+}
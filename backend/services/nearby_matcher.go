@@ -0,0 +1,253 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"m/backend/models"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// The nearby matcher tells people when someone new shows up in their search radius.
+// Profile, bio and location changes (including trip switches) call ProfileChanged; the
+// matcher then looks for users whose MaxRadius covers the changed user's location and
+// whose profile scores at least MinScore against them, and sends each a
+// new_match_nearby event (stored for offline users, see notifications.go).
+// Alerts are rate limited: a person is announced to the same user only once (see
+// models.NearbyAlert), and nobody gets more than DailyLimit alerts in nearbyAlertWindow.
+// Users with an incomplete profile are not announced, nor pairs where either side
+// declined the other or that are already connected. The watcher's dealbreakers and
+// age, gender and language filters apply, and in their mutual mode the newcomer's radius,
+// dealbreakers and filters too (see dealbreakers.go and demographics.go).
+
+// nearbyAlertWindow is the period DailyLimit applies to.
+const nearbyAlertWindow = 24 * time.Hour
+
+// nearbyQueueSize is how many changed users can wait for matching; more are dropped.
+const nearbyQueueSize = 1000
+
+// NearbyMatcher matches changed profiles against the users around them.
+type NearbyMatcher struct {
+	DB            *gorm.DB
+	Recs          *RecommendationService
+	Notifications *NotificationService
+	MinScore      float64
+	DailyLimit    int
+
+	queue   chan uuid.UUID
+	mu      sync.Mutex
+	pending map[uuid.UUID]bool
+}
+
+// NewNearbyMatcher creates a matcher alerting users about matches scoring at least
+// minScore, at most dailyLimit times a day each.
+func NewNearbyMatcher(db *gorm.DB, recs *RecommendationService, minScore float64, dailyLimit int) *NearbyMatcher {
+	logrus.Info("NearbyMatcher initialized")
+	return &NearbyMatcher{
+		DB:            db,
+		Recs:          recs,
+		Notifications: NewNotificationService(db),
+		MinScore:      minScore,
+		DailyLimit:    dailyLimit,
+		queue:         make(chan uuid.UUID, nearbyQueueSize),
+		pending:       make(map[uuid.UUID]bool),
+	}
+}
+
+var nearbyMatcher *NearbyMatcher
+
+// SetNearbyMatcher sets the matcher notified by ProfileChanged.
+func SetNearbyMatcher(m *NearbyMatcher) {
+	nearbyMatcher = m
+}
+
+// ProfileChanged queues the user for nearby matching after a profile, bio or location
+// change. Does nothing if no matcher is set.
+func ProfileChanged(userID uuid.UUID) {
+	if nearbyMatcher != nil {
+		nearbyMatcher.Enqueue(userID)
+	}
+}
+
+// Enqueue queues the user for matching. A user already waiting is not queued twice,
+// so a burst of edits is matched once. Never blocks.
+func (m *NearbyMatcher) Enqueue(userID uuid.UUID) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pending[userID] {
+		return
+	}
+	select {
+	case m.queue <- userID:
+		m.pending[userID] = true
+	default:
+		logrus.Warnf("NearbyMatcher: queue full, dropping %s", userID)
+	}
+}
+
+// watcher is an existing user whose radius covers the changed user.
+type watcher struct {
+	UserID   uuid.UUID
+	Distance float64
+}
+
+// watchers returns the users whose MaxRadius covers the location, closest first,
+// skipping pairs that were already announced, declined either way (until the decline
+// expires), are connected or are ruled out by dealbreakers or filters (and, in the
+// watcher's mutual mode, the newcomer's). The earth_box of the largest radius anyone
+// uses lets the profiles index narrow the scan before the per-watcher distance check.
+func (m *NearbyMatcher) watchers(userID uuid.UUID, lat, lon float64) ([]watcher, error) {
+	var list []watcher
+	err := m.DB.Raw(`
+		SELECT p.user_id, earth_distance(p.earth_loc, ll_to_earth(?, ?)) / 1000.0 AS distance
+		FROM profiles p
+		JOIN preferences pr ON pr.user_id = p.user_id
+		LEFT JOIN bios wb ON wb.user_id = p.user_id
+		LEFT JOIN bios nb ON nb.user_id = ?
+		LEFT JOIN preferences np ON np.user_id = ?
+		JOIN profiles nf ON nf.user_id = ?
+		WHERE p.user_id <> ?
+		  AND p.earth_loc IS NOT NULL
+		  AND pr.max_radius > 0
+		  AND earth_box(ll_to_earth(?, ?), (SELECT MAX(max_radius) FROM preferences) * 1000.0) @> p.earth_loc
+		  AND earth_distance(p.earth_loc, ll_to_earth(?, ?)) <= pr.max_radius * 1000.0
+		  AND NOT `+dealbreakerHitSQL("pr", "nb")+`
+		  AND `+demographicMatchSQL("pr", "nf")+`
+		  AND (
+		    NOT pr.mutual_matching
+		    OR (
+		      (COALESCE(np.max_radius, 0) <= 0 OR earth_distance(p.earth_loc, ll_to_earth(?, ?)) <= np.max_radius * 1000.0)
+		      AND NOT `+dealbreakerHitSQL("np", "wb")+`
+		      AND `+demographicMatchSQL("np", "p")+`
+		    )
+		  )
+		  AND NOT EXISTS (
+		    SELECT 1 FROM nearby_alerts a WHERE a.user_id = p.user_id AND a.matched_user_id = ?
+		  )
+		  AND NOT EXISTS (
+		    SELECT 1 FROM recommendations r
+		    LEFT JOIN preferences pr ON pr.user_id = r.user_id
+		    WHERE `+activeDeclineCondition+`
+		      AND ((r.user_id = p.user_id AND r.rec_user_id = ?) OR (r.user_id = ? AND r.rec_user_id = p.user_id))
+		  )
+		  AND NOT EXISTS (
+		    SELECT 1 FROM connections c
+		    WHERE (c.user_id = p.user_id AND c.connection_id = ?) OR (c.user_id = ? AND c.connection_id = p.user_id)
+		  )
+		ORDER BY distance
+		LIMIT ?
+	`, lat, lon, userID, userID, userID, userID, lat, lon, lat, lon, lat, lon,
+		userID, declineTTLDays, declineTTLDays, userID, userID, userID, userID, m.Recs.CandidateLimit).Scan(&list).Error
+	return list, err
+}
+
+// alertsToday returns how many nearby alerts each user received in nearbyAlertWindow.
+func (m *NearbyMatcher) alertsToday(ids []uuid.UUID, now time.Time) (map[uuid.UUID]int, error) {
+	var rows []struct {
+		UserID uuid.UUID
+		N      int
+	}
+	err := m.DB.Model(&models.NearbyAlert{}).
+		Select("user_id, COUNT(*) AS n").
+		Where("user_id IN ? AND sent_at > ?", ids, now.Add(-nearbyAlertWindow)).
+		Group("user_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[uuid.UUID]int, len(rows))
+	for _, r := range rows {
+		out[r.UserID] = r.N
+	}
+	return out, nil
+}
+
+// MatchUser announces the user to everyone nearby who would score them at least MinScore.
+// Returns the number of users alerted.
+func (m *NearbyMatcher) MatchUser(userID uuid.UUID) (int, error) {
+	var u models.User
+	if err := m.DB.Preload("Profile").Preload("Bio").First(&u, "id = ?", userID).Error; err != nil {
+		return 0, err
+	}
+	if validateUserData(u) != nil || (u.Profile.Latitude == 0 && u.Profile.Longitude == 0) {
+		return 0, nil
+	}
+
+	list, err := m.watchers(userID, u.Profile.Latitude, u.Profile.Longitude)
+	if err != nil || len(list) == 0 {
+		return 0, err
+	}
+	ids := make([]uuid.UUID, len(list))
+	for i, w := range list {
+		ids[i] = w.UserID
+	}
+	now := time.Now()
+	sent, err := m.alertsToday(ids, now)
+	if err != nil {
+		return 0, err
+	}
+	var others []models.User
+	if err := m.DB.Preload("Bio").Preload("Preference").Where("id IN ?", ids).Find(&others).Error; err != nil {
+		return 0, err
+	}
+	byID := make(map[uuid.UUID]models.User, len(others))
+	for _, o := range others {
+		byID[o.ID] = o
+	}
+
+	alerted := 0
+	for _, w := range list {
+		if sent[w.UserID] >= m.DailyLimit {
+			continue
+		}
+		other := byID[w.UserID]
+		score, _ := m.Recs.scoreCandidate("affinity", other.Bio, other.Preference, u.Bio, w.Distance, 0.05)
+		if score < m.MinScore {
+			continue
+		}
+		alert := models.NearbyAlert{UserID: w.UserID, MatchedUserID: userID, Distance: w.Distance, Score: score}
+		if err := m.DB.Create(&alert).Error; err != nil {
+			logrus.Errorf("NearbyMatcher: recording alert for %s failed: %v", w.UserID, err)
+			continue
+		}
+		if err := m.Notifications.Notify(w.UserID, "new_match_nearby", map[string]interface{}{
+			"user_id":    userID.String(),
+			"first_name": u.Profile.FirstName,
+			"photo_url":  u.Profile.PhotoURL,
+			"city":       u.Profile.City,
+			"distance":   w.Distance,
+			"score":      score,
+			"found_at":   now.Unix(),
+		}); err != nil {
+			logrus.Errorf("NearbyMatcher: notifying %s failed: %v", w.UserID, err)
+		}
+		alerted++
+	}
+	return alerted, nil
+}
+
+// Run matches queued users as they arrive. Blocks forever; start it in a goroutine.
+// A non-positive DailyLimit disables the matcher.
+func (m *NearbyMatcher) Run() {
+	if m.DailyLimit <= 0 {
+		logrus.Info("NearbyMatcher: new match alerts disabled")
+		return
+	}
+	for userID := range m.queue {
+		m.mu.Lock()
+		delete(m.pending, userID)
+		m.mu.Unlock()
+
+		n, err := m.MatchUser(userID)
+		if err != nil {
+			logrus.Errorf("NearbyMatcher: matching %s failed: %v", userID, err)
+			continue
+		}
+		if n > 0 {
+			logrus.Infof("NearbyMatcher: %s announced to %d users nearby", userID, n)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"encoding/json"
+	"time"
+
+	"m/backend/models"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Notifications are real-time events produced by background jobs (saved search alerts
+// and similar). Services cannot import the sockets package, so main wires the WebSocket
+// delivery in with SetNotifier. If the user has no open connection the event is stored
+// and delivered when they connect again (or fetched with GET /notifications).
+
+// Notifier delivers an event to the user's open connections and reports whether any
+// connection accepted it.
+type Notifier func(userID uuid.UUID, payload interface{}) bool
+
+var notifier Notifier
+
+// SetNotifier sets the real-time delivery used by NotificationService.
+func SetNotifier(n Notifier) {
+	notifier = n
+}
+
+// NotificationService sends events to users and stores them for offline users.
+type NotificationService struct {
+	DB *gorm.DB
+}
+
+// NewNotificationService creates a notification service.
+func NewNotificationService(db *gorm.DB) *NotificationService {
+	return &NotificationService{DB: db}
+}
+
+// Notify sends payload (which must contain the event "type") to the user,
+// storing it for later delivery if the user is offline.
+func (ns *NotificationService) Notify(userID uuid.UUID, eventType string, payload map[string]interface{}) error {
+	payload["type"] = eventType
+	if notifier != nil && notifier(userID, payload) {
+		return nil
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	logrus.Debugf("Notify: %s offline, storing %s event", userID, eventType)
+	return ns.DB.Create(&models.Notification{UserID: userID, Type: eventType, Payload: string(data)}).Error
+}
+
+// TakePending returns the user's undelivered events (oldest first) as raw JSON
+// and marks them delivered.
+func (ns *NotificationService) TakePending(userID uuid.UUID) ([]json.RawMessage, error) {
+	var list []models.Notification
+	err := ns.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND delivered_at IS NULL", userID).
+			Order("created_at, id").Find(&list).Error; err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			return nil
+		}
+		ids := make([]uint, len(list))
+		for i, n := range list {
+			ids[i] = n.ID
+		}
+		return tx.Model(&models.Notification{}).Where("id IN ?", ids).
+			Update("delivered_at", time.Now()).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]json.RawMessage, len(list))
+	for i, n := range list {
+		out[i] = json.RawMessage(n.Payload)
+	}
+	return out, nil
+}
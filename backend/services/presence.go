@@ -4,7 +4,12 @@ import (
 	"context"
 	"time"
 
+	"m/backend/models"
+
 	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
 // PresenceService uses Redis as a fast in-memory store to track user online status.
@@ -12,6 +17,9 @@ import (
 // In this service, each user gets a presence key with a short TTL (time-to-live).
 // If the key exists, the user is considered online; if not, offline.
 // This approach is efficient for real-time presence tracking in chat and social apps.
+// When DB is set, activity is also persisted as Profile.LastActiveAt (at most once per
+// models.LastActiveResolution), so ranking can tell recently active accounts from
+// abandoned ones after the Redis key is gone.
 
 const (
 	PresencePrefix = "presence:"
@@ -23,6 +31,7 @@ const (
 type PresenceService struct {
 	Rdb *redis.Client
 	Ctx context.Context
+	DB  *gorm.DB
 }
 
 // NewPresenceService creates a service for tracking user online status using Redis.
@@ -38,6 +47,7 @@ func NewPresenceService(rdb *redis.Client) *PresenceService {
 func (ps *PresenceService) Touch(userID string) error {
 	// Set a key in Redis with a short TTL to mark the user as online
 	key := PresencePrefix + userID
+	ps.persistLastActive(userID)
 	return ps.Rdb.Set(ps.Ctx, key, "1", PresenceTTL).Err()
 }
 
@@ -46,9 +56,24 @@ func (ps *PresenceService) Touch(userID string) error {
 func (ps *PresenceService) SetOffline(userID string) error {
 	// Remove the user's presence key from Redis to mark as offline
 	key := PresencePrefix + userID
+	ps.persistLastActive(userID)
 	return ps.Rdb.Del(ps.Ctx, key).Err()
 }
 
+// persistLastActive stores the activity time in the user's profile when DB is set.
+func (ps *PresenceService) persistLastActive(userID string) {
+	if ps.DB == nil {
+		return
+	}
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return
+	}
+	if err := models.UpdateLastActive(ps.DB, id, time.Now()); err != nil {
+		logrus.Warnf("presence: persisting last activity of %s failed: %v", userID, err)
+	}
+}
+
 // IsOnline checks if a user is online (by presence of a key in Redis).
 // Returns true if the presence key exists (user is online), false otherwise.
 func (ps *PresenceService) IsOnline(userID string) (bool, error) {
@@ -57,3 +82,25 @@ func (ps *PresenceService) IsOnline(userID string) (bool, error) {
 	cnt, err := ps.Rdb.Exists(ps.Ctx, key).Result()
 	return cnt == 1, err
 }
+
+// OnlineMany checks the online status of several users with a single Redis round trip.
+// Every requested ID is present in the result.
+func (ps *PresenceService) OnlineMany(userIDs []string) (map[string]bool, error) {
+	result := make(map[string]bool, len(userIDs))
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+	keys := make([]string, len(userIDs))
+	for i, id := range userIDs {
+		keys[i] = PresencePrefix + id
+		result[id] = false
+	}
+	vals, err := ps.Rdb.MGet(ps.Ctx, keys...).Result()
+	if err != nil {
+		return result, err
+	}
+	for i, v := range vals {
+		result[userIDs[i]] = v != nil
+	}
+	return result, nil
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"m/backend/models"
+)
+
+// Profile completeness drives the cold-start mode: full recommendations need both names
+// and every Bio field; until then GetColdStartRecommendations ranks nearby and popular
+// profiles using whatever is filled in. Completeness is the share of all profile fields
+// (required and optional) that are filled, for the frontend to display.
+
+// ErrIncompleteProfile is returned when the profile lacks fields required for full
+// recommendations. MissingFields uses the JSON names of the fields ("firstName", "music", ...).
+type ErrIncompleteProfile struct {
+	MissingFields []string `json:"missingFields"`
+	Completeness  int      `json:"completeness"`
+}
+
+func (e *ErrIncompleteProfile) Error() string {
+	return fmt.Sprintf("please complete your profile to get recommendations (missing: %s)",
+		strings.Join(e.MissingFields, ", "))
+}
+
+// ProfileStatus describes how complete a profile is.
+// MissingFields lists every empty field, RequiredMissing only those needed for full recommendations.
+type ProfileStatus struct {
+	Completeness    int      `json:"completeness"`
+	Complete        bool     `json:"complete"`
+	MissingFields   []string `json:"missingFields"`
+	RequiredMissing []string `json:"requiredMissing"`
+}
+
+// profileField is one field counted for completeness.
+type profileField struct {
+	name     string
+	required bool
+	value    func(u models.User) string
+}
+
+var profileFields = []profileField{
+	{"firstName", true, func(u models.User) string { return u.Profile.FirstName }},
+	{"lastName", true, func(u models.User) string { return u.Profile.LastName }},
+	{"city", false, func(u models.User) string { return u.Profile.City }},
+	{"about", false, func(u models.User) string { return u.Profile.About }},
+	{"photo", false, func(u models.User) string { return u.Profile.PhotoURL }},
+	{"interests", true, func(u models.User) string { return u.Bio.Interests }},
+	{"hobbies", true, func(u models.User) string { return u.Bio.Hobbies }},
+	{"music", true, func(u models.User) string { return u.Bio.Music }},
+	{"food", true, func(u models.User) string { return u.Bio.Food }},
+	{"travel", true, func(u models.User) string { return u.Bio.Travel }},
+	{"lookingFor", true, func(u models.User) string { return u.Bio.LookingFor }},
+}
+
+// GetProfileStatus returns the completeness of a user loaded with Profile and Bio.
+func GetProfileStatus(u models.User) ProfileStatus {
+	status := ProfileStatus{MissingFields: []string{}, RequiredMissing: []string{}}
+	filled := 0
+	for _, f := range profileFields {
+		if strings.TrimSpace(f.value(u)) != "" {
+			filled++
+			continue
+		}
+		status.MissingFields = append(status.MissingFields, f.name)
+		if f.required {
+			status.RequiredMissing = append(status.RequiredMissing, f.name)
+		}
+	}
+	status.Completeness = int(math.Round(100 * float64(filled) / float64(len(profileFields))))
+	status.Complete = len(status.RequiredMissing) == 0
+	return status
+}
+
+// validateUserData checks if the user profile and bio are sufficiently filled for
+// recommendations. Returns *ErrIncompleteProfile if not.
+func validateUserData(u models.User) error {
+	status := GetProfileStatus(u)
+	if status.Complete {
+		return nil
+	}
+	return &ErrIncompleteProfile{MissingFields: status.RequiredMissing, Completeness: status.Completeness}
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"m/backend/models"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// The questionnaire is a set of admin-managed multiple-choice questions. A user answers a
+// question with their own choice, the choices they accept from a partner and how much it
+// matters to them; compatibility.go turns the answers of two users into a match score.
+// Questions are deactivated rather than deleted and their choices are fixed once created,
+// so existing answers always stay valid.
+
+var (
+	ErrInvalidQuestion = errors.New("invalid question")
+	ErrInvalidAnswer   = errors.New("invalid answer")
+)
+
+const (
+	// MinQuestionChoices and MaxQuestionChoices bound the number of choices of a question.
+	MinQuestionChoices = 2
+	MaxQuestionChoices = 10
+	// DefaultImportance is used when an answer does not say how much it matters.
+	DefaultImportance = "somewhat"
+)
+
+// importanceWeights maps answer importances to their weight in compatibility scoring.
+var importanceWeights = map[string]float64{
+	"irrelevant": 0,
+	"little":     1,
+	"somewhat":   10,
+	"very":       50,
+	"mandatory":  250,
+}
+
+// QuestionWithAnswer is an active question with the current user's answer, if any.
+type QuestionWithAnswer struct {
+	models.Question
+	Answer *models.QuestionAnswer `json:"answer"`
+}
+
+// QuestionnaireService manages questions and answers.
+type QuestionnaireService struct {
+	DB *gorm.DB
+}
+
+// NewQuestionnaireService creates a QuestionnaireService.
+func NewQuestionnaireService(db *gorm.DB) *QuestionnaireService {
+	logrus.Info("QuestionnaireService initialized")
+	return &QuestionnaireService{DB: db}
+}
+
+// orderedChoices preloads question choices in display order.
+func orderedChoices(db *gorm.DB) *gorm.DB {
+	return db.Order("position, id")
+}
+
+// CreateQuestion validates and stores a question with its choices, numbering the
+// choices in the given order.
+func (qs *QuestionnaireService) CreateQuestion(q *models.Question) error {
+	q.Text = strings.TrimSpace(q.Text)
+	if q.Text == "" || len(q.Text) > 500 {
+		return fmt.Errorf("%w: text must be 1-500 characters", ErrInvalidQuestion)
+	}
+	if len(q.Choices) < MinQuestionChoices || len(q.Choices) > MaxQuestionChoices {
+		return fmt.Errorf("%w: a question needs %d-%d choices", ErrInvalidQuestion, MinQuestionChoices, MaxQuestionChoices)
+	}
+	seen := map[string]bool{}
+	for i := range q.Choices {
+		c := &q.Choices[i]
+		c.Text = strings.TrimSpace(c.Text)
+		if c.Text == "" || len(c.Text) > 200 {
+			return fmt.Errorf("%w: choice text must be 1-200 characters", ErrInvalidQuestion)
+		}
+		if seen[strings.ToLower(c.Text)] {
+			return fmt.Errorf("%w: duplicate choice %q", ErrInvalidQuestion, c.Text)
+		}
+		seen[strings.ToLower(c.Text)] = true
+		c.ID = 0
+		c.Position = i
+	}
+	q.ID = 0
+	return qs.DB.Create(q).Error
+}
+
+// ListQuestions returns the questions with their choices, newest first. Inactive
+// questions are included only when all is true.
+func (qs *QuestionnaireService) ListQuestions(all bool) ([]models.Question, error) {
+	var list []models.Question
+	query := qs.DB.Preload("Choices", orderedChoices).Order("id DESC")
+	if !all {
+		query = query.Where("active = ?", true)
+	}
+	err := query.Find(&list).Error
+	return list, err
+}
+
+// UpdateQuestion changes the text and/or active flag of a question. Nil leaves a value as is.
+func (qs *QuestionnaireService) UpdateQuestion(id uint, text *string, active *bool) (*models.Question, error) {
+	var q models.Question
+	if err := qs.DB.First(&q, id).Error; err != nil {
+		return nil, err
+	}
+	updates := map[string]interface{}{}
+	if text != nil {
+		t := strings.TrimSpace(*text)
+		if t == "" || len(t) > 500 {
+			return nil, fmt.Errorf("%w: text must be 1-500 characters", ErrInvalidQuestion)
+		}
+		updates["text"] = t
+	}
+	if active != nil {
+		updates["active"] = *active
+	}
+	if len(updates) > 0 {
+		if err := qs.DB.Model(&q).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+	if err := qs.DB.Preload("Choices", orderedChoices).First(&q, id).Error; err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+// QuestionsFor returns the active questions with the user's answers.
+func (qs *QuestionnaireService) QuestionsFor(userID uuid.UUID) ([]QuestionWithAnswer, error) {
+	questions, err := qs.ListQuestions(false)
+	if err != nil {
+		return nil, err
+	}
+	var answers []models.QuestionAnswer
+	if err := qs.DB.Where("user_id = ?", userID).Find(&answers).Error; err != nil {
+		return nil, err
+	}
+	byQuestion := make(map[uint]*models.QuestionAnswer, len(answers))
+	for i := range answers {
+		byQuestion[answers[i].QuestionID] = &answers[i]
+	}
+	out := make([]QuestionWithAnswer, len(questions))
+	for i, q := range questions {
+		out[i] = QuestionWithAnswer{Question: q, Answer: byQuestion[q.ID]}
+	}
+	return out, nil
+}
+
+// Answer stores or replaces the user's answer to an active question. acceptable lists
+// the choices accepted from a partner (empty accepts any); importance defaults to
+// DefaultImportance. Returns gorm.ErrRecordNotFound for unknown or inactive questions.
+func (qs *QuestionnaireService) Answer(
+	userID uuid.UUID, questionID, choiceID uint, acceptable []uint, importance string,
+) (*models.QuestionAnswer, error) {
+	var q models.Question
+	if err := qs.DB.Preload("Choices").Where("active = ?", true).First(&q, questionID).Error; err != nil {
+		return nil, err
+	}
+	choices := make(map[uint]bool, len(q.Choices))
+	for _, c := range q.Choices {
+		choices[c.ID] = true
+	}
+	if !choices[choiceID] {
+		return nil, fmt.Errorf("%w: choice %d does not belong to question %d", ErrInvalidAnswer, choiceID, questionID)
+	}
+	accept := models.IDList{}
+	for _, id := range acceptable {
+		if !choices[id] {
+			return nil, fmt.Errorf("%w: acceptable choice %d does not belong to question %d", ErrInvalidAnswer, id, questionID)
+		}
+		if !accept.Contains(id) {
+			accept = append(accept, id)
+		}
+	}
+	importance = strings.ToLower(strings.TrimSpace(importance))
+	if importance == "" {
+		importance = DefaultImportance
+	}
+	if _, ok := importanceWeights[importance]; !ok {
+		return nil, fmt.Errorf("%w: importance must be one of irrelevant, little, somewhat, very, mandatory", ErrInvalidAnswer)
+	}
+
+	var a models.QuestionAnswer
+	err := qs.DB.Where("user_id = ? AND question_id = ?", userID, questionID).First(&a).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	a.UserID = userID
+	a.QuestionID = questionID
+	a.ChoiceID = choiceID
+	a.Acceptable = accept
+	a.Importance = importance
+	if err := qs.DB.Save(&a).Error; err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// DeleteAnswer removes the user's answer to a question.
+// Returns gorm.ErrRecordNotFound if there is none.
+func (qs *QuestionnaireService) DeleteAnswer(userID uuid.UUID, questionID uint) error {
+	res := qs.DB.Where("user_id = ? AND question_id = ?", userID, questionID).Delete(&models.QuestionAnswer{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
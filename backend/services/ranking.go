@@ -0,0 +1,132 @@
+package services
+
+import (
+	"math"
+	"reflect"
+	"sort"
+
+	"m/backend/models"
+
+	"github.com/google/uuid"
+)
+
+// Ranking strategies decide the order of scored candidates:
+//
+//   - "distance" (default): nearest first, score breaks ties.
+//   - "score": best match first, distance breaks ties.
+//   - "blend": by blendScoreWeight*score + (1-blendScoreWeight)*decay(distance),
+//     where decay(d) = exp(-d/decayKm) is 1 next door and ~0.37 at decayKm.
+//
+// The strategy of a request is, in order of precedence: the explicit per-request
+// Ranking (query parameter), the user's Preference.RankingStrategy, the service
+// SortOrder (server default or experiment variant).
+
+const (
+	RankingDistance = "distance"
+	RankingScore    = "score"
+	RankingBlend    = "blend"
+)
+
+// RankingBlendParams configures the "blend" strategy.
+type RankingBlendParams struct {
+	ScoreWeight float64 `json:"scoreWeight"`
+	DecayKm     float64 `json:"decayKm"`
+}
+
+var rankingBlend = RankingBlendParams{ScoreWeight: 0.7, DecayKm: 10}
+
+// SetRankingBlend sets the blend parameters. The score weight is clamped to [0, 1];
+// a non-positive decay distance keeps the current one.
+func SetRankingBlend(scoreWeight, decayKm float64) {
+	rankingBlend.ScoreWeight = math.Max(0, math.Min(1, scoreWeight))
+	if decayKm > 0 {
+		rankingBlend.DecayKm = decayKm
+	}
+}
+
+// GetRankingBlend returns the current blend parameters.
+func GetRankingBlend() RankingBlendParams {
+	return rankingBlend
+}
+
+// IsRankingStrategy reports whether s names a known ranking strategy.
+func IsRankingStrategy(s string) bool {
+	return s == RankingDistance || s == RankingScore || s == RankingBlend
+}
+
+// distanceDecay maps a distance in km to (0, 1], decreasing with distance.
+func distanceDecay(km float64) float64 {
+	return math.Exp(-km / rankingBlend.DecayKm)
+}
+
+// blendedRank is the sort key of the "blend" strategy.
+func blendedRank(score, km float64) float64 {
+	return rankingBlend.ScoreWeight*score + (1-rankingBlend.ScoreWeight)*distanceDecay(km)
+}
+
+// WithRanking returns a copy of the service that ranks with the given strategy,
+// overriding the user's preference. An empty strategy returns rs unchanged.
+func (rs *RecommendationService) WithRanking(strategy string) *RecommendationService {
+	if strategy == "" {
+		return rs
+	}
+	c := *rs
+	c.Ranking = strategy
+	return &c
+}
+
+// rankingFor resolves the strategy for a user with the given preferences.
+func (rs *RecommendationService) rankingFor(pref models.Preference) string {
+	switch {
+	case IsRankingStrategy(rs.Ranking):
+		return rs.Ranking
+	case IsRankingStrategy(pref.RankingStrategy):
+		return pref.RankingStrategy
+	case IsRankingStrategy(rs.SortOrder):
+		return rs.SortOrder
+	}
+	return RankingDistance
+}
+
+// orderCandidates is the ordering pipeline shared by every ranking path: it sorts the
+// cands slice in place by the strategy, re-ranks the top for variety (see diversity.go)
+// and moves users over their exposure caps last (see exposure.go). candidate(i) and
+// id(i) read the i-th element of cands. Returns the serving order (indices into the
+// sorted cands) and the demoted users.
+func (rs *RecommendationService) orderCandidates(
+	viewerID uuid.UUID,
+	strategy string,
+	cands interface{},
+	candidate func(i int) (models.Bio, float64, float64),
+	id func(i int) uuid.UUID,
+) ([]int, map[uuid.UUID]bool, error) {
+	sort.Slice(cands, func(i, j int) bool {
+		_, di, si := candidate(i)
+		_, dj, sj := candidate(j)
+		return rankLess(strategy, di, si, dj, sj)
+	})
+	order := rs.diversify(strategy, reflect.ValueOf(cands).Len(), candidate)
+	return rs.demoteOverExposed(viewerID, order, id)
+}
+
+// rankLess reports whether a candidate (distance di, score si) ranks before (dj, sj)
+// under the given strategy.
+func rankLess(strategy string, di, si, dj, sj float64) bool {
+	switch strategy {
+	case RankingScore:
+		if math.Abs(si-sj) > 1e-9 {
+			return si > sj
+		}
+		return di < dj
+	case RankingBlend:
+		bi, bj := blendedRank(si, di), blendedRank(sj, dj)
+		if math.Abs(bi-bj) > 1e-9 {
+			return bi > bj
+		}
+		return di < dj
+	}
+	if math.Abs(di-dj) > 1e-9 {
+		return di < dj
+	}
+	return si > sj
+}
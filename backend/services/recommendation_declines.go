@@ -0,0 +1,123 @@
+package services
+
+import (
+	"sort"
+	"time"
+
+	"m/backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Declines hide a recommended user from the decliner. A decline can be undone, and it
+// can expire: the per-user Preference.DeclineTTLDays wins over the global TTL, and a TTL
+// of 0 (the default) keeps declined users hidden forever. Rows declined before DeclinedAt
+// existed fall back to CreatedAt.
+
+// declineTTLDays is the global decline expiry in days (0 = never expire).
+var declineTTLDays = 0
+
+// activeDeclineCondition matches declines that have not expired yet.
+// Expects recommendations aliased as r, the decliner's preferences LEFT JOINed as pr,
+// and the global TTL bound twice.
+const activeDeclineCondition = `r.status = 'declined'
+	AND (
+	  COALESCE(pr.decline_ttl_days, ?) <= 0
+	  OR COALESCE(r.declined_at, r.created_at) > NOW() - make_interval(days => COALESCE(pr.decline_ttl_days, ?))
+	)`
+
+// SetDeclineTTLDays sets the global decline expiry. Negative values are treated as 0.
+func SetDeclineTTLDays(days int) {
+	if days < 0 {
+		days = 0
+	}
+	declineTTLDays = days
+}
+
+// DeclinedRecommendation is an active decline. ExpiresAt is nil if it never expires.
+type DeclinedRecommendation struct {
+	UserID     uuid.UUID  `json:"id"`
+	DeclinedAt time.Time  `json:"declinedAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+}
+
+// GetDeclined returns the user's active (not yet expired) declines, most recent first.
+func (rs *RecommendationService) GetDeclined(userID uuid.UUID) ([]DeclinedRecommendation, error) {
+	var rows []struct {
+		RecUserID  uuid.UUID
+		DeclinedAt time.Time
+		TTLDays    int
+	}
+	err := rs.DB.
+		Raw(`
+			SELECT DISTINCT ON (r.rec_user_id)
+			  r.rec_user_id,
+			  COALESCE(r.declined_at, r.created_at) AS declined_at,
+			  COALESCE(pr.decline_ttl_days, ?) AS ttl_days
+			FROM recommendations r
+			LEFT JOIN preferences pr ON pr.user_id = r.user_id
+			WHERE r.user_id = ? AND `+activeDeclineCondition+`
+			ORDER BY r.rec_user_id, declined_at DESC
+		`, declineTTLDays, userID, declineTTLDays, declineTTLDays).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]DeclinedRecommendation, len(rows))
+	for i, row := range rows {
+		out[i] = DeclinedRecommendation{UserID: row.RecUserID, DeclinedAt: row.DeclinedAt}
+		if row.TTLDays > 0 {
+			exp := row.DeclinedAt.AddDate(0, 0, row.TTLDays)
+			out[i].ExpiresAt = &exp
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].DeclinedAt.After(out[j].DeclinedAt)
+	})
+	return out, nil
+}
+
+// DeclinedUserIDs returns the IDs of users the given user has declined and
+// whose decline has not expired yet.
+func (rs *RecommendationService) DeclinedUserIDs(userID uuid.UUID) ([]uuid.UUID, error) {
+	list, err := rs.GetDeclined(userID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uuid.UUID, len(list))
+	for i, d := range list {
+		ids[i] = d.UserID
+	}
+	return ids, nil
+}
+
+// declinedSet is DeclinedUserIDs as a set for fast candidate filtering.
+func (rs *RecommendationService) declinedSet(userID uuid.UUID) (map[uuid.UUID]struct{}, error) {
+	ids, err := rs.DeclinedUserIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[uuid.UUID]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	return set, nil
+}
+
+// UndoDecline puts a declined recommendation back to pending so the user can be
+// recommended again. The row is kept for its score, serving history and impressions.
+// Returns gorm.ErrRecordNotFound if the user was not declined.
+func (rs *RecommendationService) UndoDecline(currentUserID, recUserID uuid.UUID) error {
+	res := rs.DB.Model(&models.Recommendation{}).
+		Where("user_id = ? AND rec_user_id = ? AND status = ?", currentUserID, recUserID, "declined").
+		Updates(map[string]interface{}{"status": "pending", "declined_at": nil})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
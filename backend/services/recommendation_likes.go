@@ -0,0 +1,152 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"m/backend/models"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Likes are a lightweight positive signal on a recommendation, stored as
+// Recommendation rows with status "liked". When both users like each other the
+// rows become "matched", an accepted Connection is created (or an existing
+// request is accepted) and a chat is opened, all in one transaction. Likes and declines
+// of a pair take a transaction-level advisory lock on the pair, so two users liking each
+// other at the same moment cannot both miss the other's like.
+
+var (
+	ErrLikeSelf       = errors.New("cannot like yourself")
+	ErrAlreadyMatched = errors.New("already matched with this user")
+)
+
+// lockPair serializes likes and declines between two users until tx ends.
+// The key does not depend on who acts, so both directions wait on the same lock.
+func lockPair(tx *gorm.DB, a, b uuid.UUID) error {
+	first, second := a.String(), b.String()
+	if second < first {
+		first, second = second, first
+	}
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "recommendation:"+first+":"+second).Error
+}
+
+// MatchResult is the outcome of a like. ChatID is set only when Matched is true.
+type MatchResult struct {
+	Matched bool `json:"matched"`
+	ChatID  uint `json:"chatId,omitempty"`
+}
+
+// ReceivedLike is a like from another user that the current user has not answered yet.
+type ReceivedLike struct {
+	UserID  uuid.UUID `json:"id"`
+	LikedAt time.Time `json:"likedAt"`
+}
+
+// LikeRecommendation records that currentUserID likes recUserID and detects a mutual match.
+// Liking a previously declined user replaces the decline.
+func (rs *RecommendationService) LikeRecommendation(currentUserID, recUserID uuid.UUID) (*MatchResult, error) {
+	if currentUserID == recUserID {
+		return nil, ErrLikeSelf
+	}
+	var target models.User
+	if err := rs.DB.Select("id").First(&target, "id = ?", recUserID).Error; err != nil {
+		return nil, err
+	}
+
+	result := &MatchResult{}
+	err := rs.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockPair(tx, currentUserID, recUserID); err != nil {
+			return err
+		}
+		now := time.Now()
+
+		var mine models.Recommendation
+		err := tx.Where("user_id = ? AND rec_user_id = ?", currentUserID, recUserID).First(&mine).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if mine.Status == "matched" {
+			result.Matched = true
+			return nil
+		}
+		mine.UserID = currentUserID
+		mine.RecUserID = recUserID
+		mine.Status = "liked"
+		mine.LikedAt = &now
+		mine.DeclinedAt = nil
+		if err := tx.Save(&mine).Error; err != nil {
+			return err
+		}
+
+		// Mutual match: the other user already liked the current user
+		var theirs models.Recommendation
+		err = tx.Where("user_id = ? AND rec_user_id = ? AND status = ?", recUserID, currentUserID, "liked").
+			First(&theirs).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Recommendation{}).
+			Where("id IN ?", []uint{mine.ID, theirs.ID}).
+			Update("status", "matched").Error; err != nil {
+			return err
+		}
+
+		var conn models.Connection
+		err = tx.Where("(user_id = ? AND connection_id = ?) OR (user_id = ? AND connection_id = ?)",
+			currentUserID, recUserID, recUserID, currentUserID).
+			First(&conn).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			conn = models.Connection{UserID: recUserID, ConnectionID: currentUserID}
+		} else if err != nil {
+			return err
+		}
+		conn.Status = "accepted"
+		if err := tx.Save(&conn).Error; err != nil {
+			return err
+		}
+
+		chat, err := NewChatService(tx).CreateChat(recUserID, currentUserID)
+		if err != nil {
+			return err
+		}
+		result.Matched = true
+		result.ChatID = chat.ID
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Matched {
+		logrus.Infof("LikeRecommendation: mutual match between %s and %s (chat %d)", currentUserID, recUserID, result.ChatID)
+	}
+	return result, nil
+}
+
+// GetReceivedLikes returns users who liked userID and have not been answered
+// (liked back or declined) yet, most recent first.
+func (rs *RecommendationService) GetReceivedLikes(userID uuid.UUID) ([]ReceivedLike, error) {
+	var likes []ReceivedLike
+	err := rs.DB.
+		Raw(`
+			SELECT r.user_id, COALESCE(r.liked_at, r.created_at) AS liked_at
+			FROM recommendations r
+			WHERE r.rec_user_id = ? AND r.status = 'liked'
+			  AND NOT EXISTS (
+			    SELECT 1 FROM recommendations m
+			    WHERE m.user_id = r.rec_user_id AND m.rec_user_id = r.user_id
+			      AND m.status IN ('declined', 'liked', 'matched')
+			  )
+			ORDER BY liked_at DESC
+		`, userID).
+		Scan(&likes).Error
+	if err != nil {
+		return nil, err
+	}
+	return likes, nil
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Recommendation snapshots make paging stable: the first page request ranks the full
+// candidate list once and stores it in memory; following pages are served from the
+// stored list using an opaque cursor (snapshot ID + rank position), so new profiles or
+// score changes do not shift items between pages while the user scrolls.
+//
+// Snapshots live in the memory of the process that served the first page, so cursors
+// only work while requests of a user reach the same instance and do not survive a restart.
+
+var (
+	ErrInvalidCursor    = errors.New("invalid cursor")
+	ErrSnapshotNotFound = errors.New("recommendation snapshot expired or not found")
+)
+
+// DefaultSnapshotTTL defines how long a ranked list can be paged before it must be rebuilt.
+const DefaultSnapshotTTL = 30 * time.Minute
+
+// maxSnapshotsPerUser bounds the memory of one user; older snapshots are dropped first
+// and their cursors stop working.
+const maxSnapshotsPerUser = 5
+
+// RecommendationSnapshot is a ranked recommendation list frozen for paging.
+type RecommendationSnapshot struct {
+	ID        string
+	UserID    uuid.UUID
+	Mode      string
+	Items     []RecommendationWithDistance
+	CreatedAt time.Time
+
+	members map[uuid.UUID]struct{} // IDs in Items, for access checks
+}
+
+// RecommendationPage is one page of a snapshot. NextCursor is empty on the last page.
+// Total is the number of recommendations available in the snapshot; Offset is the
+// rank position of the first item.
+type RecommendationPage struct {
+	SnapshotID string                       `json:"snapshotId"`
+	Items      []RecommendationWithDistance `json:"items"`
+	NextCursor string                       `json:"nextCursor,omitempty"`
+	Total      int                          `json:"total"`
+	Offset     int                          `json:"-"`
+}
+
+// snapshotStore is an in-memory store of recommendation snapshots keyed by user, with
+// expiration. Expired snapshots are dropped whenever the user's list is touched and by
+// a background sweep every TTL. Uses a mutex for safe concurrent access.
+type snapshotStore struct {
+	byUser    map[uuid.UUID][]*RecommendationSnapshot
+	ttl       time.Duration
+	mu        sync.Mutex
+	sweepOnce sync.Once
+}
+
+var snapshots = &snapshotStore{
+	byUser: make(map[uuid.UUID][]*RecommendationSnapshot),
+	ttl:    DefaultSnapshotTTL,
+}
+
+// live returns the user's non-expired snapshots, dropping the expired ones.
+// Callers must hold the mutex.
+func (st *snapshotStore) live(userID uuid.UUID) []*RecommendationSnapshot {
+	list := st.byUser[userID]
+	kept := list[:0]
+	for _, s := range list {
+		if time.Since(s.CreatedAt) <= st.ttl {
+			kept = append(kept, s)
+		}
+	}
+	for i := len(kept); i < len(list); i++ {
+		list[i] = nil
+	}
+	if len(kept) == 0 {
+		delete(st.byUser, userID)
+		return nil
+	}
+	st.byUser[userID] = kept
+	return kept
+}
+
+// sweep drops the expired snapshots of every user, then repeats every TTL.
+// Catches users who never come back for their next page.
+func (st *snapshotStore) sweep() {
+	for {
+		st.mu.Lock()
+		ttl := st.ttl
+		for userID := range st.byUser {
+			st.live(userID)
+		}
+		st.mu.Unlock()
+		time.Sleep(ttl)
+	}
+}
+
+// SetSnapshotTTL changes how long recommendation snapshots are kept.
+func SetSnapshotTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	snapshots.mu.Lock()
+	defer snapshots.mu.Unlock()
+	snapshots.ttl = ttl
+}
+
+type cursorPayload struct {
+	SnapshotID string `json:"s"`
+	Position   int    `json:"p"`
+}
+
+// encodeCursor builds an opaque cursor pointing at a rank position inside a snapshot.
+func encodeCursor(snapshotID string, position int) string {
+	data, _ := json.Marshal(cursorPayload{SnapshotID: snapshotID, Position: position})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a cursor created by encodeCursor.
+func decodeCursor(cursor string) (cursorPayload, error) {
+	var p cursorPayload
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return p, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &p); err != nil || p.SnapshotID == "" || p.Position < 0 {
+		return p, ErrInvalidCursor
+	}
+	return p, nil
+}
+
+// SaveSnapshot stores a ranked list for the user and returns the new snapshot.
+func (rs *RecommendationService) SaveSnapshot(
+	userID uuid.UUID, mode string, items []RecommendationWithDistance,
+) *RecommendationSnapshot {
+	snap := &RecommendationSnapshot{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Mode:      mode,
+		Items:     items,
+		CreatedAt: time.Now(),
+		members:   make(map[uuid.UUID]struct{}, len(items)),
+	}
+	for _, item := range items {
+		snap.members[item.UserID] = struct{}{}
+	}
+	snapshots.sweepOnce.Do(func() { go snapshots.sweep() })
+	snapshots.mu.Lock()
+	defer snapshots.mu.Unlock()
+	list := append(snapshots.live(userID), snap)
+	if n := len(list) - maxSnapshotsPerUser; n > 0 {
+		list = append([]*RecommendationSnapshot(nil), list[n:]...)
+	}
+	snapshots.byUser[userID] = list
+	return snap
+}
+
+// getSnapshot returns a non-expired snapshot owned by the user.
+func getSnapshot(userID uuid.UUID, snapshotID string) (*RecommendationSnapshot, error) {
+	snapshots.mu.Lock()
+	defer snapshots.mu.Unlock()
+	for _, snap := range snapshots.live(userID) {
+		if snap.ID == snapshotID {
+			return snap, nil
+		}
+	}
+	return nil, ErrSnapshotNotFound
+}
+
+// InSnapshot reports whether candidateID appears in any live snapshot of the user.
+// Used for access checks: users may open profiles from any page they were served.
+func InSnapshot(userID, candidateID uuid.UUID) bool {
+	snapshots.mu.Lock()
+	defer snapshots.mu.Unlock()
+	for _, snap := range snapshots.live(userID) {
+		if _, ok := snap.members[candidateID]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// PageFromSnapshot returns pageSize items of the snapshot starting at position.
+func PageFromSnapshot(snap *RecommendationSnapshot, position, pageSize int) *RecommendationPage {
+	total := len(snap.Items)
+	if position > total {
+		position = total
+	}
+	end := position + pageSize
+	if end > total {
+		end = total
+	}
+	page := &RecommendationPage{
+		SnapshotID: snap.ID,
+		Items:      snap.Items[position:end],
+		Total:      total,
+		Offset:     position,
+	}
+	if end < total {
+		page.NextCursor = encodeCursor(snap.ID, end)
+	}
+	return page
+}
+
+// GetPageByCursor returns the page a cursor points at.
+// Returns ErrInvalidCursor for malformed cursors and ErrSnapshotNotFound
+// if the snapshot expired or belongs to another user.
+func (rs *RecommendationService) GetPageByCursor(
+	userID uuid.UUID, cursor string, pageSize int,
+) (*RecommendationPage, error) {
+	p, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := getSnapshot(userID, p.SnapshotID)
+	if err != nil {
+		return nil, err
+	}
+	return PageFromSnapshot(snap, p.Position, pageSize), nil
+}
@@ -4,9 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"m/backend/models"
-	"math"
-	"sort"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/google/uuid"
@@ -22,22 +21,36 @@ This service provides user-to-user recommendations based on profile similarity,
 Key Principles:
 - Two modes: "affinity" (profile similarity, weighted fields) and "desire" (matching by 'LookingFor').
 - Geospatial filtering: Only users within a preferred radius (using PostgreSQL earthdistance/cube).
-- Score calculation: Weighted overlap of interests, hobbies, music, food, travel (weights can be doubled by user priorities).
-- Filtering: Excludes declined users and those with incomplete profiles.
-- Sorting: Recommendations are sorted by distance (ascending), then by score (descending).
+- Score calculation: Weighted overlap of interests, hobbies, music, food, travel (weights are scaled by the user's field weights, see field_weights.go).
+- Filtering: Excludes declined users (until the decline expires, see recommendation_declines.go) and those with incomplete profiles.
+- Sorting: by distance (ascending) then score by default; score-first or a distance-decay
+  blend can be chosen per request, per user or server-wide (see ranking.go).
+- Diversity: the top of the sorted list is re-ranked with maximal marginal relevance so
+  near-identical profiles do not crowd it (see diversity.go).
+- Exposure caps: users served to too many people today or with too many pending requests
+  are moved to the end of the list (see exposure.go).
+- Dealbreakers: candidates with a ruled-out token are excluded in SQL; in mutual mode the
+  candidate's radius and dealbreakers must accept the user too (see dealbreakers.go).
+- Presence: optionally only online users, and scores decay with days since the last
+  activity so inactive accounts sink (see activity.go).
 - Extensible: Field weights and extractors are configurable for future algorithm tuning.
+- Hybrid: with a blend weight > 0 the content score is mixed with a collaborative score learned
+  from connections, likes, declines and chats (see collaborative.go).
+- Explainable: scoreCandidate records shared tokens, weights and distance band per candidate.
 
 Typical Flow:
 1. Load current user with profile, bio, and preferences.
 2. Find nearby users within radius, excluding declined.
-3. For each candidate, calculate score based on field overlap and priorities.
+3. For each candidate, calculate score based on field overlap and field weights.
 4. Filter out candidates with zero score.
-5. Sort by distance, then score. Limit output.
+5. Sort by distance, then score, re-rank the top for diversity, demote over-exposed users. Limit output.
 
 See also:
 - GetRecommendationsForUser: Main entry for recommendations.
 - GetRecommendationsWithFiltersWithDistance: Advanced search with custom filters.
-- validateUserData: Ensures profile completeness.
+- ExplainRecommendation: Score breakdown for a single candidate.
+- validateUserData: Ensures profile completeness (see profile_completeness.go).
+- GetColdStartRecommendations: Degraded mode for incomplete profiles (see cold_start.go).
 */
 
 // Used internally for sorting and filtering nearby users.
@@ -57,10 +70,52 @@ type FieldConfig struct {
 
 // RecommendationWithDistance contains a recommended user with their distance
 // and match score. Used for API responses and client-side display.
+// Explanation describes how the score was obtained (see RecommendationExplanation).
+// Online is set when presence was checked (see activity.go).
 type RecommendationWithDistance struct {
-	UserID   uuid.UUID `json:"id"`
-	Distance float64   `json:"distance"`
-	Score    float64   `json:"score"`
+	UserID      uuid.UUID                  `json:"id"`
+	Distance    float64                    `json:"distance"`
+	Score       float64                    `json:"score"`
+	Online      bool                       `json:"online"`
+	Explanation *RecommendationExplanation `json:"explanation,omitempty"`
+}
+
+// FieldMatch describes how a single Bio field contributed to a candidate's score.
+// Weight is the effective weight: BaseWeight times the user's Multiplier for the field.
+// Prioritized is true when the multiplier is above 1.
+type FieldMatch struct {
+	Field        string   `json:"field"`
+	SharedTokens []string `json:"sharedTokens"`
+	BaseWeight   float64  `json:"baseWeight"`
+	Multiplier   float64  `json:"multiplier"`
+	Weight       float64  `json:"weight"`
+	Prioritized  bool     `json:"prioritized"`
+	Contribution float64  `json:"contribution"`
+}
+
+// RecommendationExplanation answers "why was this person recommended?":
+// the mode used, the distance band, and the per-field matches with weights.
+// Capped is true when the raw score exceeded 1 and was clamped.
+// When collaborative filtering is active, ContentScore, CollaborativeScore and
+// BlendWeight show how the final score was blended.
+// ExposureCapped is true when the candidate was moved down for being over an exposure cap.
+// Popularity is set in cold-start mode (see cold_start.go). ActivityFactor is the
+// recently-active multiplier when it lowered the score (see activity.go).
+// SharedCommunities is the number of communities both users are in (see communities.go).
+type RecommendationExplanation struct {
+	Mode               string       `json:"mode"`
+	DistanceBand       string       `json:"distanceBand"`
+	Fields             []FieldMatch `json:"fields"`
+	Capped             bool         `json:"capped"`
+	ContentScore       float64      `json:"contentScore,omitempty"`
+	CollaborativeScore *float64     `json:"collaborativeScore,omitempty"`
+	BlendWeight        float64      `json:"blendWeight,omitempty"`
+	ExposureCapped     bool         `json:"exposureCapped,omitempty"`
+	Popularity         float64      `json:"popularity,omitempty"`
+	ActivityFactor     float64      `json:"activityFactor,omitempty"`
+	Compatibility      *float64     `json:"compatibility,omitempty"`
+	CommonQuestions    int          `json:"commonQuestions,omitempty"`
+	SharedCommunities  int          `json:"sharedCommunities,omitempty"`
 }
 
 // candidate is an internal struct for scoring and sorting candidates.
@@ -75,17 +130,58 @@ type candidate struct {
 // based on profile data, preferences, and geolocation.
 // Supports different recommendation modes (affinity/desire) and customizable
 // field weights for scoring.
+// CandidateLimit caps how many nearby users are fetched and scored per request.
+// SortOrder is the default ranking strategy ("distance", "score" or "blend", see ranking.go);
+// experiments override it per request through WithVariant. Ranking is a per-request
+// strategy set through WithRanking that wins over the user's preference.
+// Diversity is a per-request MMR lambda set through WithDiversity (see diversity.go).
+// Presence is optional; OnlineOnly and ActivityBoost are per-request presence options
+// set through WithOnlineOnly and WithActivityBoost (see activity.go). Mutual overrides the
+// user's mutual matching preference through WithMutual (see dealbreakers.go).
 type RecommendationService struct {
-	DB           *gorm.DB
-	FieldConfigs []FieldConfig
-	Mode         string
+	DB             *gorm.DB
+	FieldConfigs   []FieldConfig
+	Mode           string
+	CandidateLimit int
+	SortOrder      string
+	Ranking        string
+	Diversity      *float64
+	Presence       *PresenceService
+	OnlineOnly     bool
+	ActivityBoost  *bool
+	Mutual         *bool
+}
+
+// DefaultCandidateLimit is the number of nearby users scored when CandidateLimit is not set.
+const DefaultCandidateLimit = 100
+
+// RecommendationFilters holds the custom search used instead of the saved profile:
+// search location, tokens per Bio field with field weights, and 'LookingFor' text.
+// The priority flags are the legacy form of a field weight of 2.
+// OnlineOnly keeps only users who are online.
+type RecommendationFilters struct {
+	Latitude          float64            `json:"latitude"`
+	Longitude         float64            `json:"longitude"`
+	Interests         []string           `json:"interests"`
+	PriorityInterests bool               `json:"priorityInterests"`
+	Hobbies           []string           `json:"hobbies"`
+	PriorityHobbies   bool               `json:"priorityHobbies"`
+	Music             []string           `json:"music"`
+	PriorityMusic     bool               `json:"priorityMusic"`
+	Food              []string           `json:"food"`
+	PriorityFood      bool               `json:"priorityFood"`
+	Travel            []string           `json:"travel"`
+	PriorityTravel    bool               `json:"priorityTravel"`
+	LookingFor        string             `json:"lookingFor"`
+	FieldWeights      map[string]float64 `json:"fieldWeights,omitempty"`
+	OnlineOnly        bool               `json:"onlineOnly,omitempty"`
 }
 
 // NewRecommendationService creates a new RecommendationService with optional
 // custom field configurations. If no configs are provided, uses default weights
 // for interests, hobbies, music, food, and travel preferences.
 func NewRecommendationService(db *gorm.DB, fieldConfigs []FieldConfig) *RecommendationService {
-	rs := &RecommendationService{DB: db, Mode: "affinity"}
+	rs := &RecommendationService{DB: db, Mode: "affinity", CandidateLimit: DefaultCandidateLimit}
 	if len(fieldConfigs) == 0 {
 		fieldConfigs = []FieldConfig{
 			{Name: "Interests", Weight: 0.02, Extractor: func(b models.Bio) string { return b.Interests }},
@@ -120,7 +216,105 @@ func countCommon(a, b []string) int {
 	return cnt
 }
 
+// distanceBand groups a distance in km into a human-readable band.
+func distanceBand(km float64) string {
+	switch {
+	case km < 1:
+		return "<1 km"
+	case km < 5:
+		return "1-5 km"
+	case km < 10:
+		return "5-10 km"
+	case km < 25:
+		return "10-25 km"
+	case km < 50:
+		return "25-50 km"
+	case km < 100:
+		return "50-100 km"
+	default:
+		return "100+ km"
+	}
+}
+
+// scoreCandidate calculates the match score between the current user's bio and a candidate's bio.
+// In affinity mode every shared token of a configured field adds the field weight
+// (scaled by the user's field weight); in desire mode every matching 'LookingFor' token adds desireStep.
+// The score is capped at 1. The returned explanation lists what contributed to the score.
+func (rs *RecommendationService) scoreCandidate(
+	mode string,
+	me models.Bio,
+	pref models.Preference,
+	other models.Bio,
+	distance float64,
+	desireStep float64,
+) (float64, *RecommendationExplanation) {
+	expl := &RecommendationExplanation{
+		Mode:         mode,
+		DistanceBand: distanceBand(distance),
+		Fields:       []FieldMatch{},
+	}
+	var score float64
+
+	if mode == "affinity" {
+		// Affinity mode: score by field similarity and user preferences
+		for _, fc := range rs.FieldConfigs {
+			mult := pref.FieldWeights.Weight(fc.Name)
+			w := fc.Weight * mult
+
+			// Tokenize and compare fields for overlap
+			setA := make(map[string]struct{})
+			for _, t := range splitTokens(fc.Extractor(me)) {
+				setA[t] = struct{}{}
+			}
+			shared := []string{}
+			for _, t := range splitTokens(fc.Extractor(other)) {
+				if _, ok := setA[t]; ok {
+					shared = append(shared, t)
+				}
+			}
+			contribution := float64(len(shared)) * w
+			score += contribution
+			expl.Fields = append(expl.Fields, FieldMatch{
+				Field:        fc.Name,
+				SharedTokens: shared,
+				BaseWeight:   fc.Weight,
+				Multiplier:   mult,
+				Weight:       w,
+				Prioritized:  mult > 1,
+				Contribution: contribution,
+			})
+		}
+	} else {
+		// Desire mode: match by 'LookingFor' field
+		shared := []string{}
+		for _, tok := range splitTokens(me.LookingFor) {
+			if anyTokenMatch(tok, other.LookingFor) {
+				shared = append(shared, tok)
+				score += desireStep
+			}
+		}
+		expl.Fields = append(expl.Fields, FieldMatch{
+			Field:        "LookingFor",
+			SharedTokens: shared,
+			BaseWeight:   desireStep,
+			Multiplier:   1,
+			Weight:       desireStep,
+			Contribution: float64(len(shared)) * desireStep,
+		})
+	}
+
+	// Cap score at 1
+	if score > 1 {
+		score = 1
+		expl.Capped = true
+	}
+	return score, expl
+}
+
 // GetNearbyUsers returns a list of users within maxRadius km from the given coordinates, excluding the specified user.
+// Candidates hitting the user's dealbreakers or outside their age, gender and language
+// filters are left out; in mutual mode so are candidates whose own radius, dealbreakers
+// or filters exclude the user (see dealbreakers.go and demographics.go).
 func (rs *RecommendationService) GetNearbyUsers(
 	lat, lon, maxRadius float64,
 	limit int,
@@ -132,32 +326,52 @@ func (rs *RecommendationService) GetNearbyUsers(
 	fmt.Printf("[DEBUG] Excluding user ID: %s\n", excludeID)
 	var list []Nearby
 	// Use PostgreSQL earthdistance/cube extensions for efficient geospatial search
-	// Exclude users who have been declined in recommendations
+	// Exclude users who have been declined (until the decline expires) or already liked
 	err := rs.DB.
 		Raw(`
 			SELECT
 			p.user_id   AS id,
 			earth_distance(p.earth_loc, ll_to_earth(?, ?)) / 1000.0 AS distance
 		  FROM profiles p
+		  LEFT JOIN bios cb ON cb.user_id = p.user_id
+		  LEFT JOIN preferences cp ON cp.user_id = p.user_id
+		  LEFT JOIN bios mb ON mb.user_id = ?
+		  LEFT JOIN preferences mp ON mp.user_id = ?
+		  LEFT JOIN profiles mf ON mf.user_id = ?
 		  WHERE 
 			earth_box(ll_to_earth(?, ?), ?) @> p.earth_loc
 			AND earth_distance(p.earth_loc, ll_to_earth(?, ?)) <= ?
 			AND p.user_id != ?
+			AND `+nearbyDealbreakerCondition+`
+			AND `+nearbyDemographicCondition+`
+			AND `+nearbyMutualCondition+`
 			AND NOT EXISTS (
 			  SELECT 1
 			  FROM recommendations r
+			  LEFT JOIN preferences pr ON pr.user_id = r.user_id
 			  WHERE
 				r.user_id       = ?
 				AND r.rec_user_id = p.user_id
-				AND r.status     = 'declined'
+				AND `+activeDeclineCondition+`
+			)
+			AND NOT EXISTS (
+			  SELECT 1
+			  FROM recommendations l
+			  WHERE
+				l.user_id       = ?
+				AND l.rec_user_id = p.user_id
+				AND l.status IN ('liked', 'matched')
 			)
 		  ORDER BY distance ASC
 		  LIMIT ?
         `,
 			lat, lon,
+			excludeID, excludeID, excludeID,
 			lat, lon, maxMeters,
 			lat, lon, maxMeters,
-			excludeID, excludeID, limit,
+			excludeID,
+			rs.Mutual, lat, lon,
+			excludeID, declineTTLDays, declineTTLDays, excludeID, limit,
 		).
 		Scan(&list).Error
 	if err != nil {
@@ -177,10 +391,9 @@ func (rs *RecommendationService) GetRecommendationsForUser(
 	currentUserID uuid.UUID,
 	mode string,
 ) ([]uuid.UUID, error) {
+	// Mode is resolved per request; the shared service instance is never mutated
 	if mode != "desire" {
-		rs.Mode = "affinity"
-	} else {
-		rs.Mode = "desire"
+		mode = "affinity"
 	}
 
 	var me models.User
@@ -190,7 +403,16 @@ func (rs *RecommendationService) GetRecommendationsForUser(
 		return nil, err
 	}
 	if err := validateUserData(me); err != nil {
-		return nil, err
+		// Incomplete profiles get the cold-start list instead
+		recs, err := rs.GetColdStartRecommendations(currentUserID, mode, 10)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]uuid.UUID, len(recs))
+		for i, rec := range recs {
+			out[i] = rec.UserID
+		}
+		return out, nil
 	}
 
 	// Find nearby users within the user's preferred radius
@@ -198,7 +420,7 @@ func (rs *RecommendationService) GetRecommendationsForUser(
 		me.Profile.Latitude,
 		me.Profile.Longitude,
 		me.Preference.MaxRadius,
-		50,
+		rs.CandidateLimit,
 		currentUserID,
 	)
 	if err != nil {
@@ -224,84 +446,58 @@ func (rs *RecommendationService) GetRecommendationsForUser(
 		return nil, err
 	}
 
+	declined, err := rs.declinedSet(currentUserID)
+	if err != nil {
+		return nil, err
+	}
+	cf, err := rs.collaborativeScores(currentUserID, ids)
+	if err != nil {
+		return nil, err
+	}
+	compat, err := rs.compatibilityScores(currentUserID, ids)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := rs.sharedCommunities(currentUserID, ids)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	activity, err := rs.activityOf(ids, now)
+	if err != nil {
+		return nil, err
+	}
+
 	var cands []candidate
 	for _, u := range users {
 		// Skip users who have been declined
-		var rec models.Recommendation
-		if err := rs.DB.
-			Where("user_id = ? AND rec_user_id = ? AND status = ?", currentUserID, u.ID, "declined").
-			First(&rec).Error; err == nil {
+		if _, ok := declined[u.ID]; ok {
 			continue
 		}
 
 		d := distMap[u.ID]
-		var score float64
-
-		if rs.Mode == "affinity" {
-			// Affinity mode: score by field similarity and user preferences
-			for _, fc := range rs.FieldConfigs {
-				w := fc.Weight
-				switch fc.Name {
-				case "Interests":
-					if me.Preference.PriorityInterests {
-						w *= 2
-					}
-				case "Hobbies":
-					if me.Preference.PriorityHobbies {
-						w *= 2
-					}
-				case "Music":
-					if me.Preference.PriorityMusic {
-						w *= 2
-					}
-				case "Food":
-					if me.Preference.PriorityFood {
-						w *= 2
-					}
-				case "Travel":
-					if me.Preference.PriorityTravel {
-						w *= 2
-					}
-				}
-
-				// Tokenize and compare fields for overlap
-				setA := make(map[string]struct{})
-				for _, t := range splitTokens(fc.Extractor(me.Bio)) {
-					setA[t] = struct{}{}
-				}
-				common := 0
-				for _, t := range splitTokens(fc.Extractor(u.Bio)) {
-					if _, ok := setA[t]; ok {
-						common++
-					}
-				}
-				score += float64(common) * w
-			}
-		} else {
-			// Desire mode: match by 'LookingFor' field
-			for _, tok := range splitTokens(me.Bio.LookingFor) {
-				if anyTokenMatch(tok, u.Bio.LookingFor) {
-					score += 0.005
-				}
-			}
+		score, _ := rs.scoreCandidate(mode, me.Bio, me.Preference, u.Bio, d, 0.005)
+		score = blendScore(score, cf, u.ID, nil)
+		score = blendCompatibility(score, compat, u.ID, nil)
+		score = boostCommunities(score, shared, u.ID, nil)
+		score, ok := rs.applyActivity(score, activity[u.ID], now, nil)
+		if !ok {
+			continue
 		}
 
-		// Cap score at 1
-		if score > 1 {
-			score = 1
-		}
 		if score > 0 {
 			cands = append(cands, candidate{User: u, Score: score, Distance: d})
 		}
 	}
 
-	// Sort by distance (asc), then by score (desc)
-	sort.Slice(cands, func(i, j int) bool {
-		if math.Abs(cands[i].Distance-cands[j].Distance) > 1e-9 {
-			return cands[i].Distance < cands[j].Distance
-		}
-		return cands[i].Score > cands[j].Score
-	})
+	// Sort by the resolved ranking strategy (distance first by default), diversify and demote over-exposed users
+	ranking := rs.rankingFor(me.Preference)
+	order, _, err := rs.orderCandidates(currentUserID, ranking, cands,
+		func(i int) (models.Bio, float64, float64) { return cands[i].User.Bio, cands[i].Distance, cands[i].Score },
+		func(i int) uuid.UUID { return cands[i].User.ID })
+	if err != nil {
+		return nil, err
+	}
 
 	limit := 10
 	if len(cands) < limit {
@@ -309,7 +505,7 @@ func (rs *RecommendationService) GetRecommendationsForUser(
 	}
 	out := make([]uuid.UUID, limit)
 	for i := 0; i < limit; i++ {
-		out[i] = cands[i].User.ID
+		out[i] = cands[order[i]].User.ID
 	}
 	return out, nil
 }
@@ -319,10 +515,18 @@ func (rs *RecommendationService) GetRecommendationsForUser(
 func (rs *RecommendationService) GetRecommendationsWithDistance(
 	currentUserID uuid.UUID, mode string,
 ) ([]RecommendationWithDistance, error) {
+	return rs.GetRankedRecommendations(currentUserID, mode, 50)
+}
+
+// GetRankedRecommendations returns up to limit ranked recommendations from the saved profile.
+// A limit <= 0 returns every scored candidate (used to build paging snapshots).
+// Returns *ErrIncompleteProfile if the profile is incomplete (see GetColdStartRecommendations).
+func (rs *RecommendationService) GetRankedRecommendations(
+	currentUserID uuid.UUID, mode string, limit int,
+) ([]RecommendationWithDistance, error) {
+	// Mode is resolved per request; the shared service instance is never mutated
 	if mode != "desire" {
-		rs.Mode = "affinity"
-	} else {
-		rs.Mode = "desire"
+		mode = "affinity"
 	}
 
 	var me models.User
@@ -340,7 +544,7 @@ func (rs *RecommendationService) GetRecommendationsWithDistance(
 		me.Profile.Latitude,
 		me.Profile.Longitude,
 		me.Preference.MaxRadius,
-		100,
+		rs.CandidateLimit,
 		currentUserID,
 	)
 	if err != nil {
@@ -370,94 +574,81 @@ func (rs *RecommendationService) GetRecommendationsWithDistance(
 	}
 
 	type outCand struct {
-		ID       uuid.UUID
-		Score    float64
-		Distance float64
+		ID          uuid.UUID
+		Score       float64
+		Distance    float64
+		Online      bool
+		Explanation *RecommendationExplanation
+		Bio         models.Bio
 	}
 	var cands []outCand
 
+	declined, err := rs.declinedSet(currentUserID)
+	if err != nil {
+		return nil, err
+	}
+	cf, err := rs.collaborativeScores(currentUserID, ids)
+	if err != nil {
+		return nil, err
+	}
+	compat, err := rs.compatibilityScores(currentUserID, ids)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := rs.sharedCommunities(currentUserID, ids)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	activity, err := rs.activityOf(ids, now)
+	if err != nil {
+		return nil, err
+	}
+
 	for _, u := range users {
-		var rec models.Recommendation
-		if err := rs.DB.
-			Where("user_id = ? AND rec_user_id = ? AND status = ?", currentUserID, u.ID, "declined").
-			First(&rec).Error; err == nil {
+		if _, ok := declined[u.ID]; ok {
 			continue
 		}
 
 		d := distMap[u.ID]
-		var score float64
-
-		if rs.Mode == "affinity" {
-			for _, fc := range rs.FieldConfigs {
-				w := fc.Weight
-				switch fc.Name {
-				case "Interests":
-					if me.Preference.PriorityInterests {
-						w *= 2
-					}
-				case "Hobbies":
-					if me.Preference.PriorityHobbies {
-						w *= 2
-					}
-				case "Music":
-					if me.Preference.PriorityMusic {
-						w *= 2
-					}
-				case "Food":
-					if me.Preference.PriorityFood {
-						w *= 2
-					}
-				case "Travel":
-					if me.Preference.PriorityTravel {
-						w *= 2
-					}
-				}
-
-				setA := make(map[string]struct{})
-				for _, t := range splitTokens(fc.Extractor(me.Bio)) {
-					setA[t] = struct{}{}
-				}
-				common := 0
-				for _, t := range splitTokens(fc.Extractor(u.Bio)) {
-					if _, ok := setA[t]; ok {
-						common++
-					}
-				}
-				score += float64(common) * w
-			}
-		} else {
-			for _, tok := range splitTokens(me.Bio.LookingFor) {
-				if anyTokenMatch(tok, u.Bio.LookingFor) {
-					score += 0.05
-				}
-			}
+		score, expl := rs.scoreCandidate(mode, me.Bio, me.Preference, u.Bio, d, 0.05)
+		score = blendScore(score, cf, u.ID, expl)
+		score = blendCompatibility(score, compat, u.ID, expl)
+		score = boostCommunities(score, shared, u.ID, expl)
+		a := activity[u.ID]
+		score, ok := rs.applyActivity(score, a, now, expl)
+		if !ok {
+			continue
 		}
 
-		if score > 1 {
-			score = 1
-		}
 		if score > 0 {
-			cands = append(cands, outCand{ID: u.ID, Score: score, Distance: d})
+			cands = append(cands, outCand{ID: u.ID, Score: score, Distance: d, Online: a.Online, Explanation: expl, Bio: u.Bio})
 		}
 	}
 
-	sort.Slice(cands, func(i, j int) bool {
-		if math.Abs(cands[i].Distance-cands[j].Distance) > 1e-9 {
-			return cands[i].Distance < cands[j].Distance
-		}
-		return cands[i].Score > cands[j].Score
-	})
+	ranking := rs.rankingFor(me.Preference)
+	order, demoted, err := rs.orderCandidates(currentUserID, ranking, cands,
+		func(i int) (models.Bio, float64, float64) { return cands[i].Bio, cands[i].Distance, cands[i].Score },
+		func(i int) uuid.UUID { return cands[i].ID })
+	if err != nil {
+		return nil, err
+	}
 
-	limit := 50
-	if len(cands) < limit {
+	if limit <= 0 || len(cands) < limit {
 		limit = len(cands)
 	}
 	out := make([]RecommendationWithDistance, limit)
 	for i := 0; i < limit; i++ {
+		c := cands[order[i]]
+		if demoted[c.ID] && c.Explanation != nil {
+			c.Explanation.ExposureCapped = true
+		}
 		out[i] = RecommendationWithDistance{
-			UserID:   cands[i].ID,
-			Distance: cands[i].Distance,
-			Score:    cands[i].Score,
+			UserID:      c.ID,
+			Distance:    c.Distance,
+			Score:       c.Score,
+			Online:      c.Online,
+			Explanation: c.Explanation,
 		}
 	}
 	fmt.Printf("[DEBUG] Returning %d recommendations\n", len(out))
@@ -478,10 +669,37 @@ func (rs *RecommendationService) GetRecommendationsWithFiltersWithDistance(
 	travel []string, prioTravel bool,
 	lookingFor string,
 ) ([]RecommendationWithDistance, error) {
+	return rs.GetRecommendationsWithFilters(currentUserID, mode, RecommendationFilters{
+		Latitude:          lat,
+		Longitude:         lon,
+		Interests:         interests,
+		PriorityInterests: prioInterests,
+		Hobbies:           hobbies,
+		PriorityHobbies:   prioHobbies,
+		Music:             music,
+		PriorityMusic:     prioMusic,
+		Food:              food,
+		PriorityFood:      prioFood,
+		Travel:            travel,
+		PriorityTravel:    prioTravel,
+		LookingFor:        lookingFor,
+	}, 10)
+}
+
+// GetRecommendationsWithFilters returns up to limit recommendations for a custom search.
+// A limit <= 0 returns every scored candidate.
+func (rs *RecommendationService) GetRecommendationsWithFilters(
+	currentUserID uuid.UUID,
+	mode string,
+	f RecommendationFilters,
+	limit int,
+) ([]RecommendationWithDistance, error) {
+	// Mode is resolved per request; the shared service instance is never mutated
 	if mode != "desire" {
-		rs.Mode = "affinity"
-	} else {
-		rs.Mode = "desire"
+		mode = "affinity"
+	}
+	if f.OnlineOnly && !rs.OnlineOnly {
+		rs = rs.WithOnlineOnly()
 	}
 
 	var me models.User
@@ -492,13 +710,13 @@ func (rs *RecommendationService) GetRecommendationsWithFiltersWithDistance(
 		Error; err != nil {
 		return nil, err
 	}
-	me.Profile.Latitude = lat
-	me.Profile.Longitude = lon
+	me.Profile.Latitude = f.Latitude
+	me.Profile.Longitude = f.Longitude
 
 	nearby, err := rs.GetNearbyUsers(
-		lat, lon,
+		f.Latitude, f.Longitude,
 		me.Preference.MaxRadius,
-		100,
+		rs.CandidateLimit,
 		currentUserID,
 	)
 	if err != nil {
@@ -522,120 +740,208 @@ func (rs *RecommendationService) GetRecommendationsWithFiltersWithDistance(
 		return nil, err
 	}
 
-	meInts := splitTokens(strings.Join(interests, " "))
-	meHobs := splitTokens(strings.Join(hobbies, " "))
-	meMusic := splitTokens(strings.Join(music, " "))
-	meFood := splitTokens(strings.Join(food, " "))
-	meTrav := splitTokens(strings.Join(travel, " "))
+	// Custom filters are scored exactly like a saved bio with the given priorities
+	filterBio := models.Bio{
+		Interests:  strings.Join(f.Interests, " "),
+		Hobbies:    strings.Join(f.Hobbies, " "),
+		Music:      strings.Join(f.Music, " "),
+		Food:       strings.Join(f.Food, " "),
+		Travel:     strings.Join(f.Travel, " "),
+		LookingFor: f.LookingFor,
+	}
+	weights, err := rs.NormalizeFieldWeights(f.FieldWeights)
+	if err != nil {
+		return nil, err
+	}
+	filterPref := models.Preference{FieldWeights: ApplyPriorityFlags(weights, f.priorityFlags())}
 
 	type outCand struct {
-		ID       uuid.UUID
-		Score    float64
-		Distance float64
+		ID          uuid.UUID
+		Score       float64
+		Distance    float64
+		Online      bool
+		Explanation *RecommendationExplanation
+		Bio         models.Bio
 	}
 	var cands []outCand
 
+	declined, err := rs.declinedSet(currentUserID)
+	if err != nil {
+		return nil, err
+	}
+	cf, err := rs.collaborativeScores(currentUserID, ids)
+	if err != nil {
+		return nil, err
+	}
+	compat, err := rs.compatibilityScores(currentUserID, ids)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := rs.sharedCommunities(currentUserID, ids)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	activity, err := rs.activityOf(ids, now)
+	if err != nil {
+		return nil, err
+	}
+
 	for _, u := range users {
-		var rec models.Recommendation
-		if err := rs.DB.
-			Where("user_id = ? AND rec_user_id = ? AND status = ?", currentUserID, u.ID, "declined").
-			First(&rec).Error; err == nil {
+		if _, ok := declined[u.ID]; ok {
 			continue
 		}
 
 		d := distMap[u.ID]
-		var score float64
-
-		if rs.Mode == "affinity" {
-			// Interests
-			w := 0.02
-			if prioInterests {
-				w *= 2
-			}
-			score += float64(countCommon(meInts, splitTokens(u.Bio.Interests))) * w
-
-			// Hobbies
-			w = 0.02
-			if prioHobbies {
-				w *= 2
-			}
-			score += float64(countCommon(meHobs, splitTokens(u.Bio.Hobbies))) * w
-
-			// Music
-			w = 0.02
-			if prioMusic {
-				w *= 2
-			}
-			score += float64(countCommon(meMusic, splitTokens(u.Bio.Music))) * w
-
-			// Food
-			w = 0.02
-			if prioFood {
-				w *= 2
-			}
-			score += float64(countCommon(meFood, splitTokens(u.Bio.Food))) * w
-
-			// Travel
-			w = 0.02
-			if prioTravel {
-				w *= 2
-			}
-			score += float64(countCommon(meTrav, splitTokens(u.Bio.Travel))) * w
-		} else {
-			for _, tok := range splitTokens(lookingFor) {
-				if anyTokenMatch(tok, u.Bio.LookingFor) {
-					score += 0.05
-				}
-			}
+		score, expl := rs.scoreCandidate(mode, filterBio, filterPref, u.Bio, d, 0.05)
+		score = blendScore(score, cf, u.ID, expl)
+		score = blendCompatibility(score, compat, u.ID, expl)
+		score = boostCommunities(score, shared, u.ID, expl)
+		a := activity[u.ID]
+		score, ok := rs.applyActivity(score, a, now, expl)
+		if !ok {
+			continue
 		}
 
-		if score > 1 {
-			score = 1
-		}
 		if score > 0 {
-			cands = append(cands, outCand{ID: u.ID, Score: score, Distance: d})
+			cands = append(cands, outCand{ID: u.ID, Score: score, Distance: d, Online: a.Online, Explanation: expl, Bio: u.Bio})
 		}
 	}
 
-	sort.Slice(cands, func(i, j int) bool {
-		if math.Abs(cands[i].Distance-cands[j].Distance) > 1e-9 {
-			return cands[i].Distance < cands[j].Distance
-		}
-		return cands[i].Score > cands[j].Score
-	})
+	ranking := rs.rankingFor(me.Preference)
+	order, demoted, err := rs.orderCandidates(currentUserID, ranking, cands,
+		func(i int) (models.Bio, float64, float64) { return cands[i].Bio, cands[i].Distance, cands[i].Score },
+		func(i int) uuid.UUID { return cands[i].ID })
+	if err != nil {
+		return nil, err
+	}
 
-	limit := 10
-	if len(cands) < limit {
+	if limit <= 0 || len(cands) < limit {
 		limit = len(cands)
 	}
 	out := make([]RecommendationWithDistance, limit)
 	for i := 0; i < limit; i++ {
+		c := cands[order[i]]
+		if demoted[c.ID] && c.Explanation != nil {
+			c.Explanation.ExposureCapped = true
+		}
 		out[i] = RecommendationWithDistance{
-			UserID:   cands[i].ID,
-			Distance: cands[i].Distance,
-			Score:    cands[i].Score,
+			UserID:      c.ID,
+			Distance:    c.Distance,
+			Score:       c.Score,
+			Online:      c.Online,
+			Explanation: c.Explanation,
 		}
 	}
 	return out, nil
 }
 
+// ExplainRecommendation scores a single candidate against the current user's saved profile
+// and returns the score, distance and explanation, even if the score is zero.
+// Returns gorm.ErrRecordNotFound if the candidate does not exist or has no profile.
+func (rs *RecommendationService) ExplainRecommendation(
+	currentUserID, recUserID uuid.UUID, mode string,
+) (*RecommendationWithDistance, error) {
+	if mode != "desire" {
+		mode = "affinity"
+	}
+
+	var me models.User
+	if err := rs.DB.
+		Preload("Profile").Preload("Bio").Preload("Preference").
+		First(&me, "id = ?", currentUserID).Error; err != nil {
+		return nil, err
+	}
+	if err := validateUserData(me); err != nil {
+		return nil, err
+	}
+
+	var other models.User
+	if err := rs.DB.
+		Preload("Profile").Preload("Bio").
+		First(&other, "id = ?", recUserID).Error; err != nil {
+		return nil, err
+	}
+	if other.Profile.ID == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var distance float64
+	if err := rs.DB.
+		Raw(`SELECT earth_distance(ll_to_earth(?, ?), ll_to_earth(?, ?)) / 1000.0`,
+			me.Profile.Latitude, me.Profile.Longitude,
+			other.Profile.Latitude, other.Profile.Longitude,
+		).
+		Scan(&distance).Error; err != nil {
+		return nil, err
+	}
+
+	score, expl := rs.scoreCandidate(mode, me.Bio, me.Preference, other.Bio, distance, 0.05)
+	cf, err := rs.collaborativeScores(currentUserID, []uuid.UUID{other.ID})
+	if err != nil {
+		return nil, err
+	}
+	score = blendScore(score, cf, other.ID, expl)
+	compat, err := rs.compatibilityScores(currentUserID, []uuid.UUID{other.ID})
+	if err != nil {
+		return nil, err
+	}
+	score = blendCompatibility(score, compat, other.ID, expl)
+	shared, err := rs.sharedCommunities(currentUserID, []uuid.UUID{other.ID})
+	if err != nil {
+		return nil, err
+	}
+	score = boostCommunities(score, shared, other.ID, expl)
+	// Explanations show the activity boost but never filter the candidate out
+	now := time.Now()
+	explainer := *rs
+	explainer.OnlineOnly = false
+	activity, err := explainer.activityOf([]uuid.UUID{other.ID}, now)
+	if err != nil {
+		return nil, err
+	}
+	a := activity[other.ID]
+	score, _ = explainer.applyActivity(score, a, now, expl)
+	return &RecommendationWithDistance{
+		UserID:      other.ID,
+		Distance:    distance,
+		Score:       score,
+		Online:      a.Online,
+		Explanation: expl,
+	}, nil
+}
+
 // DeclineRecommendation marks a recommendation as declined for the current user.
+// Returns ErrAlreadyMatched for a mutual match, which has to be ended through the connection.
 func (rs *RecommendationService) DeclineRecommendation(
 	currentUserID, recUserID uuid.UUID,
 ) error {
-	var existing models.Recommendation
-	if err := rs.DB.
-		Where("user_id = ? AND rec_user_id = ?", currentUserID, recUserID).
-		First(&existing).Error; err == nil {
-		existing.Status = "declined"
-		return rs.DB.Save(&existing).Error
-	}
-	rec := models.Recommendation{
-		UserID:    currentUserID,
-		RecUserID: recUserID,
-		Status:    "declined",
-	}
-	return rs.DB.Create(&rec).Error
+	return rs.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockPair(tx, currentUserID, recUserID); err != nil {
+			return err
+		}
+		now := time.Now()
+		var existing models.Recommendation
+		err := tx.Where("user_id = ? AND rec_user_id = ?", currentUserID, recUserID).First(&existing).Error
+		if err == nil {
+			// A match has an accepted connection and a chat; declining must not hide that
+			if existing.Status == "matched" {
+				return ErrAlreadyMatched
+			}
+			existing.Status = "declined"
+			existing.DeclinedAt = &now
+			return tx.Save(&existing).Error
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		rec := models.Recommendation{
+			UserID:     currentUserID,
+			RecUserID:  recUserID,
+			Status:     "declined",
+			DeclinedAt: &now,
+		}
+		return tx.Create(&rec).Error
+	})
 }
 
 // anyTokenMatch checks if any token from a matches b (case-insensitive substring).
@@ -650,33 +956,3 @@ func anyTokenMatch(a, b string) bool {
 	return false
 }
 
-// validateUserData checks if the user profile and bio are sufficiently filled for recommendations.
-func validateUserData(u models.User) error {
-	if u.Profile.ID == 0 || u.Bio.ID == 0 {
-		return errors.New("please fill in your profile and biography to get recommendations")
-	}
-	if strings.TrimSpace(u.Profile.FirstName) == "" || strings.TrimSpace(u.Profile.LastName) == "" {
-		return errors.New("please provide your first and last name")
-	}
-	required := []struct {
-		val string
-		msg string
-	}{
-		{u.Bio.Interests, "interests"},
-		{u.Bio.Hobbies, "hobbies"},
-		{u.Bio.Music, "music"},
-		{u.Bio.Food, "food"},
-		{u.Bio.Travel, "travel"},
-		{u.Bio.LookingFor, "who you are looking for"},
-	}
-	var missing []string
-	for _, field := range required {
-		if strings.TrimSpace(field.val) == "" {
-			missing = append(missing, field.msg)
-		}
-	}
-	if len(missing) > 0 {
-		return errors.New("please complete your biography: " + strings.Join(missing, ", "))
-	}
-	return nil
-}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"m/backend/models"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Saved searches keep a custom recommendation search (the useProfile=false filters of
+// GET /recommendations) under a name. The saved search job re-runs every search with
+// alerts enabled, remembers who it found in saved_search_matches and sends a
+// saved_search_match event listing the users that qualify for the first time.
+// The first run only records the baseline, so creating a search does not alert
+// about everyone already nearby.
+
+var ErrInvalidSavedSearch = errors.New("invalid saved search")
+
+const (
+	// MaxSavedSearches is the number of searches a user can save.
+	MaxSavedSearches = 20
+	// savedSearchResultLimit caps the users considered per search run.
+	savedSearchResultLimit = 50
+)
+
+// SavedSearchInput is the editable part of a saved search.
+type SavedSearchInput struct {
+	Name    string                `json:"name"`
+	Mode    string                `json:"mode"`
+	Alerts  *bool                 `json:"alerts"`
+	Filters RecommendationFilters `json:"filters"`
+}
+
+// SavedSearchOutput is a saved search with its decoded filters.
+type SavedSearchOutput struct {
+	models.SavedSearch
+	Filters RecommendationFilters `json:"filters"`
+}
+
+// SavedSearchService stores saved searches and runs them for alerts.
+type SavedSearchService struct {
+	DB            *gorm.DB
+	Recs          *RecommendationService
+	Notifications *NotificationService
+}
+
+// NewSavedSearchService creates a saved search service ranking with recs.
+func NewSavedSearchService(db *gorm.DB, recs *RecommendationService) *SavedSearchService {
+	logrus.Info("SavedSearchService initialized")
+	return &SavedSearchService{DB: db, Recs: recs, Notifications: NewNotificationService(db)}
+}
+
+func (in SavedSearchInput) validate() error {
+	if strings.TrimSpace(in.Name) == "" || len(in.Name) > 100 {
+		return fmt.Errorf("%w: name is required (max 100 characters)", ErrInvalidSavedSearch)
+	}
+	if in.Mode != "" && in.Mode != "affinity" && in.Mode != "desire" {
+		return fmt.Errorf("%w: unknown mode %q", ErrInvalidSavedSearch, in.Mode)
+	}
+	f := in.Filters
+	if f.Latitude == 0 && f.Longitude == 0 {
+		return fmt.Errorf("%w: filters.latitude and filters.longitude are required", ErrInvalidSavedSearch)
+	}
+	if f.Latitude < -90 || f.Latitude > 90 || f.Longitude < -180 || f.Longitude > 180 {
+		return fmt.Errorf("%w: coordinates are out of range", ErrInvalidSavedSearch)
+	}
+	return nil
+}
+
+func toSavedSearchOutput(s models.SavedSearch) SavedSearchOutput {
+	out := SavedSearchOutput{SavedSearch: s}
+	if err := json.Unmarshal([]byte(s.Filters), &out.Filters); err != nil {
+		logrus.Warnf("saved search %d: bad filters: %v", s.ID, err)
+	}
+	return out
+}
+
+// List returns the user's saved searches, oldest first.
+func (ss *SavedSearchService) List(userID uuid.UUID) ([]SavedSearchOutput, error) {
+	var list []models.SavedSearch
+	if err := ss.DB.Where("user_id = ?", userID).Order("created_at, id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	out := make([]SavedSearchOutput, len(list))
+	for i, s := range list {
+		out[i] = toSavedSearchOutput(s)
+	}
+	return out, nil
+}
+
+// Get returns a saved search owned by the user, or gorm.ErrRecordNotFound.
+func (ss *SavedSearchService) Get(userID uuid.UUID, id uint) (*SavedSearchOutput, error) {
+	var s models.SavedSearch
+	if err := ss.DB.Where("id = ? AND user_id = ?", id, userID).First(&s).Error; err != nil {
+		return nil, err
+	}
+	out := toSavedSearchOutput(s)
+	return &out, nil
+}
+
+// Create saves a new search.
+func (ss *SavedSearchService) Create(userID uuid.UUID, in SavedSearchInput) (*SavedSearchOutput, error) {
+	if err := in.validate(); err != nil {
+		return nil, err
+	}
+	weights, err := ss.Recs.NormalizeFieldWeights(in.Filters.FieldWeights)
+	if err != nil {
+		return nil, fmt.Errorf("%w: filters.%v", ErrInvalidSavedSearch, err)
+	}
+	in.Filters.FieldWeights = weights
+	filters, err := json.Marshal(in.Filters)
+	if err != nil {
+		return nil, err
+	}
+	s := models.SavedSearch{
+		UserID:  userID,
+		Name:    strings.TrimSpace(in.Name),
+		Mode:    in.Mode,
+		Filters: string(filters),
+		Alerts:  true,
+	}
+	if s.Mode == "" {
+		s.Mode = "affinity"
+	}
+	err = ss.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.SavedSearch{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= MaxSavedSearches {
+			return fmt.Errorf("%w: at most %d saved searches", ErrInvalidSavedSearch, MaxSavedSearches)
+		}
+		if err := tx.Create(&s).Error; err != nil {
+			return err
+		}
+		// A false value is skipped on insert in favor of the column default
+		if in.Alerts != nil && !*in.Alerts {
+			s.Alerts = false
+			return tx.Model(&s).Update("alerts", false).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := toSavedSearchOutput(s)
+	return &out, nil
+}
+
+// Delete removes a saved search and what it has found.
+func (ss *SavedSearchService) Delete(userID uuid.UUID, id uint) error {
+	return ss.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("id = ? AND user_id = ?", id, userID).Delete(&models.SavedSearch{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("saved_search_id = ?", id).Delete(&models.SavedSearchMatch{}).Error
+	})
+}
+
+// Execute runs a saved search now and returns the ranked results.
+func (ss *SavedSearchService) Execute(s *SavedSearchOutput) ([]RecommendationWithDistance, error) {
+	return ss.Recs.GetRecommendationsWithFilters(s.UserID, s.Mode, s.Filters, savedSearchResultLimit)
+}
+
+// RunSearch executes a search, records newly found users and alerts the owner about
+// them (except on the first run). Returns the IDs of the new users.
+func (ss *SavedSearchService) RunSearch(s *SavedSearchOutput) ([]uuid.UUID, error) {
+	recs, err := ss.Execute(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var known []uuid.UUID
+	if err := ss.DB.Model(&models.SavedSearchMatch{}).
+		Where("saved_search_id = ?", s.ID).
+		Pluck("matched_user_id", &known).Error; err != nil {
+		return nil, err
+	}
+	seen := make(map[uuid.UUID]bool, len(known))
+	for _, id := range known {
+		seen[id] = true
+	}
+
+	var fresh []uuid.UUID
+	var rows []models.SavedSearchMatch
+	for _, rec := range recs {
+		if !seen[rec.UserID] {
+			fresh = append(fresh, rec.UserID)
+			rows = append(rows, models.SavedSearchMatch{SavedSearchID: s.ID, MatchedUserID: rec.UserID})
+		}
+	}
+
+	now := time.Now()
+	firstRun := s.LastRunAt == nil
+	err = ss.DB.Transaction(func(tx *gorm.DB) error {
+		if len(rows) > 0 {
+			if err := tx.CreateInBatches(rows, 100).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.SavedSearch{}).Where("id = ?", s.ID).Update("last_run_at", now).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.LastRunAt = &now
+
+	if firstRun || len(fresh) == 0 {
+		return fresh, nil
+	}
+	ids := make([]string, len(fresh))
+	for i, id := range fresh {
+		ids[i] = id.String()
+	}
+	if err := ss.Notifications.Notify(s.UserID, "saved_search_match", map[string]interface{}{
+		"search_id":   s.ID,
+		"search_name": s.Name,
+		"user_ids":    ids,
+		"count":       len(ids),
+		"found_at":    now.Unix(),
+	}); err != nil {
+		logrus.Errorf("RunSearch: notifying %s about search %d failed: %v", s.UserID, s.ID, err)
+	}
+	return fresh, nil
+}
+
+// RunAll runs every saved search with alerts enabled.
+func (ss *SavedSearchService) RunAll() error {
+	var list []models.SavedSearch
+	if err := ss.DB.Where("alerts = ?", true).Order("id").Find(&list).Error; err != nil {
+		return err
+	}
+	alerts := 0
+	for _, s := range list {
+		out := toSavedSearchOutput(s)
+		fresh, err := ss.RunSearch(&out)
+		if err != nil {
+			logrus.Errorf("SavedSearchService: search %d failed: %v", s.ID, err)
+			continue
+		}
+		if s.LastRunAt != nil && len(fresh) > 0 {
+			alerts++
+		}
+	}
+	logrus.Infof("SavedSearchService: %d searches run, %d with new matches", len(list), alerts)
+	return nil
+}
+
+// Run runs saved searches every interval. Blocks forever; start it in a goroutine.
+// A non-positive interval disables the job.
+func (ss *SavedSearchService) Run(interval time.Duration) {
+	if interval <= 0 {
+		logrus.Info("SavedSearchService: saved search alerts disabled")
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := ss.RunAll(); err != nil {
+			logrus.Errorf("SavedSearchService: run failed: %v", err)
+		}
+		<-ticker.C
+	}
+}
+
+// FoundBySavedSearch reports whether one of the user's saved searches found candidateID.
+// Used for access checks so alerted users can open the profiles they were told about.
+func (ss *SavedSearchService) FoundBySavedSearch(userID, candidateID uuid.UUID) (bool, error) {
+	var count int64
+	err := ss.DB.Model(&models.SavedSearchMatch{}).
+		Joins("JOIN saved_searches s ON s.id = saved_search_matches.saved_search_id").
+		Where("s.user_id = ? AND saved_search_matches.matched_user_id = ?", userID, candidateID).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"errors"
+	"html"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// User search finds people by name or by a phrase in About / LookingFor. Matching uses
+// the search_tsv columns (see models.SetupFullTextSearch) with websearch syntax, plus a
+// trigram similarity fallback on the full name for typos and partial names.
+//
+// Visibility follows the user access rules: results are users with an accepted or pending
+// connection, users who liked the searcher, or users within the searcher's MaxRadius.
+// Users either side has declined (and not yet expired) are hidden, which is how one user
+// blocks another in this app.
+
+var ErrInvalidQuery = errors.New("invalid search query")
+
+// MaxSearchQueryLength limits the q parameter.
+const MaxSearchQueryLength = 200
+
+// searchLanguage is the text search configuration of the search_tsv columns.
+var searchLanguage = "simple"
+
+// SetSearchLanguage sets the text search configuration used to parse queries.
+// It must match the one the search columns were built with.
+func SetSearchLanguage(language string) {
+	if language != "" {
+		searchLanguage = language
+	}
+}
+
+// SearchSnippets are fragments of the matched text as escaped HTML, with matches wrapped
+// in <mark></mark>. Markup written by users is escaped, so they are safe to render.
+type SearchSnippets struct {
+	About      string `json:"about,omitempty"`
+	LookingFor string `json:"lookingFor,omitempty"`
+}
+
+// UserSearchResult is one user found by a search.
+type UserSearchResult struct {
+	UserID    uuid.UUID      `json:"id"`
+	FirstName string         `json:"firstName"`
+	LastName  string         `json:"lastName"`
+	PhotoURL  string         `json:"photoUrl"`
+	City      string         `json:"city"`
+	Distance  *float64       `json:"distance,omitempty"`
+	Rank      float64        `json:"rank"`
+	Snippets  SearchSnippets `gorm:"-" json:"snippets"`
+}
+
+// UserSearchPage is one page of search results. Total counts all matches.
+type UserSearchPage struct {
+	Items    []UserSearchResult `json:"items"`
+	Page     int                `json:"page"`
+	PageSize int                `json:"pageSize"`
+	Total    int                `json:"total"`
+}
+
+// SearchService runs full-text user searches.
+type SearchService struct {
+	DB *gorm.DB
+}
+
+// NewSearchService creates a search service.
+func NewSearchService(db *gorm.DB) *SearchService {
+	return &SearchService{DB: db}
+}
+
+// ts_headline marks matches with private-use characters, which survive the HTML escaping
+// of the fragment and are then replaced by <mark> tags (see highlight). They are removed
+// from the user's text first, so only ts_headline can produce them.
+const (
+	searchMarkStart       = "\uE000"
+	searchMarkStop        = "\uE001"
+	searchHeadlineOptions = "StartSel=\"" + searchMarkStart + "\", StopSel=\"" + searchMarkStop + "\", " +
+		"MaxFragments=2, MaxWords=20, MinWords=5, FragmentDelimiter=\" … \""
+)
+
+var searchMarkReplacer = strings.NewReplacer(searchMarkStart, "<mark>", searchMarkStop, "</mark>")
+
+// highlight turns a ts_headline fragment into escaped HTML with <mark> around matches.
+func highlight(fragment string) string {
+	return searchMarkReplacer.Replace(html.EscapeString(fragment))
+}
+
+// searchMeCTE is the searcher's location and radius, taking the searcher's ID.
+const searchMeCTE = `me AS (
+		  SELECT p.earth_loc, COALESCE(pr.max_radius, 0) AS max_radius
+		  FROM profiles p
+		  LEFT JOIN preferences pr ON pr.user_id = p.user_id
+		  WHERE p.user_id = ?
+		  LIMIT 1
+		)`
+
+// searchVisibleCondition is true when the profile p is visible to the searcher, given
+// searchMeCTE joined as me. It takes the searcher's ID five times and the decline TTL twice.
+const searchVisibleCondition = `(
+		      EXISTS (
+		        SELECT 1 FROM connections c
+		        WHERE c.status IN ('accepted', 'pending')
+		          AND ((c.user_id = ? AND c.connection_id = p.user_id) OR (c.user_id = p.user_id AND c.connection_id = ?))
+		      )
+		      OR EXISTS (
+		        SELECT 1 FROM recommendations l
+		        WHERE l.user_id = p.user_id AND l.rec_user_id = ? AND l.status IN ('liked', 'matched')
+		      )
+		      OR (
+		        me.max_radius > 0
+		        AND earth_box(me.earth_loc, me.max_radius * 1000.0) @> p.earth_loc
+		        AND earth_distance(me.earth_loc, p.earth_loc) <= me.max_radius * 1000.0
+		      )
+		    )
+		    AND NOT EXISTS (
+		      SELECT 1 FROM recommendations r
+		      LEFT JOIN preferences pr ON pr.user_id = r.user_id
+		      WHERE ((r.user_id = ? AND r.rec_user_id = p.user_id) OR (r.user_id = p.user_id AND r.rec_user_id = ?))
+		        AND ` + activeDeclineCondition + `
+		    )`
+
+// Visible reports whether otherID could be found by userID's searches. Used for access
+// checks, so search results can be opened.
+func (ss *SearchService) Visible(userID, otherID uuid.UUID) (bool, error) {
+	var visible bool
+	err := ss.DB.Raw(`
+		WITH `+searchMeCTE+`
+		SELECT EXISTS (
+		  SELECT 1 FROM profiles p
+		  LEFT JOIN me ON true
+		  WHERE p.user_id = ? AND p.user_id <> ?
+		    AND `+searchVisibleCondition+`
+		)
+	`, userID, otherID, userID,
+		userID, userID, userID, userID, userID, declineTTLDays, declineTTLDays,
+	).Scan(&visible).Error
+	return visible, err
+}
+
+// SearchUsers returns page (1-based) of users matching q that are visible to userID,
+// best matches first.
+func (ss *SearchService) SearchUsers(userID uuid.UUID, q string, page, pageSize int) (*UserSearchPage, error) {
+	q = strings.TrimSpace(q)
+	if q == "" || len(q) > MaxSearchQueryLength {
+		return nil, ErrInvalidQuery
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	var rows []struct {
+		UserSearchResult
+		About      string
+		LookingFor string
+		Total      int
+	}
+	err := ss.DB.Raw(`
+		WITH `+searchMeCTE+`,
+		query AS (
+		  SELECT websearch_to_tsquery(CAST(? AS regconfig), ?) AS tsq, CAST(? AS text) AS raw
+		),
+		hits AS (
+		  SELECT
+		    p.user_id, p.first_name, p.last_name, p.photo_url, p.city, p.about,
+		    COALESCE(b.looking_for, '') AS looking_for,
+		    CASE WHEN me.earth_loc IS NOT NULL AND p.earth_loc IS NOT NULL
+		      THEN earth_distance(p.earth_loc, me.earth_loc) / 1000.0 END AS distance,
+		    ts_rank_cd(p.search_tsv || COALESCE(b.search_tsv, ''::tsvector), query.tsq)
+		      + similarity(coalesce(p.first_name, '') || ' ' || coalesce(p.last_name, ''), query.raw) AS rank
+		  FROM profiles p
+		  CROSS JOIN query
+		  LEFT JOIN me ON true
+		  LEFT JOIN bios b ON b.user_id = p.user_id
+		  WHERE p.user_id <> ?
+		    AND (
+		      (p.search_tsv || COALESCE(b.search_tsv, ''::tsvector)) @@ query.tsq
+		      OR (coalesce(p.first_name, '') || ' ' || coalesce(p.last_name, '')) % query.raw
+		    )
+		    AND `+searchVisibleCondition+`
+		)
+		SELECT
+		  hits.user_id, hits.first_name, hits.last_name, hits.photo_url, hits.city, hits.distance, hits.rank,
+		  CASE WHEN hits.about <> '' THEN ts_headline(CAST(? AS regconfig), translate(hits.about, ?, ''), query.tsq, ?) END AS about,
+		  CASE WHEN hits.looking_for <> '' THEN ts_headline(CAST(? AS regconfig), translate(hits.looking_for, ?, ''), query.tsq, ?) END AS looking_for,
+		  COUNT(*) OVER () AS total
+		FROM hits CROSS JOIN query
+		ORDER BY hits.rank DESC, hits.distance NULLS LAST, hits.user_id
+		LIMIT ? OFFSET ?
+	`,
+		userID,
+		searchLanguage, q, q,
+		userID,
+		userID, userID,
+		userID,
+		userID, userID, declineTTLDays, declineTTLDays,
+		searchLanguage, searchMarkStart+searchMarkStop, searchHeadlineOptions,
+		searchLanguage, searchMarkStart+searchMarkStop, searchHeadlineOptions,
+		pageSize, (page-1)*pageSize,
+	).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	out := &UserSearchPage{Items: make([]UserSearchResult, len(rows)), Page: page, PageSize: pageSize}
+	for i, row := range rows {
+		out.Items[i] = row.UserSearchResult
+		out.Items[i].Snippets = SearchSnippets{About: highlight(row.About), LookingFor: highlight(row.LookingFor)}
+		out.Total = row.Total
+	}
+	return out, nil
+}
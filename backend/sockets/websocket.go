@@ -19,6 +19,7 @@ import (
 
 var presenceSvc *services.PresenceService
 var chatsDB *gorm.DB
+var chatService *services.ChatService
 
 // This module implements real-time chat and notifications using WebSockets, that provide a persistent, full-duplex connection between client and server,
 // enabling instant message delivery, typing indicators, and presence updates.
@@ -27,9 +28,12 @@ var chatsDB *gorm.DB
 // The design ensures thread safety and efficient delivery of events to all relevant users.
 // This approach is essential for responsive, interactive chat and social features.
 
+// BroadcastMessage is delivered to the clients subscribed to ChatID and to every
+// connection of UserIDs (the chat participants), each client once.
 type BroadcastMessage struct {
-	ChatID uint   `json:"chatId"`
-	Data   []byte `json:"data"`
+	ChatID  uint     `json:"chatId"`
+	Data    []byte   `json:"data"`
+	UserIDs []string `json:"userIds,omitempty"`
 }
 
 type Client struct {
@@ -43,8 +47,10 @@ type Client struct {
 
 // Hub manages all WebSocket clients and chat subscriptions.
 // It handles registration, unregistration, and broadcasting messages to the correct chat subscribers.
+// UserClients indexes the clients by user ID so participants are found without a scan.
 type Hub struct {
 	Clients           map[*Client]bool
+	UserClients       map[string]map[*Client]bool
 	ChatSubscriptions map[uint]map[*Client]bool
 	Broadcast         chan BroadcastMessage
 	Register          chan *Client
@@ -54,6 +60,7 @@ type Hub struct {
 
 var hub = Hub{
 	Clients:           make(map[*Client]bool),
+	UserClients:       make(map[string]map[*Client]bool),
 	ChatSubscriptions: make(map[uint]map[*Client]bool),
 	Broadcast:         make(chan BroadcastMessage),
 	Register:          make(chan *Client),
@@ -80,6 +87,57 @@ func IsUserTypingInChat(chatID uint, userID string) bool {
 	return false
 }
 
+// removeClient drops a client from the hub and all its chat subscriptions and closes
+// its send channel. Callers must hold the hub mutex for writing.
+func removeClient(client *Client) {
+	delete(hub.Clients, client)
+	if clients := hub.UserClients[client.UserID]; clients != nil {
+		delete(clients, client)
+		if len(clients) == 0 {
+			delete(hub.UserClients, client.UserID)
+		}
+	}
+	for chatID, subs := range hub.ChatSubscriptions {
+		if subs[client] {
+			delete(subs, client)
+			if len(subs) == 0 {
+				delete(hub.ChatSubscriptions, chatID)
+			}
+		}
+	}
+	close(client.Send)
+}
+
+// UnsubscribeUser removes every connection of the user from the chat, so someone
+// who left or was removed from a group stops receiving its events right away.
+func UnsubscribeUser(chatID uint, userID uuid.UUID) {
+	var removed []*Client
+	hub.Mutex.Lock()
+	if subs := hub.ChatSubscriptions[chatID]; subs != nil {
+		for client := range subs {
+			if client.UserID == userID.String() {
+				delete(subs, client)
+				removed = append(removed, client)
+			}
+		}
+		if len(subs) == 0 {
+			delete(hub.ChatSubscriptions, chatID)
+		}
+	}
+	hub.Mutex.Unlock()
+
+	// Client state is locked after the hub, as readPump locks them in the other order
+	for _, client := range removed {
+		client.Mutex.Lock()
+		delete(client.Chats, chatID)
+		delete(client.TypingChats, chatID)
+		client.Mutex.Unlock()
+	}
+	if len(removed) > 0 {
+		logrus.Infof("UnsubscribeUser: %s unsubscribed from chat %d", userID, chatID)
+	}
+}
+
 func RunHub() {
 	// Main event loop for the WebSocket hub.
 	// Handles registration/unregistration of clients and broadcasting messages to chat subscribers.
@@ -89,6 +147,10 @@ func RunHub() {
 			// Register a new client connection
 			hub.Mutex.Lock()
 			hub.Clients[client] = true
+			if hub.UserClients[client.UserID] == nil {
+				hub.UserClients[client.UserID] = make(map[*Client]bool)
+			}
+			hub.UserClients[client.UserID][client] = true
 			hub.Mutex.Unlock()
 			logrus.Infof("Client registered: %s", client.UserID)
 
@@ -96,38 +158,36 @@ func RunHub() {
 			// Unregister a client and clean up all its subscriptions
 			hub.Mutex.Lock()
 			if _, ok := hub.Clients[client]; ok {
-				delete(hub.Clients, client)
 				// Remove client from all chat subscriptions
-				for chatID := range client.Chats {
-					if subs := hub.ChatSubscriptions[chatID]; subs != nil {
-						delete(subs, client)
-						if len(subs) == 0 {
-							delete(hub.ChatSubscriptions, chatID)
-						}
-					}
-				}
-				close(client.Send)
+				removeClient(client)
 				logrus.Infof("Client unregistered: %s", client.UserID)
 			}
 			hub.Mutex.Unlock()
 
 		case msg := <-hub.Broadcast:
-			// Broadcast a message to all clients subscribed to the chat
-			hub.Mutex.RLock()
-			if subs := hub.ChatSubscriptions[msg.ChatID]; subs != nil {
-				for client := range subs {
-					select {
-					case client.Send <- msg.Data:
-						logrus.Debugf("Message sent to client %s in chat %d", client.UserID, msg.ChatID)
-					default:
-						// If the send buffer is full, close the connection to avoid blocking the hub
-						logrus.Warnf("Send channel full for client %s. Closing.", client.UserID)
-						close(client.Send)
-						delete(hub.Clients, client)
-					}
+			// Broadcast a message to all clients subscribed to the chat and to the
+			// other connections of its participants
+			hub.Mutex.Lock()
+			targets := make(map[*Client]bool)
+			for client := range hub.ChatSubscriptions[msg.ChatID] {
+				targets[client] = true
+			}
+			for _, id := range msg.UserIDs {
+				for client := range hub.UserClients[id] {
+					targets[client] = true
+				}
+			}
+			for client := range targets {
+				select {
+				case client.Send <- msg.Data:
+					logrus.Debugf("Message sent to client %s in chat %d", client.UserID, msg.ChatID)
+				default:
+					// If the send buffer is full, close the connection to avoid blocking the hub
+					logrus.Warnf("Send channel full for client %s. Closing.", client.UserID)
+					removeClient(client)
 				}
 			}
-			hub.Mutex.RUnlock()
+			hub.Mutex.Unlock()
 		}
 	}
 }
@@ -186,20 +246,12 @@ func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 
 	// Start writePump in a separate goroutine to send messages
 	go client.writePump()
+	// Deliver events stored while the user was offline
+	go deliverPendingNotifications(client)
 	// Start readPump in the current goroutine to receive messages
 	client.readPump()
 }
 
-//Helper function for retrieving message IDs
-
-func getMessageIDs(msgs []models.Message) []uint {
-	ids := make([]uint, len(msgs))
-	for i, m := range msgs {
-		ids[i] = m.ID
-	}
-	return ids
-}
-
 func (c *Client) readPump() {
 	// Main loop for reading messages from the WebSocket connection.
 	// Handles subscription, typing, heartbeat, and other client events.
@@ -252,6 +304,10 @@ func (c *Client) readPump() {
 				logrus.Warnf("readPump: bad chat_id '%s' from %s", req.ChatID, c.UserID)
 				continue
 			}
+			if req.Action == "subscribe" && !isChatParticipant(uint(chatID), c.UserID) {
+				logrus.Warnf("readPump: %s is not a participant of chat %d", c.UserID, chatID)
+				continue
+			}
 			c.Mutex.Lock()
 			if req.Action == "subscribe" {
 				c.Chats[uint(chatID)] = true
@@ -317,36 +373,12 @@ func (c *Client) readPump() {
 				logrus.Warnf("readPump: invalid userID in read from %s", c.UserID)
 				continue
 			}
-			var unreadMsgs []models.Message
-			if err := chatsDB.
-				Model(&models.Message{}).
-				Where("chat_id = ? AND sender_id <> ? AND read = ?", chatID, uid, false).
-				Find(&unreadMsgs).Error; err != nil {
-				logrus.Errorf("readPump: failed to find unread messages for chat %d: %v", chatID, err)
-				continue
-			}
-			if len(unreadMsgs) == 0 {
-				continue // Защита от лишних событий!
-			}
-			if err := chatsDB.
-				Model(&models.Message{}).
-				Where("id IN ?", getMessageIDs(unreadMsgs)).
-				Update("read", true).Error; err != nil {
-				logrus.Errorf("readPump: failed to mark messages as read for chat %d: %v", chatID, err)
+			lastRead, readIDs, err := chatService.MarkRead(uint(chatID), uid, 0)
+			if err != nil {
+				logrus.Errorf("readPump: failed to mark chat %d read for %s: %v", chatID, c.UserID, err)
 				continue
 			}
-			for _, msg := range unreadMsgs {
-				payload := map[string]interface{}{
-					"type":       "read",
-					"chat_id":    chatID,
-					"message_id": msg.ID,
-				}
-				data, _ := json.Marshal(payload)
-				hub.Broadcast <- BroadcastMessage{
-					ChatID: uint(chatID),
-					Data:   data,
-				}
-			}
+			BroadcastReadState(uint(chatID), uid, lastRead, readIDs)
 
 		default:
 			logrus.Warnf("readPump: unknown action '%s' from %s", req.Action, c.UserID)
@@ -416,18 +448,122 @@ func BroadcastNewMessage(msg models.Message) error {
 		"read":        msg.Read,
 	}
 
+	if err := broadcastToChat(msg.ChatID, payload); err != nil {
+		logrus.Errorf("BroadcastNewMessage: %v", err)
+		return err
+	}
+	logrus.Infof("BroadcastNewMessage: sent message %d to chat %d", msg.ID, msg.ChatID)
+	return nil
+}
+
+// isChatParticipant reports whether the user may follow a chat.
+func isChatParticipant(chatID uint, userID string) bool {
+	uid, err := uuid.Parse(userID)
+	if err != nil || chatService == nil {
+		return false
+	}
+	_, err = chatService.Participant(chatID, uid)
+	return err == nil
+}
+
+// chatAudience returns the participant IDs of the chat as broadcast targets.
+func chatAudience(chatID uint) []string {
+	if chatService == nil {
+		return nil
+	}
+	ids, err := chatService.ParticipantIDs(chatID)
+	if err != nil {
+		logrus.Errorf("chatAudience: loading participants of chat %d failed: %v", chatID, err)
+	}
+	users := make([]string, len(ids))
+	for i, id := range ids {
+		users[i] = id.String()
+	}
+	return users
+}
+
+// broadcastToChat sends a JSON event to everyone following the chat and to all
+// connections of its participants.
+func broadcastToChat(chatID uint, payload interface{}) error {
+	return broadcastToAudience(chatID, chatAudience(chatID), payload)
+}
+
+// broadcastToAudience is broadcastToChat with the participants already resolved, for
+// sending several events at once.
+func broadcastToAudience(chatID uint, users []string, payload interface{}) error {
 	data, err := json.Marshal(payload)
 	if err != nil {
-		logrus.Errorf("BroadcastNewMessage: marshal error: %v", err)
 		return err
 	}
+	hub.Broadcast <- BroadcastMessage{ChatID: chatID, Data: data, UserIDs: users}
+	return nil
+}
+
+// BroadcastReadState sends a read event for each message that became read and the
+// user's new read position, looking the participants up once for all of them.
+func BroadcastReadState(chatID uint, userID uuid.UUID, lastRead uint, readIDs []uint) {
+	users := chatAudience(chatID)
+	for _, id := range readIDs {
+		if err := broadcastToAudience(chatID, users, map[string]interface{}{
+			"type":       "read",
+			"chat_id":    chatID,
+			"message_id": id,
+		}); err != nil {
+			logrus.Errorf("BroadcastReadState: %v", err)
+		}
+	}
+	if err := broadcastToAudience(chatID, users, map[string]interface{}{
+		"type":                 "read_state",
+		"chat_id":              chatID,
+		"user_id":              userID.String(),
+		"last_read_message_id": lastRead,
+	}); err != nil {
+		logrus.Errorf("BroadcastReadState: %v", err)
+	}
+}
 
-	hub.Broadcast <- BroadcastMessage{
-		ChatID: msg.ChatID,
-		Data:   data,
+// BroadcastChatEvent sends an event about a chat (renamed, members changed, read
+// positions) to its participants.
+func BroadcastChatEvent(chatID uint, payload map[string]interface{}) {
+	payload["chat_id"] = chatID
+	if err := broadcastToChat(chatID, payload); err != nil {
+		logrus.Errorf("BroadcastChatEvent: %v", err)
+	}
+}
+
+// BroadcastMessageEdited sends the new text of an edited message to the chat.
+func BroadcastMessageEdited(msg models.Message) {
+	payload := map[string]interface{}{
+		"type":      "message_edited",
+		"chat_id":   msg.ChatID,
+		"id":        msg.ID,
+		"sender_id": msg.SenderID.String(),
+		"content":   msg.Content,
+	}
+	if msg.EditedAt != nil {
+		payload["edited_at"] = msg.EditedAt.UnixNano() / int64(time.Millisecond)
+	}
+	if err := broadcastToChat(msg.ChatID, payload); err != nil {
+		logrus.Errorf("BroadcastMessageEdited: %v", err)
+	}
+}
+
+// BroadcastMessageDeleted tells the chat a message was deleted for everyone, or only
+// the user's own connections when they deleted it for themselves.
+func BroadcastMessageDeleted(msg models.Message, userID uuid.UUID, forEveryone bool) {
+	payload := map[string]interface{}{
+		"type":         "message_deleted",
+		"chat_id":      msg.ChatID,
+		"id":           msg.ID,
+		"for_everyone": forEveryone,
+	}
+	if !forEveryone {
+		SendToUser(userID, payload)
+		return
+	}
+	if err := broadcastToChat(msg.ChatID, payload); err != nil {
+		logrus.Errorf("BroadcastMessageDeleted: %v", err)
 	}
-	logrus.Infof("BroadcastNewMessage: sent message %d to chat %d", msg.ID, msg.ChatID)
-	return nil
 }
 
 func BroadcastNotification(userID uuid.UUID, message string) {
@@ -442,14 +578,12 @@ func BroadcastNotification(userID uuid.UUID, message string) {
 
 	hub.Mutex.RLock()
 	defer hub.Mutex.RUnlock()
-	for client := range hub.Clients {
-		if client.UserID == userID.String() {
-			select {
-			case client.Send <- data:
-				logrus.Infof("Notification sent to %s", userID)
-			default:
-				logrus.Warnf("Notification channel full for %s", userID)
-			}
+	for client := range hub.UserClients[userID.String()] {
+		select {
+		case client.Send <- data:
+			logrus.Infof("Notification sent to %s", userID)
+		default:
+			logrus.Warnf("Notification channel full for %s", userID)
 		}
 	}
 }
@@ -495,16 +629,76 @@ func BroadcastConnectionRequest(userID uuid.UUID) {
 
 	hub.Mutex.RLock()
 	defer hub.Mutex.RUnlock()
-	for client := range hub.Clients {
-		if client.UserID == userID.String() {
-			select {
-			case client.Send <- data:
-				logrus.Infof("ConnectionRequest event sent to %s", userID)
-			default:
-				logrus.Warnf("ConnectionRequest channel full for %s", userID)
-			}
+	for client := range hub.UserClients[userID.String()] {
+		select {
+		case client.Send <- data:
+			logrus.Infof("ConnectionRequest event sent to %s", userID)
+		default:
+			logrus.Warnf("ConnectionRequest channel full for %s", userID)
+		}
+	}
+}
+
+// SendToUser delivers a JSON event to every open connection of the user.
+// Returns true if at least one connection accepted the event, so callers can
+// store it for later delivery when the user is offline.
+func SendToUser(userID uuid.UUID, payload interface{}) bool {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		logrus.Errorf("SendToUser: marshal error: %v", err)
+		return false
+	}
+
+	delivered := false
+	hub.Mutex.RLock()
+	defer hub.Mutex.RUnlock()
+	for client := range hub.UserClients[userID.String()] {
+		select {
+		case client.Send <- data:
+			delivered = true
+		default:
+			logrus.Warnf("SendToUser: channel full for %s", userID)
 		}
 	}
+	return delivered
+}
+
+// deliverPendingNotifications sends events stored while the user was offline
+// (see services.NotificationService) to a freshly connected client.
+func deliverPendingNotifications(client *Client) {
+	userID, err := uuid.Parse(client.UserID)
+	if err != nil || chatsDB == nil {
+		return
+	}
+	events, err := services.NewNotificationService(chatsDB).TakePending(userID)
+	if err != nil {
+		logrus.Errorf("deliverPendingNotifications: %v", err)
+		return
+	}
+	for _, data := range events {
+		select {
+		case client.Send <- data:
+		default:
+			logrus.Warnf("deliverPendingNotifications: channel full for %s", client.UserID)
+			return
+		}
+	}
+	if len(events) > 0 {
+		logrus.Infof("deliverPendingNotifications: %d stored events sent to %s", len(events), client.UserID)
+	}
+}
+
+// BroadcastMatch notifies both users of a mutual like with the chat opened for them.
+func BroadcastMatch(userID, otherUserID uuid.UUID, chatID uint) {
+	for _, pair := range [][2]uuid.UUID{{userID, otherUserID}, {otherUserID, userID}} {
+		SendToUser(pair[0], map[string]interface{}{
+			"type":     "match",
+			"user_id":  pair[1].String(),
+			"chat_id":  chatID,
+			"match_at": time.Now().Unix(),
+		})
+		logrus.Infof("Match event sent to %s", pair[0])
+	}
 }
 
 func InitWebSocketServer(ps *services.PresenceService, addr string) error {
@@ -517,4 +711,5 @@ func InitWebSocketServer(ps *services.PresenceService, addr string) error {
 
 func SetChatsDB(db *gorm.DB) {
 	chatsDB = db
+	chatService = services.NewChatService(db)
 }
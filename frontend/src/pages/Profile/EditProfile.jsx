@@ -93,11 +93,12 @@ const EditProfile = () => {
           food:      bio.food      ? bio.food.split(' ')      : [],
           travel:    bio.travel    ? bio.travel.split(' ')    : [],
           lookingFor: bio.lookingFor || '',
-          priorityInterests: prefs.priorityInterests || false,
-          priorityHobbies:   prefs.priorityHobbies   || false,
-          priorityMusic:     prefs.priorityMusic     || false,
-          priorityFood:      prefs.priorityFood      || false,
-          priorityTravel:    prefs.priorityTravel    || false,
+          // A field is a priority when its weight is above the default of 1
+          priorityInterests: (prefs.fieldWeights?.Interests ?? 1) > 1,
+          priorityHobbies:   (prefs.fieldWeights?.Hobbies   ?? 1) > 1,
+          priorityMusic:     (prefs.fieldWeights?.Music     ?? 1) > 1,
+          priorityFood:      (prefs.fieldWeights?.Food      ?? 1) > 1,
+          priorityTravel:    (prefs.fieldWeights?.Travel    ?? 1) > 1,
         });
       } catch {
         toast.error('Error loading profile data');